@@ -3,25 +3,34 @@ package crudder
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"sync"
 	"time"
-
-	_ "github.com/go-sql-driver/mysql"
 )
 
 type App struct {
-	SessionStore map[string]*SessionData // Armazena dados de sessão para cada token de sessão
-	Mutex        sync.Mutex              // Protege o acesso a SessionStore em concorrência
+	SessionStore SessionStore           // Armazena dados de sessão para cada token de sessão (em memória ou SESSION_STORE_DIR)
+	SavedQueries map[string]*SavedQuery // Consultas nomeadas expostas em /views/{name}
+	Migrations   []*Migration           // Migrações carregadas de MIGRATIONS_DIR, em ordem de versão
+	SchemaTTL    time.Duration          // Validade do cache de metadados de cada sessão (SCHEMA_CACHE_TTL)
+	Conn         *ConnConfig            // Configuração de conexão com o servidor (DB_HOST, DB_PORT, DB_TLS...)
+	Profiles     map[string]*Profile    // Servidores nomeados de PROFILES_FILE, escolhidos no login
+	Cookie       *CookieConfig          // Atributos do cookie de sessão (COOKIE_PREFIX, COOKIE_SECURE...)
+	Policy       *Policy                // Permissões por tabela e coluna de POLICY_FILE, nil sem restrições
+
+	SessionIdleTimeout time.Duration // Inatividade máxima de uma sessão (SESSION_IDLE_TIMEOUT), 0 sem limite
+	SessionMaxAge      time.Duration // Duração máxima de uma sessão desde o login (SESSION_MAX_AGE), 0 sem limite
+	LoginLimiter       *LoginLimiter // Limita tentativas de login falhas por IP e por usuário, nil sem limite
 }
 
 type contextKey string
 
 const userDBKey contextKey = "userDB"
 
+// sessionKey holds the session bound to the {schema} of the route, see databaseMiddleware
+const sessionKey contextKey = "session"
+
 var sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
 	return sql.Open(driverName, dataSourceName)
 }
@@ -31,12 +40,20 @@ var sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
 // @Tags Authentication
 // @Accept application/x-www-form-urlencoded
 // @Produce json
-// @Param username formData string true "Database username" default(crudder_user)
-// @Param password formData string true "Database password" default(crudder_p455w0rd)
+// @Param username formData string false "Database username, not used by sqlite" default(crudder_user)
+// @Param password formData string false "Database password, not used by sqlite" default(crudder_p455w0rd)
 // @Param dbname formData string true "Database name" default(crudder_db_test)
-// @Success 200 {object} map[string]string "Login successful"
+// @Param engine formData string false "Database engine" Enums(mysql, postgres, sqlite) default(mysql)
+// @Param profile formData string false "Server profile from /profiles; replaces DB_HOST and engine"
+// @Param api_key formData boolean false "Return an API key for the Authorization: Bearer header instead of setting the session cookie"
+// @Param key_name formData string false "Name of the API key"
+// @Param expires_in formData string false "Lifetime of the API key as a Go duration" default(720h)
+// @Success 200 {object} map[string]string "Login successful, or the APIKeyInfo of the new key with api_key=true"
+// @Header 200 {string} X-CSRF-Token "Token to send in the X-CSRF-Token header of POST, PUT and DELETE requests made with the session cookie"
 // @Failure 400 {string} string "Username and password are required"
 // @Failure 401 {string} string "Invalid credentials"
+// @Failure 403 {string} string "Database not allowed on this server"
+// @Failure 429 {string} string "Too many failed logins, try again after the seconds in Retry-After"
 // @Failure 500 {string} string "Error connecting to the database"
 // @Router /login [post]
 func (app *App) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -46,93 +63,202 @@ func (app *App) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	//log.Printf("app: %+v, username: %s, password: %s", app, username, password)
 
-	// Input validation
-	if username == "" || password == "" {
+	// A server profile replaces DB_HOST and fixes the engine
+	conn, engine := app.connConfig(), r.FormValue("engine")
+	var profile *Profile
+	if name := r.FormValue("profile"); name != "" {
+		if profile = app.Profiles[name]; profile == nil {
+			WriteErrorResponse(w, http.StatusBadRequest, "Unknown server profile")
+			return
+		}
+		conn, engine = profile.conn, profile.Engine
+		if dbName == "" && len(profile.Databases) > 0 {
+			dbName = profile.Databases[0]
+		}
+		if !profile.allows(dbName) {
+			WriteErrorResponse(w, http.StatusForbidden, errDBNotAllowed)
+			return
+		}
+	}
+
+	dialect, ok := dialectFor(engine)
+	if !ok {
+		WriteErrorResponse(w, http.StatusBadRequest, "Unsupported database engine")
+		return
+	}
+
+	issueKey := r.FormValue("api_key") == "true"
+	keyTTL, err := parseAPIKeyTTL(r.FormValue("expires_in"))
+	if issueKey && err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Input validation; a SQLite file is chosen by the server, not by credentials
+	if dialect.Name() == "sqlite" {
+		if conn.SQLitePath == "" {
+			WriteErrorResponse(w, http.StatusBadRequest, "SQLite database not configured")
+			return
+		}
+	} else if username == "" || password == "" {
 		WriteErrorResponse(w, http.StatusBadRequest, "Username and password are required")
 		return
 	}
 
 	// Create DB connection  with the provided credentials
-	dbHost := os.Getenv("DB_HOST")
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s", username, password, dbHost, dbName)
+	login := &sessionLogin{Engine: dialect.Name(), Database: dbName, Username: username, Password: password}
+	if profile != nil {
+		login.Profile = profile.Name
+	}
 
-	db, err := sqlOpen("mysql", connStr)
+	// Locked out IPs and users are refused before the database sees the password
+	limiter := app.LoginLimiter
+	var ip string
+	var failures int
+	var lockout time.Duration
+	if limiter != nil {
+		ip = limiter.clientIP(r)
+		var wait time.Duration
+		if wait, failures, lockout = limiter.attempt(ip, login.owner(), time.Now()); wait > 0 {
+			slog.Warn("login refused", slog.String("ip", ip), slog.String("username", login.Username), slog.Duration("retry_after", wait))
+			writeTooManyAttempts(w, wait)
+			return
+		}
+	}
+
+	sessionData, err := app.newSession(login, conn, dialect, profile)
 	if err != nil {
+		if limiter != nil {
+			limiter.refund(ip, login.owner())
+		}
 		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
 		log.Println("Error opening connection:", err)
 		return
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := sessionData.DB.Ping(); err != nil {
+		sessionData.close()
+		if limiter != nil {
+			if isAuthError(err) {
+				logFailedLogin(r, ip, login, failures, lockout)
+			} else {
+				limiter.refund(ip, login.owner())
+			}
+		}
 		WriteErrorResponse(w, http.StatusUnauthorized, errInvalidCred)
 		log.Println("Error pinging database:", err)
 		return
 	}
+	if limiter != nil {
+		limiter.succeed(ip, login.owner())
+	}
+
+	// Machine clients get an API key in the body instead of the cookie
+	if issueKey {
+		info, err := app.issueAPIKey(sessionData, r.FormValue("key_name"), keyTTL)
+		if err != nil {
+			sessionData.close()
+			WriteErrorResponse(w, http.StatusInternalServerError, "Error issuing API key")
+			log.Println("error issuing API key:", err)
+			return
+		}
+		writeJSONResponseWithStatus(w, http.StatusOK, info)
+		return
+	}
 
-	// Create a unique session token
-	sessionToken := fmt.Sprintf("%d", time.Now().UnixNano())
+	// Create a random session token; only its hash is kept in the SessionStore
+	sessionToken, err := newSessionToken()
+	if err != nil {
+		sessionData.close()
+		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
+		log.Println("Error generating session token:", err)
+		return
+	}
 
 	// Store the connection in the SessionStore
-	app.Mutex.Lock()
-	app.SessionStore[sessionToken] = &SessionData{DB: db}
-	app.Mutex.Unlock()
-
-	// Set the cookie with the session token
-	cookie := &http.Cookie{
-		Name:    "session_token",
-		Value:   sessionToken,
-		Expires: time.Now().Add(5 * time.Minute),
+	sessionData.created = time.Now()
+	sessionData.touch(sessionData.created)
+	if err := app.SessionStore.Save(hashSessionToken(sessionToken), sessionData); err != nil {
+		sessionData.close()
+		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
+		log.Println("Error saving session:", err)
+		return
 	}
-	http.SetCookie(w, cookie)
+	expiry := app.sessionExpiry(sessionData)
+
+	// Set the cookie with the session token, and hand out the CSRF token that goes with it
+	http.SetCookie(w, app.cookieConfig().sessionCookie(r, sessionToken, expiry))
+	w.Header().Set(csrfHeader, csrfToken(sessionToken))
 
 	writeJSONResponseWithStatus(w, http.StatusOK, map[string]string{errMessage: errLoginOK})
 }
 
 // @Summary Logout
-// @Description Handler for logging out and closing the database connection associated with the session.
+// @Description Handler for logging out and closing the database connection associated with the session. Cookie sessions must send the X-CSRF-Token header.
 // @Tags Authentication
 // @Produce json
 // @Success 200 {object} map[string]string "Logout successful"
 // @Failure 400 {object} map[string]string "No session found"
-// @Router /logout [get]
+// @Failure 403 {object} map[string]string "Invalid or missing CSRF token"
+// @Router /logout [post]
 func (app *App) logoutHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	token, bearer := app.requestToken(r)
+	if token == "" {
 		WriteErrorResponse(w, http.StatusBadRequest, errNoSessionFound)
 		return
 	}
-	sessionToken := cookie.Value
-	app.Mutex.Lock()
-	sessionData, exists := app.SessionStore[sessionToken]
-	if exists {
-		// Fecha a conexão do banco de dados e remove a sessão
-		sessionData.DB.Close()
-		delete(app.SessionStore, sessionToken)
+	// Sem o token CSRF, outra página poderia encerrar a sessão pelo cookie
+	if !csrfExempt(r, bearer) && !validCSRF(r, token) {
+		WriteErrorResponse(w, http.StatusForbidden, errInvalidCSRF)
+		return
+	}
+	id := hashSessionToken(token)
+	// Remove a sessão e fecha as conexões do banco de dados
+	if sessionData := app.SessionStore.Delete(id); sessionData != nil {
+		sessionData.close()
 	}
-	app.Mutex.Unlock()
 
 	// Invalida o cookie
-	cookie.Expires = time.Now().Add(-time.Hour)
-	http.SetCookie(w, cookie)
+	http.SetCookie(w, app.cookieConfig().sessionCookie(r, "", time.Now().Add(-time.Hour)))
 	writeJSONResponseWithStatus(w, http.StatusOK, map[string]string{errMessage: errLogoutOK})
 }
 
-// Middleware to check if user is authenticated and get user connection
+// Middleware to check if user is authenticated and get user connection. Expired
+// sessions are closed; live ones are renewed for another SESSION_IDLE_TIMEOUT.
 func (app *App) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_token")
-		if err != nil {
+		token, bearer := app.requestToken(r)
+		if token == "" {
 			writeJSONResponseWithStatus(w, http.StatusUnauthorized, map[string]string{errMessage: errUnauthorized})
 			return
 		}
-		sessionToken := cookie.Value
-		app.Mutex.Lock()
-		sessionData, exists := app.SessionStore[sessionToken]
-		app.Mutex.Unlock()
-		if !exists {
+		cookies := app.cookieConfig()
+		sessionData, expiry, expired := app.touchSession(hashSessionToken(token), time.Now())
+		if expired {
+			sessionData.close()
+			if !bearer {
+				http.SetCookie(w, cookies.sessionCookie(r, "", time.Now().Add(-time.Hour)))
+			}
+			writeJSONResponseWithStatus(w, http.StatusUnauthorized, map[string]string{errMessage: errSessionExpired})
+			return
+		}
+		if sessionData == nil {
 			writeJSONResponseWithStatus(w, http.StatusUnauthorized, map[string]string{errMessage: errUnauthorized})
 			return
 		}
+		// The cookie is sent by the browser on requests from any page, so changes also
+		// need the CSRF token, which only our pages and the login response know
+		if !csrfExempt(r, bearer) && !validCSRF(r, token) {
+			writeJSONResponseWithStatus(w, http.StatusForbidden, map[string]string{errMessage: errInvalidCSRF})
+			return
+		}
+		if !bearer {
+			w.Header().Set(csrfHeader, csrfToken(token))
+		}
+		// Sliding renewal: the cookie lives as long as the session
+		if !bearer && sessionData.apiKey == nil && app.SessionIdleTimeout > 0 {
+			http.SetCookie(w, cookies.sessionCookie(r, token, expiry))
+		}
 		// Store the connection in the context for the next handler
 		ctx := context.WithValue(r.Context(), userDBKey, sessionData.DB)
 		next.ServeHTTP(w, r.WithContext(ctx))
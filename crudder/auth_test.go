@@ -79,7 +79,7 @@ func TestLoginHandler(t *testing.T) {
 			}
 
 			// Initialize app with an empty session store
-			app := &App{SessionStore: make(map[string]*SessionData)}
+			app := &App{SessionStore: NewMemoryStore()}
 
 			// Set environment variable
 			os.Setenv("DB_HOST", "localhost")
@@ -112,12 +112,13 @@ func TestLoginHandler(t *testing.T) {
 					t.Fatal("No session token set")
 				}
 				sessionToken := cookies[0].Value
-				app.Mutex.Lock()
-				_, exists := app.SessionStore[sessionToken]
-				app.Mutex.Unlock()
+				_, exists := app.SessionStore.Get(hashSessionToken(sessionToken))
 				if !exists {
 					t.Fatal("Session not found for token")
 				}
+				if got := w.Header().Get("X-CSRF-Token"); got != csrfToken(sessionToken) {
+					t.Errorf("Expected the CSRF token of the session, got %q", got)
+				}
 			}
 
 			// Validate all expectations were met
@@ -140,24 +141,36 @@ func TestLogoutHandler(t *testing.T) {
 	mock.ExpectClose()
 
 	// Inicializa o App com uma sessão válida
-	app := &App{SessionStore: make(map[string]*SessionData)}
+	app := &App{SessionStore: NewMemoryStore()}
 	sessionToken := "mockSession"
-	app.SessionStore[sessionToken] = &SessionData{DB: db}
+	app.SessionStore.Save(hashSessionToken(sessionToken), &SessionData{DB: db})
 
 	// Cria a requisição simulada com o cookie de sessão
+	// Sem o token CSRF a sessão é mantida
 	req := httptest.NewRequest("POST", "/logout", nil)
 	req.AddCookie(&http.Cookie{Name: "session_token", Value: sessionToken})
 	w := httptest.NewRecorder()
+	app.logoutHandler(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Esperado status 403 sem o token CSRF, obtido %d", w.Code)
+	}
+	if _, exists := app.SessionStore.Get(hashSessionToken(sessionToken)); !exists {
+		t.Error("Esperado que a sessão fosse mantida sem o token CSRF")
+	}
+
+	// Cria a requisição simulada com o cookie de sessão
+	req = httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: sessionToken})
+	req.Header.Set(csrfHeader, csrfToken(sessionToken))
+	w = httptest.NewRecorder()
 
 	// Executa o handler
 	app.logoutHandler(w, req)
 
 	// Verifica se a sessão foi removida
-	app.Mutex.Lock()
-	if _, exists := app.SessionStore[sessionToken]; exists {
+	if _, exists := app.SessionStore.Get(hashSessionToken(sessionToken)); exists {
 		t.Error("Esperado que a sessão fosse removida")
 	}
-	app.Mutex.Unlock()
 
 	// Verifica o status da resposta
 	if w.Result().StatusCode != http.StatusOK {
@@ -179,9 +192,9 @@ func TestAuthMiddleware(t *testing.T) {
 	defer db.Close()
 
 	// Inicializa o App com uma sessão válida
-	app := &App{SessionStore: make(map[string]*SessionData)}
+	app := &App{SessionStore: NewMemoryStore()}
 	sessionToken := "mockSession"
-	app.SessionStore[sessionToken] = &SessionData{DB: db}
+	app.SessionStore.Save(hashSessionToken(sessionToken), &SessionData{DB: db})
 
 	// Rota protegida simulada
 	protectedHandler := app.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
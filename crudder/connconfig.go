@@ -0,0 +1,210 @@
+package crudder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS modes accepted in DB_TLS, named after the PostgreSQL sslmode values
+var tlsModes = map[string]bool{"": true, "disable": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true}
+
+// mysqlTLSConfigName is the name the verify-ca and verify-full configurations are
+// registered under in the MySQL driver, so FormatDSN can refer to them. Server
+// profiles register theirs as "crudder-<profile>".
+const mysqlTLSConfigName = "crudder"
+
+// ConnConfig describes how logins reach the database server. Only the credentials and
+// the database name come from the login form; the rest is server configuration read
+// from the environment by LoadConnConfig.
+type ConnConfig struct {
+	Host         string            // DB_HOST
+	Port         int               // DB_PORT; 0 means the default port of the engine
+	Socket       string            // DB_SOCKET: unix socket (MySQL) or socket directory (PostgreSQL), replaces host and port
+	TLSMode      string            // DB_TLS: disable, prefer, require, verify-ca or verify-full
+	TLSCA        string            // DB_TLS_CA: PEM file with the CA certificates for verify-ca and verify-full
+	ParseTime    bool              // DB_PARSE_TIME: MySQL only, scan DATE and DATETIME into time.Time
+	Loc          *time.Location    // DB_LOC: MySQL only, time zone of the parsed times
+	Charset      string            // DB_CHARSET: MySQL only
+	Collation    string            // DB_COLLATION: MySQL only
+	Timeout      time.Duration     // DB_TIMEOUT: dial timeout
+	ReadTimeout  time.Duration     // DB_READ_TIMEOUT: MySQL only
+	WriteTimeout time.Duration     // DB_WRITE_TIMEOUT: MySQL only
+	Params       map[string]string // DB_PARAMS: extra driver parameters as a query string, e.g. "interpolateParams=true"
+	SQLitePath   string            // SQLITE_PATH: database file opened by engine=sqlite
+
+	profile string // name of the server profile the settings belong to, "" for the environment
+}
+
+// LoadConnConfig reads the connection settings from the environment
+func LoadConnConfig() (*ConnConfig, error) {
+	conn := &ConnConfig{
+		Host:       os.Getenv("DB_HOST"),
+		Socket:     os.Getenv("DB_SOCKET"),
+		TLSMode:    os.Getenv("DB_TLS"),
+		TLSCA:      os.Getenv("DB_TLS_CA"),
+		Charset:    os.Getenv("DB_CHARSET"),
+		Collation:  os.Getenv("DB_COLLATION"),
+		SQLitePath: os.Getenv("SQLITE_PATH"),
+	}
+
+	var err error
+	if value := os.Getenv("DB_PORT"); value != "" {
+		if conn.Port, err = strconv.Atoi(value); err != nil || conn.Port <= 0 || conn.Port > 65535 {
+			return nil, fmt.Errorf("invalid DB_PORT %q", value)
+		}
+	}
+	if value := os.Getenv("DB_PARSE_TIME"); value != "" {
+		if conn.ParseTime, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid DB_PARSE_TIME %q", value)
+		}
+	}
+	if value := os.Getenv("DB_LOC"); value != "" {
+		if conn.Loc, err = time.LoadLocation(value); err != nil {
+			return nil, fmt.Errorf("invalid DB_LOC: %v", err)
+		}
+	}
+	for name, target := range map[string]*time.Duration{"DB_TIMEOUT": &conn.Timeout, "DB_READ_TIMEOUT": &conn.ReadTimeout, "DB_WRITE_TIMEOUT": &conn.WriteTimeout} {
+		if value := os.Getenv(name); value != "" {
+			if *target, err = time.ParseDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", name, err)
+			}
+		}
+	}
+	if value := os.Getenv("DB_PARAMS"); value != "" {
+		params, err := url.ParseQuery(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_PARAMS: %v", err)
+		}
+		conn.Params = make(map[string]string, len(params))
+		for name := range params {
+			conn.Params[name] = params.Get(name)
+		}
+	}
+
+	if err := conn.setupTLS(); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// setupTLS checks the TLS mode and, for the verifying modes, registers the CA
+// certificates with the MySQL driver
+func (c *ConnConfig) setupTLS() error {
+	if !tlsModes[c.TLSMode] {
+		return fmt.Errorf("invalid DB_TLS %q", c.TLSMode)
+	}
+
+	var roots *x509.CertPool // nil verifies against the system roots
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return fmt.Errorf("error reading DB_TLS_CA: %v", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in DB_TLS_CA %s", c.TLSCA)
+		}
+	}
+
+	switch c.TLSMode {
+	case "verify-ca":
+		return mysql.RegisterTLSConfig(c.tlsConfigName(), verifyCATLSConfig(roots))
+	case "verify-full":
+		return mysql.RegisterTLSConfig(c.tlsConfigName(), &tls.Config{RootCAs: roots})
+	}
+	return nil
+}
+
+func (c *ConnConfig) tlsConfigName() string {
+	if c.profile == "" {
+		return mysqlTLSConfigName
+	}
+	return mysqlTLSConfigName + "-" + c.profile
+}
+
+// verifyCATLSConfig checks the certificate chain of the server but not its host name
+func verifyCATLSConfig(roots *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true, // the chain is verified below
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("server sent no certificate")
+			}
+			opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
+
+// address joins the host with the configured port, or with the default of the engine
+func (c *ConnConfig) address(defaultPort int) string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// mysqlConfig returns the MySQL driver configuration of a login
+func (c *ConnConfig) mysqlConfig(username, password, dbName string) *mysql.Config {
+	config := mysql.NewConfig()
+	config.User, config.Passwd, config.DBName = username, password, dbName
+	if c.Socket != "" {
+		config.Net, config.Addr = "unix", c.Socket
+	} else {
+		config.Net, config.Addr = "tcp", c.address(3306)
+	}
+	switch c.TLSMode {
+	case "prefer":
+		config.TLSConfig = "preferred"
+	case "require":
+		config.TLSConfig = "skip-verify"
+	case "verify-ca", "verify-full":
+		config.TLSConfig = c.tlsConfigName()
+	}
+	config.ParseTime = c.ParseTime
+	if c.Loc != nil {
+		config.Loc = c.Loc
+	}
+	if c.Collation != "" {
+		config.Collation = c.Collation
+	}
+	config.Timeout, config.ReadTimeout, config.WriteTimeout = c.Timeout, c.ReadTimeout, c.WriteTimeout
+	if c.Charset != "" || len(c.Params) > 0 {
+		config.Params = make(map[string]string, len(c.Params)+1)
+		if c.Charset != "" {
+			config.Params["charset"] = c.Charset
+		}
+		for name, value := range c.Params {
+			config.Params[name] = value
+		}
+	}
+	return config
+}
+
+// connConfig returns the connection settings loaded at startup, reading them from the
+// environment when the app was built without them
+func (app *App) connConfig() *ConnConfig {
+	if app.Conn != nil {
+		return app.Conn
+	}
+	conn, err := LoadConnConfig()
+	if err != nil {
+		log.Println("invalid database connection settings:", err)
+		return &ConnConfig{Host: os.Getenv("DB_HOST"), SQLitePath: os.Getenv("SQLITE_PATH")}
+	}
+	return conn
+}
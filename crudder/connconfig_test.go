@@ -0,0 +1,129 @@
+package crudder
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCA writes a self-signed CA certificate and returns the path of the PEM file
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "crudder test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+func TestLoadConnConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		t.Setenv("DB_HOST", "mysql")
+		conn, err := LoadConnConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "mysql:3306", conn.address(3306))
+		assert.Equal(t, "mysql:5432", conn.address(5432))
+	})
+
+	t.Run("All settings", func(t *testing.T) {
+		ca := writeTestCA(t)
+		t.Setenv("DB_HOST", "db.internal")
+		t.Setenv("DB_PORT", "3307")
+		t.Setenv("DB_TLS", "verify-full")
+		t.Setenv("DB_TLS_CA", ca)
+		t.Setenv("DB_PARSE_TIME", "true")
+		t.Setenv("DB_LOC", "America/Sao_Paulo")
+		t.Setenv("DB_CHARSET", "utf8mb4")
+		t.Setenv("DB_COLLATION", "utf8mb4_unicode_ci")
+		t.Setenv("DB_TIMEOUT", "5s")
+		t.Setenv("DB_READ_TIMEOUT", "30s")
+		t.Setenv("DB_WRITE_TIMEOUT", "1m")
+		t.Setenv("DB_PARAMS", "interpolateParams=true&sql_mode=ANSI")
+
+		conn, err := LoadConnConfig()
+		require.NoError(t, err)
+		assert.Equal(t, 3307, conn.Port)
+		assert.Equal(t, "verify-full", conn.TLSMode)
+		assert.True(t, conn.ParseTime)
+		assert.Equal(t, "America/Sao_Paulo", conn.Loc.String())
+		assert.Equal(t, 5*time.Second, conn.Timeout)
+		assert.Equal(t, 30*time.Second, conn.ReadTimeout)
+		assert.Equal(t, time.Minute, conn.WriteTimeout)
+		assert.Equal(t, map[string]string{"interpolateParams": "true", "sql_mode": "ANSI"}, conn.Params)
+
+		// the DSN parses back to the same settings
+		dsn := mysqlDialect{}.DSN(conn, "crudder_user", "p@ss:w/rd?#", "crudder_db_test")
+		assert.Contains(t, dsn, "charset=utf8mb4")
+		config, err := mysql.ParseDSN(dsn)
+		require.NoError(t, err)
+		assert.Equal(t, "crudder_user", config.User)
+		assert.Equal(t, "p@ss:w/rd?#", config.Passwd)
+		assert.Equal(t, "db.internal:3307", config.Addr)
+		assert.Equal(t, "crudder_db_test", config.DBName)
+		assert.Equal(t, mysqlTLSConfigName, config.TLSConfig)
+		require.NotNil(t, config.TLS)
+		assert.NotNil(t, config.TLS.RootCAs)
+		assert.Equal(t, "db.internal", config.TLS.ServerName)
+		assert.True(t, config.ParseTime)
+		assert.Equal(t, "America/Sao_Paulo", config.Loc.String())
+		assert.Equal(t, "utf8mb4_unicode_ci", config.Collation)
+		assert.Equal(t, 5*time.Second, config.Timeout)
+		assert.True(t, config.InterpolateParams)
+		assert.Equal(t, map[string]string{"sql_mode": "ANSI"}, config.Params)
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		for name, value := range map[string]string{
+			"DB_PORT":       "mysql",
+			"DB_TLS":        "always",
+			"DB_TLS_CA":     filepath.Join(t.TempDir(), "missing.pem"),
+			"DB_PARSE_TIME": "maybe",
+			"DB_LOC":        "Mars/Olympus",
+			"DB_TIMEOUT":    "5",
+			"DB_PARAMS":     "a=%zz",
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv(name, value)
+				_, err := LoadConnConfig()
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestMySQLConfig(t *testing.T) {
+	t.Run("Unix socket", func(t *testing.T) {
+		config := (&ConnConfig{Host: "ignored", Socket: "/run/mysqld/mysqld.sock"}).mysqlConfig("u", "p", "crudder_db_test")
+		assert.Equal(t, "u:p@unix(/run/mysqld/mysqld.sock)/crudder_db_test", config.FormatDSN())
+	})
+
+	t.Run("TLS modes", func(t *testing.T) {
+		for mode, value := range map[string]string{"": "", "disable": "", "prefer": "preferred", "require": "skip-verify", "verify-ca": mysqlTLSConfigName} {
+			assert.Equal(t, value, (&ConnConfig{TLSMode: mode}).mysqlConfig("u", "p", "d").TLSConfig, mode)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package crudder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+// csrfHeader carries the CSRF token on cookie authenticated requests that change state
+const csrfHeader = "X-CSRF-Token"
+
+// csrfToken returns the CSRF token of a session token. It is derived instead of stored,
+// so every replica sharing SESSION_STORE_DIR accepts it, and a page on another origin
+// cannot compute it because it cannot read the HttpOnly session cookie.
+func csrfToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfExempt tells whether a request needs no CSRF token: methods that do not change
+// state, and requests with an Authorization: Bearer header, which browsers never add
+// on their own
+func csrfExempt(r *http.Request, bearer bool) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return bearer
+}
+
+// validCSRF tells whether r carries the CSRF token of its session token
+func validCSRF(r *http.Request, sessionToken string) bool {
+	return hmac.Equal([]byte(r.Header.Get(csrfHeader)), []byte(csrfToken(sessionToken)))
+}
+
+// pageCSRFToken returns the CSRF token for the pages rendered to the browser of r,
+// empty when it has no session cookie
+func (app *App) pageCSRFToken(r *http.Request) string {
+	cookie, err := r.Cookie(app.cookieConfig().name())
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	return csrfToken(cookie.Value)
+}
@@ -0,0 +1,72 @@
+package crudder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRFToken(t *testing.T) {
+	assert.Equal(t, csrfToken("a"), csrfToken("a"))
+	assert.NotEqual(t, csrfToken("a"), csrfToken("b"))
+	assert.NotContains(t, csrfToken("mockSession"), "mockSession", "the token does not reveal the session token")
+
+	app := &App{}
+	req := httptest.NewRequest(http.MethodGet, "/welcome", nil)
+	assert.Empty(t, app.pageCSRFToken(req))
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	assert.Equal(t, csrfToken("mockSession"), app.pageCSRFToken(req))
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	app, _ := newSQLiteTestApp(t)
+	serve := func(method, token string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/crud/roles", strings.NewReader(`{"name":"`+method+token+`"}`))
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+		}
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		SetupRouter(app).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Missing token", func(t *testing.T) {
+		w := serve(http.MethodPost, "mockSession", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"Invalid or missing CSRF token"}`, w.Body.String())
+	})
+
+	t.Run("Token of another session", func(t *testing.T) {
+		w := serve(http.MethodPost, "mockSession", map[string]string{"X-CSRF-Token": csrfToken("other")})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Valid token", func(t *testing.T) {
+		w := serve(http.MethodPost, "mockSession", map[string]string{"X-CSRF-Token": csrfToken("mockSession")})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Reads need no token and return it", func(t *testing.T) {
+		w := serve(http.MethodGet, "mockSession", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, csrfToken("mockSession"), w.Header().Get("X-CSRF-Token"))
+	})
+
+	t.Run("Bearer requests are exempt", func(t *testing.T) {
+		w := serve(http.MethodPost, "", map[string]string{"Authorization": "Bearer mockSession"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Empty(t, w.Header().Get("X-CSRF-Token"))
+	})
+
+	t.Run("Unauthenticated requests get 401 first", func(t *testing.T) {
+		w := serve(http.MethodPost, "unknown", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
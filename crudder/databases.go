@@ -0,0 +1,214 @@
+package crudder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// struct represents a schema the logged in user can see
+type DatabaseInfo struct {
+	Name         string `json:"name"`
+	CharacterSet string `json:"character_set"`
+	Collation    string `json:"collation"`
+	Current      bool   `json:"current"`
+}
+
+// sessionDatabases holds the connection pools opened by one login, one per schema.
+// Every SessionData of the login shares it, whichever schema is active.
+type sessionDatabases struct {
+	mutex   sync.Mutex
+	config  *mysql.Config // login credentials; DBName is replaced for each pool
+	profile *Profile      // server profile of the login, nil for DB_HOST
+	byName  map[string]*SessionData
+}
+
+// newSessionDatabases lets a new session open the other schemas its login can see,
+// limited to the databases of the profile when there is one
+func newSessionDatabases(session *SessionData, config *mysql.Config, profile *Profile) {
+	session.databases = &sessionDatabases{config: config, profile: profile, byName: map[string]*SessionData{config.DBName: session}}
+}
+
+// allows reports whether the login may open the schema
+func (d *sessionDatabases) allows(name string) bool {
+	return d == nil || d.profile == nil || d.profile.allows(name)
+}
+
+// database returns the session bound to another schema of the same login, opening a
+// connection pool with the login credentials the first time it is asked for
+func (s *SessionData) database(name string) (*SessionData, error) {
+	if s.databases == nil {
+		return nil, fmt.Errorf("session cannot switch databases")
+	}
+
+	if !s.databases.allows(name) {
+		return nil, fmt.Errorf("database %s is not allowed by profile %s", name, s.databases.profile.Name)
+	}
+
+	s.databases.mutex.Lock()
+	defer s.databases.mutex.Unlock()
+	if session, exists := s.databases.byName[name]; exists {
+		return session, nil
+	}
+
+	config := s.databases.config.Clone()
+	config.DBName = name
+	db, err := sqlOpen("mysql", config.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	session := &SessionData{DB: db, dialect: s.dialect, databases: s.databases, created: s.created, apiKey: s.apiKey}
+	session.schema.ttl = s.schema.ttl
+	if s.login != nil {
+		login := *s.login
+		login.Database = name
+		session.login = &login
+	}
+	s.databases.byName[name] = session
+	return session, nil
+}
+
+// close closes every connection pool of the login
+func (s *SessionData) close() {
+	if s.databases == nil {
+		s.DB.Close()
+		return
+	}
+
+	s.databases.mutex.Lock()
+	defer s.databases.mutex.Unlock()
+	for _, session := range s.databases.byName {
+		session.DB.Close()
+	}
+}
+
+// Middleware for the routes with a {schema} segment: binds the request to the
+// session's connection on that schema
+func (app *App) databaseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["schema"]
+		if !isAlphaNumeric(name) {
+			WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+			return
+		}
+
+		session := app.getSession(r)
+		if session == nil {
+			WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+
+		database, err := session.database(name)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusNotFound, errDBNotFound)
+			log.Println("error opening database", name, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionKey, database)
+		ctx = context.WithValue(ctx, userDBKey, database.DB)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// @Summary List Databases
+// @Description Lists the schemas the logged in MySQL user can see, limited to the databases of the server profile of the login, marking the one the session is using. Any of them can be browsed through the /databases/{schema}/... routes or made the active one.
+// @Tags Database
+// @Produce json
+// @Success 200 {array} DatabaseInfo "List of databases"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /databases [get]
+func (app *App) listDatabasesHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	if session == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	rows, err := session.DB.Query(`
+        SELECT SCHEMA_NAME, DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME,
+               COALESCE(SCHEMA_NAME = DATABASE(), 0)
+        FROM information_schema.schemata
+        ORDER BY SCHEMA_NAME
+    `)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return
+	}
+	defer rows.Close()
+
+	databases := []DatabaseInfo{}
+	for rows.Next() {
+		var database DatabaseInfo
+		if err := rows.Scan(&database.Name, &database.CharacterSet, &database.Collation, &database.Current); err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, errScanRow)
+			return
+		}
+		if !session.databases.allows(database.Name) {
+			continue
+		}
+		databases = append(databases, database)
+	}
+	if err := rows.Err(); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, errRows)
+		return
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusOK, databases)
+}
+
+// @Summary Switch Database
+// @Description Makes another schema the active database of the session, so the routes without a {schema} segment work on it. The connection is opened with the login credentials.
+// @Tags Database
+// @Produce json
+// @Param schema path string true "Database name" default(crudder_db_test)
+// @Success 200 {object} map[string]string "Active database changed"
+// @Failure 400 {object} map[string]string "Invalid database name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Database not found or not accessible"
+// @Router /databases/{schema}/use [post]
+func (app *App) useDatabaseHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["schema"]
+	if !isAlphaNumeric(name) {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	id, ok := app.sessionID(r)
+	if !ok {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	session := app.getSession(r)
+	if session == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	database, err := session.database(name)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusNotFound, errDBNotFound)
+		log.Println("error opening database", name, err)
+		return
+	}
+
+	database.touch(session.seen())
+	if err := app.SessionStore.Save(id, database); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
+		log.Println("error saving session:", err)
+		return
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusOK, map[string]string{errMessage: "Active database changed", "database": name})
+}
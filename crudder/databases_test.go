@@ -0,0 +1,159 @@
+package crudder
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDatabasesTestApp returns an app whose session was opened on crudder_db_test and
+// whose connections to other schemas are served by other
+func newDatabasesTestApp(t *testing.T, other *sql.DB, pingErr error) (*App, sqlmock.Sqlmock, *[]string) {
+	t.Helper()
+
+	app, mock := newMockApp(t)
+	config := mysql.NewConfig()
+	config.User, config.Passwd, config.Net, config.Addr, config.DBName = "u", "p", "tcp", "db:3306", "crudder_db_test"
+	newSessionDatabases(storedSession(app, "mockSession"), config, nil)
+
+	dsns := []string{}
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+	sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+		dsns = append(dsns, dataSourceName)
+		if pingErr != nil {
+			db, pingMock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			require.NoError(t, err)
+			pingMock.ExpectPing().WillReturnError(pingErr)
+			return db, nil
+		}
+		return other, nil
+	}
+	return app, mock, &dsns
+}
+
+func TestListDatabasesHandler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT SCHEMA_NAME, .* FROM information_schema\.schemata`).WillReturnRows(
+			sqlmock.NewRows([]string{"SCHEMA_NAME", "DEFAULT_CHARACTER_SET_NAME", "DEFAULT_COLLATION_NAME", "CURRENT"}).
+				AddRow("crudder_db_staging", "utf8mb4", "utf8mb4_0900_ai_ci", 0).
+				AddRow("crudder_db_test", "utf8mb4", "utf8mb4_0900_ai_ci", 1))
+
+		w := httptest.NewRecorder()
+		app.listDatabasesHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/databases"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[
+			{"name":"crudder_db_staging","character_set":"utf8mb4","collation":"utf8mb4_0900_ai_ci","current":false},
+			{"name":"crudder_db_test","character_set":"utf8mb4","collation":"utf8mb4_0900_ai_ci","current":true}
+		]`, w.Body.String())
+	})
+
+	t.Run("Query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`FROM information_schema\.schemata`).WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.listDatabasesHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/databases"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.listDatabasesHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/databases"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestUseDatabaseHandler(t *testing.T) {
+	useRequest := func(schema string) *http.Request {
+		return mux.SetURLVars(newMigrationRequest(http.MethodPost, "/api/v1/databases/"+schema+"/use"), map[string]string{"schema": schema})
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		other, _, err := sqlmock.New()
+		require.NoError(t, err)
+		defer other.Close()
+		app, _, dsns := newDatabasesTestApp(t, other, nil)
+		original := storedSession(app, "mockSession")
+
+		w := httptest.NewRecorder()
+		app.useDatabaseHandler(w, useRequest("crudder_db_staging"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"message":"Active database changed","database":"crudder_db_staging"}`, w.Body.String())
+		assert.Equal(t, []string{"u:p@tcp(db:3306)/crudder_db_staging"}, *dsns)
+		assert.Same(t, other, storedSession(app, "mockSession").DB)
+
+		// switching back reuses the connection of the login
+		w = httptest.NewRecorder()
+		app.useDatabaseHandler(w, useRequest("crudder_db_test"))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Same(t, original, storedSession(app, "mockSession"))
+		assert.Len(t, *dsns, 1)
+	})
+
+	t.Run("Database not accessible", func(t *testing.T) {
+		app, _, _ := newDatabasesTestApp(t, nil, errors.New("Error 1044: Access denied"))
+
+		w := httptest.NewRecorder()
+		app.useDatabaseHandler(w, useRequest("mysql"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"message":"Database not found or not accessible"}`, w.Body.String())
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.useDatabaseHandler(w, useRequest("bad$name"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.useDatabaseHandler(w, useRequest("crudder_db_test"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestSchemaCrudRoutes(t *testing.T) {
+	other, otherMock, err := sqlmock.New()
+	require.NoError(t, err)
+	app, mock, _ := newDatabasesTestApp(t, other, nil)
+
+	otherMock.ExpectQuery(`SELECT COLUMN_NAME FROM information_schema\.KEY_COLUMN_USAGE`).WithArgs("orders").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("order_id"))
+	otherMock.ExpectQuery("SELECT \\* FROM `orders` WHERE `order_id` = \\?").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "total"}).AddRow(7, "9.90"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/databases/crudder_db_staging/crud/orders/7", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"order_id":7,"total":"9.90"}`, w.Body.String())
+	assert.NoError(t, otherMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// logout closes the connections of every schema
+	mock.ExpectClose()
+	otherMock.ExpectClose()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	req.Header.Set(csrfHeader, csrfToken("mockSession"))
+	app.logoutHandler(httptest.NewRecorder(), req)
+	assert.NoError(t, otherMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,12 +3,15 @@ package crudder
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -16,25 +19,125 @@ import (
 // struct to store the connection of each user session
 type SessionData struct {
 	DB *sql.DB
+
+	dialect   Dialect           // SQL dialect of the engine chosen at login, MySQL when nil
+	schema    schemaCache       // table metadata used by the CRUD routes
+	databases *sessionDatabases // connections of the same login to other schemas
+	login     *sessionLogin     // what a persistent SessionStore needs to reopen the session
+	apiKey    *apiKey           // set when the session is an API key, see /tokens
+	created   time.Time         // login time, for SESSION_MAX_AGE
+	lastSeen  atomic.Int64      // last authenticated request in unix nanoseconds, for SESSION_IDLE_TIMEOUT
+}
+
+// struct represents a table or view of the current schema
+type TableInfo struct {
+	TableName    string  `json:"table_name"`
+	TableType    string  `json:"table_type"`
+	Engine       *string `json:"engine,omitempty"`
+	RowEstimate  *int64  `json:"row_estimate,omitempty"`
+	TableComment string  `json:"table_comment"`
 }
 
 // struct represents a colum data
 type ColumnInfo struct {
-	ColumnName       string  `json:"column_name"`
-	DataType         string  `json:"data_type"`
-	IsNullable       bool    `json:"is_nullable"`
-	ColumnDefault    *string `json:"column_default,omitempty"`
-	IsPrimaryKey     bool    `json:"is_primary_key"`
-	ForeignKey       *string `json:"foreign_key,omitempty"`
-	ReferencedTable  *string `json:"referenced_table,omitempty"`
-	ReferencedColumn *string `json:"referenced_column,omitempty"`
+	ColumnName           string   `json:"column_name"`
+	DataType             string   `json:"data_type"`
+	ColumnType           string   `json:"column_type"`
+	EnumValues           []string `json:"enum_values,omitempty"`
+	IsNullable           bool     `json:"is_nullable"`
+	ColumnDefault        *string  `json:"column_default,omitempty"`
+	CharacterMaxLength   *int64   `json:"character_maximum_length,omitempty"`
+	NumericPrecision     *int64   `json:"numeric_precision,omitempty"`
+	NumericScale         *int64   `json:"numeric_scale,omitempty"`
+	IsAutoIncrement      bool     `json:"is_auto_increment"`
+	Extra                string   `json:"extra"`
+	GenerationExpression *string  `json:"generation_expression,omitempty"`
+	Collation            *string  `json:"collation,omitempty"`
+	ColumnComment        string   `json:"column_comment"`
+	IsPrimaryKey         bool     `json:"is_primary_key"`
+	ForeignKey           *string  `json:"foreign_key,omitempty"`
+	ReferencedTable      *string  `json:"referenced_table,omitempty"`
+	ReferencedColumn     *string  `json:"referenced_column,omitempty"`
+}
+
+// tableColumnsQuery reads the columns of a table with their primary and foreign keys
+const tableColumnsQuery = `
+        SELECT 
+            c.COLUMN_NAME, 
+            c.DATA_TYPE, 
+            c.IS_NULLABLE, 
+            c.COLUMN_DEFAULT,
+            EXISTS (
+                SELECT 1 FROM information_schema.key_column_usage AS pk
+                WHERE pk.TABLE_SCHEMA = c.TABLE_SCHEMA
+                  AND pk.TABLE_NAME = c.TABLE_NAME
+                  AND pk.COLUMN_NAME = c.COLUMN_NAME
+                  AND pk.CONSTRAINT_NAME = 'PRIMARY'
+            ) AS IS_PRIMARY_KEY,
+            fk.REFERENCED_TABLE_NAME,
+            fk.REFERENCED_COLUMN_NAME,
+            c.COLUMN_TYPE,
+            c.CHARACTER_MAXIMUM_LENGTH,
+            c.NUMERIC_PRECISION,
+            c.NUMERIC_SCALE,
+            c.EXTRA,
+            c.GENERATION_EXPRESSION,
+            c.COLLATION_NAME,
+            c.COLUMN_COMMENT
+        FROM information_schema.columns AS c
+        LEFT JOIN information_schema.key_column_usage AS fk
+        ON fk.TABLE_SCHEMA = c.TABLE_SCHEMA
+           AND fk.TABLE_NAME = c.TABLE_NAME
+           AND fk.COLUMN_NAME = c.COLUMN_NAME
+           AND fk.CONSTRAINT_NAME = (
+               -- one row per column, even when it takes part in several keys
+               SELECT MIN(k.CONSTRAINT_NAME) FROM information_schema.key_column_usage AS k
+               WHERE k.TABLE_SCHEMA = c.TABLE_SCHEMA
+                 AND k.TABLE_NAME = c.TABLE_NAME
+                 AND k.COLUMN_NAME = c.COLUMN_NAME
+                 AND k.REFERENCED_TABLE_NAME IS NOT NULL
+           )
+        WHERE c.table_schema = DATABASE() AND c.table_name = ?
+        ORDER BY c.ORDINAL_POSITION
+    `
+
+// errReadingTables is returned by fetchTableList when a row of the list cannot be read
+var errReadingTables = errors.New("error reading result")
+
+// fetchTableList reads the tables and views of the current schema
+func fetchTableList(db *sql.DB, d Dialect) ([]TableInfo, error) {
+	rows, err := db.Query(d.TablesQuery())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []TableInfo{}
+	for rows.Next() {
+		var table TableInfo
+		var engine sql.NullString
+		var rowEstimate sql.NullInt64
+		var comment sql.NullString
+		if err := rows.Scan(&table.TableName, &table.TableType, &engine, &rowEstimate, &comment); err != nil {
+			return nil, fmt.Errorf("%w: %v", errReadingTables, err)
+		}
+		if engine.Valid {
+			table.Engine = &engine.String
+		}
+		if rowEstimate.Valid {
+			table.RowEstimate = &rowEstimate.Int64
+		}
+		table.TableComment = comment.String
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
 }
 
 // @Summary List Tables
-// @Description Retrieves the names of all tables in the current database schema. Requires a valid database connection from the context.
+// @Description Retrieves all tables and views in the current database schema, with their type (BASE TABLE or VIEW), engine, estimated row count and comment. Requires a valid database connection from the context.
 // @Tags Database
 // @Produce json
-// @Success 200 {array} string "List of table names"
+// @Success 200 {array} TableInfo "List of tables"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /tables [get]
@@ -52,37 +155,47 @@ func (app *App) listTablesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Executa a consulta para listar as tabelas
-	rows, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()")
+	// Lista as tabelas, do cache da sessão quando possível
+	var all []TableInfo
+	var err error
+	session := app.getSession(r)
+	if session != nil {
+		all, err = session.schema.tableInfos(db, app.getDialect(r))
+	} else {
+		all, err = fetchTableList(db, app.getDialect(r))
+	}
+	if errors.Is(err, errReadingTables) {
+		http.Error(w, errReadingTables.Error(), http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
 	if err != nil {
 		http.Error(w, "error fetching tables", http.StatusInternalServerError)
 		log.Println("error fetching tables:", err)
 		return
 	}
-	defer rows.Close()
 
-	var tables []string
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			http.Error(w, "error reading result", http.StatusInternalServerError)
-			log.Println("error reading result:", err)
-			return
+	tables := []TableInfo{}
+	for _, table := range all {
+		// tables hidden by POLICY_FILE are left out
+		if !app.tableAccess(session, table.TableName).visible() {
+			continue
 		}
-		tables = append(tables, tableName)
+		tables = append(tables, table)
 	}
 	w.Header().Set(headerContentType, headerContentTypeJSON)
 	json.NewEncoder(w).Encode(tables)
 }
 
 // @Summary Get Table Structure
-// @Description Handler for retrieving the structure of a specific table, including primary and foreign keys.
+// @Description Handler for retrieving the structure of a specific table, including primary and foreign keys, full column types (with enum/set members), length and numeric limits, auto_increment and generated columns, collation and comments.
 // @Tags Database
 // @Produce json
 // @Param table query string true "Table name" default(users)
 // @Success 200 {array} ColumnInfo "Table structure retrieved successfully"
 // @Failure 400 {object} map[string]string "The parameter 'table' is mandatory"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Table not found"
 // @Failure 500 {object} map[string]string "Error querying table structure or processing result"
 // @Router /table-structure [get]
 func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
@@ -93,37 +206,18 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// retrieve db connection
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	sessionData := app.getSession(r)
+	if sessionData == nil {
 		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
-	app.Mutex.Lock()
-	sessionData, exists := app.SessionStore[cookie.Value]
-	app.Mutex.Unlock()
-	if !exists {
-		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+	access := app.tableAccess(sessionData, tableName)
+	if !access.visible() {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
 		return
 	}
 
-	// Query the table structure with primary and foreign keys
-	query := `
-        SELECT 
-            c.COLUMN_NAME, 
-            c.DATA_TYPE, 
-            c.IS_NULLABLE, 
-            c.COLUMN_DEFAULT,
-            IF(k.CONSTRAINT_NAME = 'PRIMARY', TRUE, FALSE) AS IS_PRIMARY_KEY,
-            k.REFERENCED_TABLE_NAME,
-            k.REFERENCED_COLUMN_NAME
-        FROM information_schema.columns AS c
-        LEFT JOIN information_schema.key_column_usage AS k
-        ON c.TABLE_NAME = k.TABLE_NAME 
-           AND c.COLUMN_NAME = k.COLUMN_NAME 
-           AND k.TABLE_SCHEMA = DATABASE()
-        WHERE c.table_schema = DATABASE() AND c.table_name = ?
-    `
-	rows, err := sessionData.DB.Query(query, tableName)
+	rows, err := sessionData.DB.Query(sessionData.Dialect().ColumnsQuery(), tableName)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table structure")
 		log.Println("Erro ao consultar estrutura:", err)
@@ -131,6 +225,31 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	columns, err := scanColumns(rows)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error processing result")
+		log.Println("Error processing result:", err)
+		return
+	}
+	columns = access.visibleColumns(columns)
+
+	// Retorna a estrutura da tabela em formato JSON
+	w.Header().Set(headerContentType, headerContentTypeJSON)
+	json.NewEncoder(w).Encode(columns)
+}
+
+// fetchTableColumns returns the columns of a table in the current schema, as served by /table-structure
+func fetchTableColumns(db *sql.DB, d Dialect, tableName string) ([]ColumnInfo, error) {
+	rows, err := db.Query(d.ColumnsQuery(), tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanColumns(rows)
+}
+
+// scanColumns reads the rows of Dialect.ColumnsQuery
+func scanColumns(rows *sql.Rows) ([]ColumnInfo, error) {
 	var columns []ColumnInfo
 	for rows.Next() {
 		var col ColumnInfo
@@ -139,11 +258,12 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 		var referencedTable sql.NullString
 		var referencedColumn sql.NullString
 		var isPrimaryKey bool
+		var maxLength, precision, scale sql.NullInt64
+		var generationExpression, collation sql.NullString
 
-		if err := rows.Scan(&col.ColumnName, &col.DataType, &isNullableStr, &columnDefault, &isPrimaryKey, &referencedTable, &referencedColumn); err != nil {
-			WriteErrorResponse(w, http.StatusInternalServerError, "Error processing result")
-			log.Println("Error processing result:", err)
-			return
+		if err := rows.Scan(&col.ColumnName, &col.DataType, &isNullableStr, &columnDefault, &isPrimaryKey, &referencedTable, &referencedColumn,
+			&col.ColumnType, &maxLength, &precision, &scale, &col.Extra, &generationExpression, &collation, &col.ColumnComment); err != nil {
+			return nil, err
 		}
 		// convert isNullableStr ("YES"/"NO") para bool
 		col.IsNullable = isNullableStr == "YES"
@@ -159,6 +279,9 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 			col.ColumnDefault = nil
 		}
 
+		// type details used by the forms to render inputs and limits
+		setColumnDetails(&col, maxLength, precision, scale, generationExpression, collation)
+
 		// converts sql.NullString to *string para ForeignKey
 		if !referencedTable.Valid || !referencedColumn.Valid {
 			col.ReferencedTable = nil
@@ -174,9 +297,58 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 		columns = append(columns, col)
 	}
 
-	// Retorna a estrutura da tabela em formato JSON
-	w.Header().Set(headerContentType, headerContentTypeJSON)
-	json.NewEncoder(w).Encode(columns)
+	return columns, rows.Err()
+}
+
+// setColumnDetails fills the type details of a column read from information_schema.columns
+func setColumnDetails(col *ColumnInfo, maxLength, precision, scale sql.NullInt64, generationExpression, collation sql.NullString) {
+	col.EnumValues = parseEnumValues(col.ColumnType)
+	col.IsAutoIncrement = strings.Contains(strings.ToLower(col.Extra), "auto_increment")
+	if maxLength.Valid {
+		col.CharacterMaxLength = &maxLength.Int64
+	}
+	if precision.Valid {
+		col.NumericPrecision = &precision.Int64
+	}
+	if scale.Valid {
+		col.NumericScale = &scale.Int64
+	}
+	if generationExpression.Valid && generationExpression.String != "" {
+		col.GenerationExpression = &generationExpression.String
+	}
+	if collation.Valid {
+		col.Collation = &collation.String
+	}
+}
+
+// parseEnumValues extracts the members of an enum('a','b') or set('a','b') COLUMN_TYPE
+func parseEnumValues(columnType string) []string {
+	lower := strings.ToLower(columnType)
+	if !(strings.HasPrefix(lower, "enum(") || strings.HasPrefix(lower, "set(")) || !strings.HasSuffix(columnType, ")") {
+		return nil
+	}
+	list := columnType[strings.Index(columnType, "(")+1 : len(columnType)-1]
+
+	values := []string{}
+	var current strings.Builder
+	inQuote := false
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case c == '\'' && inQuote && i+1 < len(list) && list[i+1] == '\'':
+			current.WriteByte('\'')
+			i++
+		case c == '\'':
+			inQuote = !inQuote
+			if !inQuote {
+				values = append(values, current.String())
+				current.Reset()
+			}
+		case inQuote:
+			current.WriteByte(c)
+		}
+	}
+	return values
 }
 
 // @Summary Update Record
@@ -188,20 +360,36 @@ func (app *App) tableStructureHandler(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "ID of the record to update" default(3)
 // @Param body body object true "JSON object with updated fields" example({"username": "user3changed","pwd": "456456"})
 // @Success 200 {object} map[string]interface{} "Record updated successfully"
-// @Failure 400 {string} string "Invalid input or JSON decoding error"
+// @Failure 400 {object} ValidationErrorResponse "Invalid input, JSON decoding error or body not matching the table schema"
 // @Failure 401 {string} string "Unauthorized"
-// @Failure 404 {string} string "Record not found"
+// @Failure 404 {string} string "Table or record not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /crud/{table}/{id} [put]
 func (app *App) updateRecord(w http.ResponseWriter, r *http.Request, tableName string, id int) {
 	var item map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+	// numbers are kept as written, so BIGINT and DECIMAL values do not go through float64
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&item); err != nil {
 		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
 		return
 	}
 
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
+		return
+	}
+	db, d := session.DB, session.Dialect()
+
+	if !authorizeColumns(w, app.tableAccess(session, tableName), item) {
+		return
+	}
+	if !validateRecord(w, session, tableName, item, true) {
+		return
+	}
+	numberValues(item)
+	filter, ok := app.rowFilter(w, session, tableName, opUpdate)
+	if !ok {
 		return
 	}
 
@@ -212,18 +400,19 @@ func (app *App) updateRecord(w http.ResponseWriter, r *http.Request, tableName s
 		return
 	}
 
-	columns := make([]string, 0, len(item))
-	values := make([]interface{}, 0, len(item))
+	// only rows the user can see are updated, and they must stay visible afterwards
+	query, values := updateQuery(d, tableName, primaryKey, item, id, filter)
 
-	for col, val := range item {
-		columns = append(columns, fmt.Sprintf("%s = ?", col))
-		values = append(values, val)
+	q, tx, err := beginFiltered(db, filter)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error updating Item: %v", err))
+		return
+	}
+	if tx != nil {
+		defer tx.Rollback()
 	}
 
-	values = append(values, id)
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", tableName, strings.Join(columns, ", "), primaryKey)
-
-	result, err := db.Exec(query, values...)
+	result, err := q.Exec(query, values...)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error updating Item: %v", err))
 		return
@@ -234,12 +423,48 @@ func (app *App) updateRecord(w http.ResponseWriter, r *http.Request, tableName s
 		WriteErrorResponse(w, http.StatusNotFound, "Record not found or not updated")
 		return
 	}
+	if !commitFiltered(w, tx, filter, d, tableName, primaryKey, id) {
+		return
+	}
 
 	item[primaryKey] = id
 	w.Header().Set(headerContentType, headerContentTypeJSON)
 	json.NewEncoder(w).Encode(item)
 }
 
+// numberValues passes the integers of a body decoded with UseNumber to the driver as
+// int64, and the other numbers as their text, which the database reads without the
+// rounding of float64
+func numberValues(item map[string]interface{}) {
+	for col, value := range item {
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				item[col] = i
+			} else {
+				item[col] = n.String()
+			}
+		}
+	}
+}
+
+// updateQuery builds the UPDATE of the row whose primary key is id with the columns of
+// item, limited to the rows filter lets through
+func updateQuery(d Dialect, tableName, primaryKey string, item map[string]interface{}, id interface{}, filter *rowFilter) (string, []interface{}) {
+	columns := make([]string, 0, len(item))
+	values := make([]interface{}, 0, len(item)+1)
+
+	for col, val := range item {
+		values = append(values, val)
+		columns = append(columns, fmt.Sprintf("%s = %s", d.QuoteIdentifier(col), d.Placeholder(len(values))))
+	}
+
+	values = append(values, id)
+	clause, filterArgs := filter.andClause(d, len(values))
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s%s", d.QuoteIdentifier(tableName), strings.Join(columns, ", "),
+		d.QuoteIdentifier(primaryKey), d.Placeholder(len(values)), clause)
+	return query, append(values, filterArgs...)
+}
+
 // @Summary Delete Record
 // @Description Deletes a record in the specified table based on the provided ID. This endpoint requires a valid session token.
 // @Tags CRUD
@@ -252,11 +477,12 @@ func (app *App) updateRecord(w http.ResponseWriter, r *http.Request, tableName s
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /crud/{table}/{id} [delete]
 func (app *App) deleteRecord(w http.ResponseWriter, r *http.Request, tableName string, id int) {
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
 		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
 		return
 	}
+	db, d := session.DB, session.Dialect()
 
 	// Obter a coluna de chave primária
 	primaryKey, err := app.getPrimaryKey(r, tableName)
@@ -265,8 +491,13 @@ func (app *App) deleteRecord(w http.ResponseWriter, r *http.Request, tableName s
 		return
 	}
 
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", tableName, primaryKey)
-	result, err := db.Exec(query, id)
+	filter, ok := app.rowFilter(w, session, tableName, opDelete)
+	if !ok {
+		return
+	}
+	clause, filterArgs := filter.andClause(d, 1)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s%s", d.QuoteIdentifier(tableName), d.QuoteIdentifier(primaryKey), d.Placeholder(1), clause)
+	result, err := db.Exec(query, append([]interface{}{id}, filterArgs...)...)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error deleting item: %v", err))
 		return
@@ -295,22 +526,46 @@ func (app *App) deleteRecord(w http.ResponseWriter, r *http.Request, tableName s
 // @Param table path string true "Name of the table" default(users)
 // @Param body body object true "JSON object for the new record" example({"username": "newuser","pwd": "789789"})
 // @Success 201 {object} map[string]interface{} "Record created successfully"
-// @Failure 400 {string} string "Invalid input or JSON decoding error"
+// @Failure 400 {object} ValidationErrorResponse "Invalid input, JSON decoding error or body not matching the table schema"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Table not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /crud/{table} [post]
 func (app *App) createRecord(w http.ResponseWriter, r *http.Request, tableName string) {
 	var item map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+	// numbers are kept as written, so BIGINT and DECIMAL values do not go through float64
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&item); err != nil {
 		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
 		return
 	}
 
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
 		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
 		return
 	}
+	db, d := session.DB, session.Dialect()
+
+	if !authorizeColumns(w, app.tableAccess(session, tableName), item) {
+		return
+	}
+	if !validateRecord(w, session, tableName, item, false) {
+		return
+	}
+	numberValues(item)
+	filter, ok := app.rowFilter(w, session, tableName, opCreate)
+	if !ok {
+		return
+	}
+
+	// the key is needed to read the generated value back and to upsert on it
+	primaryKey, err := app.getPrimaryKey(r, tableName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errFindPrimaryKey, err))
+		return
+	}
 
 	keys := make([]string, 0, len(item))
 	for col := range item {
@@ -322,27 +577,90 @@ func (app *App) createRecord(w http.ResponseWriter, r *http.Request, tableName s
 	values := make([]interface{}, 0, len(item))
 	placeholders := make([]string, 0, len(item))
 
-	for _, col := range keys {
-		columns = append(columns, col)
+	for i, col := range keys {
+		columns = append(columns, d.QuoteIdentifier(col))
 		values = append(values, item[col])
-		placeholders = append(placeholders, "?")
+		placeholders = append(placeholders, d.Placeholder(i+1))
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, strings.Join(columns, ","), strings.Join(placeholders, ","))
-	result, err := db.Exec(query, values...)
+	upsert := r.URL.Query().Get("upsert") == "true"
+	var updateFilter *rowFilter
+	if upsert {
+		if updateFilter, ok = app.rowFilter(w, session, tableName, opUpdate); !ok {
+			return
+		}
+	}
+	// ON CONFLICT and ON DUPLICATE KEY could update a row outside the update filter, on
+	// MySQL even through another unique key, so a filtered upsert needs the key and is
+	// run as an UPDATE of that row or a plain INSERT
+	key, sent := item[primaryKey]
+	if updateFilter != nil && !sent {
+		WriteErrorResponse(w, http.StatusForbidden, errRowDenied)
+		return
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.QuoteIdentifier(tableName), strings.Join(columns, ","), strings.Join(placeholders, ","))
+	if upsert && updateFilter == nil {
+		query += d.UpsertClause(keys, primaryKey)
+	}
+
+	// the new row is checked against the row filter before it is committed
+	q, tx, err := beginFiltered(db, filter, updateFilter)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, "Error inserting Item")
 		return
 	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
 
-	id, _ := result.LastInsertId()
-	primaryKey, err := app.getPrimaryKey(r, tableName)
+	// an upsert must not take over a row the user cannot update
+	if updateFilter != nil {
+		found, match, err := updateFilter.matches(q, d, tableName, primaryKey, key)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, "Error checking the row filter")
+			log.Println("error checking row filter:", err)
+			return
+		}
+		if found && !match {
+			WriteErrorResponse(w, http.StatusForbidden, errRowDenied)
+			return
+		}
+		if found {
+			query, values := updateQuery(d, tableName, primaryKey, item, key, updateFilter)
+			if _, err := q.Exec(query, values...); err != nil {
+				WriteErrorResponse(w, http.StatusInternalServerError, "Error inserting Item")
+				return
+			}
+			if !commitFiltered(w, tx, updateFilter, d, tableName, primaryKey, key) {
+				return
+			}
+			writeJSONResponseWithStatus(w, http.StatusOK, item)
+			return
+		}
+	}
+
+	var id interface{}
+	if returning := d.Returning(primaryKey); returning != "" {
+		err = q.QueryRow(query+returning, values...).Scan(&id)
+	} else {
+		var result sql.Result
+		if result, err = q.Exec(query, values...); err == nil {
+			id, _ = result.LastInsertId()
+		}
+	}
 	if err != nil {
-		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errFindPrimaryKey, err))
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error inserting Item")
 		return
 	}
 
-	item[primaryKey] = id
+	// a key sent in the body, as for an upsert, is kept as is
+	if _, sent := item[primaryKey]; !sent {
+		item[primaryKey] = id
+	}
+	if !commitFiltered(w, tx, filter, d, tableName, primaryKey, item[primaryKey]) {
+		return
+	}
 
 	writeJSONResponseWithStatus(w, http.StatusOK, item)
 
@@ -350,14 +668,14 @@ func (app *App) createRecord(w http.ResponseWriter, r *http.Request, tableName s
 
 // read all records
 func (app *App) readRecord(w http.ResponseWriter, r *http.Request, tableName string) {
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
 		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
 		return
 	}
 
 	// read all records
-	app.readAllRecords(w, db, tableName)
+	app.readAllRecords(w, session, tableName)
 }
 
 // @Summary Retrieve a Record by ID
@@ -373,11 +691,12 @@ func (app *App) readRecord(w http.ResponseWriter, r *http.Request, tableName str
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /crud/{table}/{id} [get]
 func (app *App) readRecordByID(w http.ResponseWriter, r *http.Request, tableName string, id int) {
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
 		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
 		return
 	}
+	db, d := session.DB, session.Dialect()
 
 	primaryKey, err := app.getPrimaryKey(r, tableName)
 	if err != nil {
@@ -385,8 +704,13 @@ func (app *App) readRecordByID(w http.ResponseWriter, r *http.Request, tableName
 		return
 	}
 
-	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", tableName, primaryKey)
-	rows, err := db.Query(query, id)
+	filter, ok := app.rowFilter(w, session, tableName, opRead)
+	if !ok {
+		return
+	}
+	clause, filterArgs := filter.andClause(d, 1)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = %s%s", d.QuoteIdentifier(tableName), d.QuoteIdentifier(primaryKey), d.Placeholder(1), clause)
+	rows, err := db.Query(query, append([]interface{}{id}, filterArgs...)...)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error querying the database: %s", err.Error()))
 		return
@@ -421,6 +745,7 @@ func (app *App) readRecordByID(w http.ResponseWriter, r *http.Request, tableName
 				item[col] = v
 			}
 		}
+		app.tableAccess(session, tableName).hideColumns(item)
 
 		writeJSONResponseWithStatus(w, http.StatusOK, item)
 		return
@@ -442,15 +767,30 @@ func (app *App) readRecordByID(w http.ResponseWriter, r *http.Request, tableName
 // @Failure 401 {object} map[string]string "Unauthorized or session not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /crud/{tableName} [get]
-func (app *App) readAllRecords(w http.ResponseWriter, db *sql.DB, tableName string) {
-	query := fmt.Sprintf("SELECT * FROM %s", tableName)
-	rows, err := db.Query(query)
+func (app *App) readAllRecords(w http.ResponseWriter, session *SessionData, tableName string) {
+	filter, ok := app.rowFilter(w, session, tableName, opRead)
+	if !ok {
+		return
+	}
+	query := fmt.Sprintf("SELECT * FROM %s", session.Dialect().QuoteIdentifier(tableName))
+	var args []interface{}
+	if filter != nil {
+		query += " WHERE " + filter.sql(session.Dialect(), 1)
+		args = filter.args
+	}
+	rows, err := session.DB.Query(query, args...)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, errqryAllRecords)
 		return
 	}
 	defer rows.Close()
 
+	writeRecords(w, rows, app.tableAccess(session, tableName))
+}
+
+// writeRecords serializes every row of the result set as a JSON array of objects,
+// without the columns access hides
+func writeRecords(w http.ResponseWriter, rows *sql.Rows, access *tableAccess) {
 	columns, err := rows.Columns()
 	if err != nil {
 		WriteErrorResponse(w, http.StatusInternalServerError, errColumnNotFound)
@@ -464,6 +804,7 @@ func (app *App) readAllRecords(w http.ResponseWriter, db *sql.DB, tableName stri
 			WriteErrorResponse(w, http.StatusInternalServerError, errRecords) // Handle row processing errors
 			return
 		}
+		access.hideColumns(item)
 		items = append(items, item)
 	}
 
@@ -515,43 +856,49 @@ func processRowWithColumns(rows *sql.Rows, columns []string) (map[string]interfa
 
 // Function to obtain the primary key column of a table
 func (app *App) getPrimaryKey(r *http.Request, tableName string) (string, error) {
-	db := app.getDBFromSession(r)
-	if db == nil {
+	session := app.getSession(r)
+	if session == nil {
 		return "", fmt.Errorf("conexão ao banco de dados não disponível")
 	}
 
-	query := `
-        SELECT COLUMN_NAME
-        FROM information_schema.KEY_COLUMN_USAGE
-        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
-    `
+	return session.schema.primaryKey(session.DB, session.Dialect(), tableName)
+}
 
-	var primaryKey string
-	err := db.QueryRow(query, tableName).Scan(&primaryKey)
-	if err != nil {
-		return "", fmt.Errorf(errFindPrimaryKey, err)
+// Helper function to get the session of the request
+func (app *App) getSession(r *http.Request) *SessionData {
+	if session, ok := r.Context().Value(sessionKey).(*SessionData); ok {
+		return session
 	}
 
-	return primaryKey, nil
-}
-
-// Helper function to get database connection from user session
-func (app *App) getDBFromSession(r *http.Request) *sql.DB {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		log.Println("Erro getting cookie:", err)
+	id, ok := app.sessionID(r)
+	if !ok {
+		log.Println("Erro getting cookie: no session token")
 		return nil
 	}
 
-	app.Mutex.Lock()
-	sessionData, exists := app.SessionStore[cookie.Value]
-	app.Mutex.Unlock()
+	sessionData, exists := app.SessionStore.Get(id)
 	if !exists {
-		log.Println("Sesssion not found to token:", cookie.Value)
+		log.Println("Sesssion not found to token hash:", id)
 		return nil
 	}
 
-	return sessionData.DB
+	return sessionData
+}
+
+// Helper function to get the SQL dialect of the request's session
+func (app *App) getDialect(r *http.Request) Dialect {
+	if sessionData := app.getSession(r); sessionData != nil {
+		return sessionData.Dialect()
+	}
+	return mysqlDialect{}
+}
+
+// Helper function to get database connection from user session
+func (app *App) getDBFromSession(r *http.Request) *sql.DB {
+	if sessionData := app.getSession(r); sessionData != nil {
+		return sessionData.DB
+	}
+	return nil
 }
 
 // crud endpoint switcher
@@ -566,6 +913,9 @@ func (app *App) crudHandler(w http.ResponseWriter, r *http.Request) {
 		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
 		return
 	}
+	if !app.authorizeCrud(w, r, tableName) {
+		return
+	}
 
 	switch r.Method {
 	case "POST":
@@ -39,12 +39,29 @@ func (r *columnsErrorRows) Columns() ([]string, error) {
 	return nil, fmt.Errorf("mock columns error")
 }
 
+// columns returned by the tableStructureHandler query
+var tableStructureColumns = []string{
+	"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT",
+	"IS_PRIMARY_KEY", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME",
+	"COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE",
+	"EXTRA", "GENERATION_EXPRESSION", "COLLATION_NAME", "COLUMN_COMMENT",
+}
+
 type errorValue struct{}
 
 func (e *errorValue) Scan(src interface{}) error {
 	return fmt.Errorf("mock scan error")
 }
 
+// usersColumnRows mocks the columns of the users table used by the create and update tests
+func usersColumnRows() *sqlmock.Rows {
+	return sqlmock.NewRows(tableStructureColumns).
+		AddRow("id", "int", "NO", nil, true, nil, nil, "int", nil, 10, 0, "auto_increment", "", nil, "").
+		AddRow("name", "varchar", "YES", nil, false, nil, nil, "varchar(100)", 100, nil, nil, "", "", nil, "").
+		AddRow("first_name", "varchar", "NO", nil, false, nil, nil, "varchar(50)", 50, nil, nil, "", "", nil, "").
+		AddRow("last_name", "varchar", "NO", nil, false, nil, nil, "varchar(50)", 50, nil, nil, "", "", nil, "")
+}
+
 func TestListTablesHandler(t *testing.T) {
 	// Configura o mock do banco de dados
 	db, mock, err := sqlmock.New()
@@ -54,8 +71,10 @@ func TestListTablesHandler(t *testing.T) {
 	defer db.Close()
 
 	// Configura o mock para simular uma consulta com sucesso
-	mock.ExpectQuery("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()").
-		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("users").AddRow("orders"))
+	mock.ExpectQuery(`SELECT table_name, table_type, engine, table_rows, table_comment FROM information_schema\.tables WHERE table_schema = DATABASE\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "table_type", "engine", "table_rows", "table_comment"}).
+			AddRow("users", "BASE TABLE", "InnoDB", 3, "application users").
+			AddRow("orders", "VIEW", nil, nil, "VIEW"))
 
 	// Configura o App
 	app := &App{}
@@ -76,16 +95,11 @@ func TestListTablesHandler(t *testing.T) {
 	}
 
 	// Parseia os valores esperados e obtidos como JSON
-	expected := []string{"users", "orders"}
-	var obtained []string
-	if err := json.Unmarshal(w.Body.Bytes(), &obtained); err != nil {
-		t.Fatalf("Erro ao parsear JSON da resposta: %v", err)
-	}
-
-	// Compara as estruturas
-	if !equalSlices(expected, obtained) {
-		t.Errorf("Esperado %v, obtido %v", expected, obtained)
-	}
+	expected := `[
+		{"table_name":"users","table_type":"BASE TABLE","engine":"InnoDB","row_estimate":3,"table_comment":"application users"},
+		{"table_name":"orders","table_type":"VIEW","table_comment":"VIEW"}
+	]`
+	assert.JSONEq(t, expected, w.Body.String())
 
 	// Verifica se todas as expectativas do mock foram atendidas
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -102,12 +116,13 @@ func TestTableStructureHandler(t *testing.T) {
 
 	mock.ExpectQuery("SELECT c.COLUMN_NAME, c.DATA_TYPE, c.IS_NULLABLE, c.COLUMN_DEFAULT, .* FROM information_schema.columns .*").
 		WithArgs("users").
-		WillReturnRows(sqlmock.NewRows([]string{
-			"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "IS_PRIMARY_KEY", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME",
-		}).AddRow("id", "int", "NO", nil, true, nil, nil).
-			AddRow("name", "varchar", "YES", "default_name", false, nil, nil))
+		WillReturnRows(sqlmock.NewRows(tableStructureColumns).
+			AddRow("id", "int", "NO", nil, true, nil, nil, "int unsigned", nil, 10, 0, "auto_increment", "", nil, "").
+			AddRow("name", "varchar", "YES", "default_name", false, nil, nil, "varchar(100)", 100, nil, nil, "", "", "utf8mb4_general_ci", "display name").
+			AddRow("status", "enum", "NO", "active", false, nil, nil, "enum('active','it''s off')", 9, nil, nil, "", "", "utf8mb4_general_ci", "").
+			AddRow("total", "decimal", "YES", nil, false, nil, nil, "decimal(10,2)", nil, 10, 2, "STORED GENERATED", "(`price` * `qty`)", nil, ""))
 
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 	req := httptest.NewRequest("GET", "/table-structure?table=users", nil)
 	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
 	w := httptest.NewRecorder()
@@ -117,6 +132,26 @@ func TestTableStructureHandler(t *testing.T) {
 	if w.Result().StatusCode != http.StatusOK {
 		t.Errorf("Esperado status 200, obtido %d", w.Result().StatusCode)
 	}
+
+	expected := `[
+		{"column_name":"id","data_type":"int","column_type":"int unsigned","is_nullable":false,"numeric_precision":10,"numeric_scale":0,
+		 "is_auto_increment":true,"extra":"auto_increment","column_comment":"","is_primary_key":true},
+		{"column_name":"name","data_type":"varchar","column_type":"varchar(100)","is_nullable":true,"column_default":"default_name",
+		 "character_maximum_length":100,"is_auto_increment":false,"extra":"","collation":"utf8mb4_general_ci","column_comment":"display name","is_primary_key":false},
+		{"column_name":"status","data_type":"enum","column_type":"enum('active','it''s off')","enum_values":["active","it's off"],"is_nullable":false,
+		 "column_default":"active","character_maximum_length":9,"is_auto_increment":false,"extra":"","collation":"utf8mb4_general_ci","column_comment":"","is_primary_key":false},
+		{"column_name":"total","data_type":"decimal","column_type":"decimal(10,2)","is_nullable":true,"numeric_precision":10,"numeric_scale":2,
+		 "is_auto_increment":false,"extra":"STORED GENERATED","generation_expression":"(` + "`price` * `qty`" + `)","column_comment":"","is_primary_key":false}
+	]`
+	assert.JSONEq(t, expected, w.Body.String())
+}
+
+func TestParseEnumValues(t *testing.T) {
+	assert.Equal(t, []string{"a", "b c"}, parseEnumValues("enum('a','b c')"))
+	assert.Equal(t, []string{"read", "write"}, parseEnumValues("set('read','write')"))
+	assert.Equal(t, []string{"it's", ""}, parseEnumValues("enum('it''s','')"))
+	assert.Nil(t, parseEnumValues("varchar(100)"))
+	assert.Nil(t, parseEnumValues("int"))
 }
 
 func TestUpdateRecord(t *testing.T) {
@@ -126,14 +161,16 @@ func TestUpdateRecord(t *testing.T) {
 	}
 	defer db.Close()
 
+	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+
 	mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE .*").
 		WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
-	mock.ExpectExec("UPDATE users SET .* WHERE id = ?").
+	mock.ExpectExec("UPDATE `users` SET .* WHERE `id` = ?").
 		WithArgs("John", 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 	req := httptest.NewRequest("PUT", "/crud/users/1", strings.NewReader(`{"name": "John"}`))
 	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
 	w := httptest.NewRecorder()
@@ -193,7 +230,7 @@ func TestDeleteRecord(t *testing.T) {
 					WithArgs("users").
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
-				mock.ExpectExec("DELETE FROM users WHERE id = ?").
+				mock.ExpectExec("DELETE FROM `users` WHERE `id` = ?").
 					WithArgs(1).
 					WillReturnError(fmt.Errorf("mock delete error"))
 			},
@@ -210,7 +247,7 @@ func TestDeleteRecord(t *testing.T) {
 					WithArgs("users").
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
-				mock.ExpectExec("DELETE FROM users WHERE id = ?").
+				mock.ExpectExec("DELETE FROM `users` WHERE `id` = ?").
 					WithArgs(999).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
@@ -227,7 +264,7 @@ func TestDeleteRecord(t *testing.T) {
 					WithArgs("users").
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
-				mock.ExpectExec("DELETE FROM users WHERE id = ?").
+				mock.ExpectExec("DELETE FROM `users` WHERE `id` = ?").
 					WithArgs(1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
@@ -238,9 +275,9 @@ func TestDeleteRecord(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			app := &App{SessionStore: map[string]*SessionData{}}
+			app := &App{SessionStore: NewMemoryStore()}
 			if tt.sessionToken != "" {
-				app.SessionStore[tt.sessionToken] = &SessionData{DB: db}
+				app.SessionStore.Save(hashSessionToken(tt.sessionToken), &SessionData{DB: db})
 			}
 
 			req := httptest.NewRequest("DELETE", fmt.Sprintf("/crud/%s/%d", tt.tableName, tt.recordID), nil)
@@ -276,16 +313,18 @@ func TestCreateRecord(t *testing.T) {
 	}
 	defer db.Close()
 
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 
 	t.Run("Success - Record Created", func(t *testing.T) {
-		mock.ExpectExec("INSERT INTO users .*").
-			WithArgs("John", "Doe").
-			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
 
 		mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE .*").
 			WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
+		mock.ExpectExec("INSERT INTO `users` .*").
+			WithArgs("John", "Doe").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
 		req := httptest.NewRequest("POST", "/crud/users", strings.NewReader(`{"first_name": "John", "last_name": "Doe"}`))
 		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
 		w := httptest.NewRecorder()
@@ -321,8 +360,28 @@ func TestCreateRecord(t *testing.T) {
 		}
 	})
 
+	t.Run("Failure - Body Does Not Match Schema", func(t *testing.T) {
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+
+		req := httptest.NewRequest("POST", "/crud/users", strings.NewReader(`{"first_name": 1, "nickname": "JD"}`))
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+		w := httptest.NewRecorder()
+
+		app.createRecord(w, req, "users")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Request body does not match the table schema","errors":[
+			{"field":"first_name","message":"must be a string"},
+			{"field":"last_name","message":"is required"},
+			{"field":"nickname","message":"is not a column of the table"}]}`, w.Body.String())
+	})
+
 	t.Run("Failure - Database Error on Insert", func(t *testing.T) {
-		mock.ExpectExec("INSERT INTO users .*").
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE .*").
+			WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+
+		mock.ExpectExec("INSERT INTO `users` .*").
 			WithArgs("John", "Doe").
 			WillReturnError(fmt.Errorf("database error"))
 
@@ -338,7 +397,7 @@ func TestCreateRecord(t *testing.T) {
 	})
 
 	t.Run("Failure - Invalid Table", func(t *testing.T) {
-		mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE .*").
+		mock.ExpectQuery(`FROM information_schema\.columns`).
 			WithArgs("invalid_table").WillReturnError(fmt.Errorf("invalid table"))
 
 		req := httptest.NewRequest("POST", "/crud/invalid_table", strings.NewReader(`{"first_name": "John", "last_name": "Doe"}`))
@@ -360,10 +419,10 @@ func TestReadRecord(t *testing.T) {
 	}
 	defer db.Close()
 
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 
 	t.Run("Success - Retrieve all records", func(t *testing.T) {
-		mock.ExpectQuery("SELECT .* FROM users").
+		mock.ExpectQuery("SELECT .* FROM `users`").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
 				AddRow(1, "John").
 				AddRow(2, "Doe"))
@@ -382,7 +441,7 @@ func TestReadRecord(t *testing.T) {
 	})
 
 	t.Run("Failure - Columns error", func(t *testing.T) {
-		mock.ExpectQuery("SELECT .* FROM users").
+		mock.ExpectQuery("SELECT .* FROM `users`").
 			WillReturnError(fmt.Errorf("Error querying all records"))
 
 		req := httptest.NewRequest("GET", "/crud/users", nil)
@@ -399,7 +458,7 @@ func TestReadRecord(t *testing.T) {
 	})
 
 	t.Run("Failure - Error processing rows", func(t *testing.T) {
-		mock.ExpectQuery("SELECT .* FROM users").
+		mock.ExpectQuery("SELECT .* FROM `users`").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "name"}).
 					AddRow(1, "John").
@@ -420,7 +479,7 @@ func TestReadRecord(t *testing.T) {
 	})
 
 	t.Run("Failure - No records found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT .* FROM users").
+		mock.ExpectQuery("SELECT .* FROM `users`").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
 
 		req := httptest.NewRequest("GET", "/crud/users", nil)
@@ -447,7 +506,7 @@ func TestGetPrimaryKey(t *testing.T) {
 	mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE .*").
 		WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 	req := httptest.NewRequest("GET", "/crud/users", nil)
 	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
 
@@ -462,7 +521,7 @@ func TestGetPrimaryKey(t *testing.T) {
 
 func TestGetDBFromSession(t *testing.T) {
 	db := &sql.DB{}
-	app := &App{SessionStore: map[string]*SessionData{"mockSession": {DB: db}}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db}})}
 	req := httptest.NewRequest("GET", "/", nil)
 	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
 
@@ -587,7 +646,7 @@ func TestReadRecordByID(t *testing.T) {
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
 				// Mock to simulate error in db.Query
-				mock.ExpectQuery(`^SELECT \* FROM users WHERE id = \?$`).
+				mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `id` = \\?$").
 					WithArgs(1).
 					WillReturnError(fmt.Errorf("mock query error"))
 			},
@@ -622,7 +681,7 @@ func TestReadRecordByID(t *testing.T) {
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
 				// Mock for db.Query with no results
-				mock.ExpectQuery(`^SELECT \* FROM users WHERE id = \?$`).
+				mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `id` = \\?$").
 					WithArgs(999).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
 			},
@@ -642,7 +701,7 @@ func TestReadRecordByID(t *testing.T) {
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
 				// Mock for db.Query returning a valid row
-				mock.ExpectQuery(`^SELECT \* FROM users WHERE id = \?$`).
+				mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `id` = \\?$").
 					WithArgs(1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
 						AddRow(1, "testuser"))
@@ -663,7 +722,7 @@ func TestReadRecordByID(t *testing.T) {
 					WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
 
 				// Mock for db.Query returning a valid row with []byte value
-				mock.ExpectQuery(`^SELECT \* FROM users WHERE id = \?$`).
+				mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `id` = \\?$").
 					WithArgs(1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
 						AddRow(1, []byte("testuser")))
@@ -675,9 +734,9 @@ func TestReadRecordByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			app := &App{SessionStore: map[string]*SessionData{}}
+			app := &App{SessionStore: NewMemoryStore()}
 			if tt.sessionToken != "" && tt.sessionExists {
-				app.SessionStore[tt.sessionToken] = &SessionData{DB: db}
+				app.SessionStore.Save(hashSessionToken(tt.sessionToken), &SessionData{DB: db})
 			}
 
 			req := httptest.NewRequest("GET", fmt.Sprintf("/crud/%s/%d", tt.tableName, tt.recordID), nil)
@@ -723,9 +782,9 @@ func TestCrudHandler(t *testing.T) {
 		require.NoError(t, err)
 
 		app := &App{
-			SessionStore: map[string]*SessionData{
-				"mockSession": {DB: db},
-			},
+			SessionStore: newTestStore(map[string]*SessionData{
+				hashSessionToken("mockSession"): {DB: db},
+			}),
 		}
 		return app, db, mock
 	}
@@ -744,15 +803,17 @@ func TestCrudHandler(t *testing.T) {
 		app, db, mock := setup(t)
 		defer db.Close()
 
-		// INSERT (args order depends on map iteration; don't assert WithArgs)
-		mock.ExpectExec(`^INSERT INTO users .*`).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
 
-		// createRecord calls getPrimaryKey after INSERT
+		// createRecord calls getPrimaryKey before the INSERT
 		mock.ExpectQuery(pkQuery).
 			WithArgs("users").
 			WillReturnRows(primaryKeyRows("id"))
 
+		// INSERT (args order depends on map iteration; don't assert WithArgs)
+		mock.ExpectExec("^INSERT INTO `users` .*").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
 		body := bytes.NewBufferString(`{"first_name":"John","last_name":"Doe"}`)
 		req := httptest.NewRequest(http.MethodPost, "/crud/users", body)
 		req.Header.Set("Content-Type", "application/json")
@@ -774,7 +835,7 @@ func TestCrudHandler(t *testing.T) {
 			AddRow(1, "John").
 			AddRow(2, "Jane")
 
-		mock.ExpectQuery(`^SELECT \* FROM users$`).WillReturnRows(rows)
+		mock.ExpectQuery("^SELECT \\* FROM `users`$").WillReturnRows(rows)
 
 		req := httptest.NewRequest(http.MethodGet, "/crud/users", nil)
 		req = mux.SetURLVars(req, map[string]string{"table": "users"})
@@ -801,7 +862,7 @@ func TestCrudHandler(t *testing.T) {
 			WillReturnRows(primaryKeyRows("id"))
 
 		rows := sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John")
-		mock.ExpectQuery(`^SELECT \* FROM users WHERE id = \?$`).
+		mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `id` = \\?$").
 			WithArgs(1).
 			WillReturnRows(rows)
 
@@ -841,12 +902,14 @@ func TestCrudHandler(t *testing.T) {
 		app, db, mock := setup(t)
 		defer db.Close()
 
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+
 		mock.ExpectQuery(pkQuery).
 			WithArgs("users").
 			WillReturnRows(primaryKeyRows("id"))
 
 		// UPDATE (args order depends on map iteration; don't assert WithArgs)
-		mock.ExpectExec(`^UPDATE users SET .* WHERE id = \?$`).
+		mock.ExpectExec("^UPDATE `users` SET .* WHERE `id` = \\?$").
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		body := bytes.NewBufferString(`{"first_name":"Jane","last_name":"Smith"}`)
@@ -887,7 +950,7 @@ func TestCrudHandler(t *testing.T) {
 			WithArgs("users").
 			WillReturnRows(primaryKeyRows("id"))
 
-		mock.ExpectExec(`^DELETE FROM users WHERE id = \?$`).
+		mock.ExpectExec("^DELETE FROM `users` WHERE `id` = \\?$").
 			WithArgs(1).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -954,7 +1017,7 @@ func TestCrudHandler(t *testing.T) {
 
 func TestReadRecord_SessionNotFound(t *testing.T) {
 	app := &App{
-		SessionStore: make(map[string]*SessionData), // Nenhuma sessão configurada
+		SessionStore: NewMemoryStore(), // Nenhuma sessão configurada
 	}
 
 	// Criar uma requisição com um cookie de sessão inexistente
@@ -996,7 +1059,7 @@ func equalSlices(a, b []string) bool {
 }
 
 func TestCrudHandler_MethodNotAllowed(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodPatch, "/api/v1/crud/users", nil)
@@ -1010,7 +1073,7 @@ func TestCrudHandler_MethodNotAllowed(t *testing.T) {
 }
 
 func TestGetPrimaryKey_NoSession_ReturnsError(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/crud/users/1", nil)
 	_, err := app.getPrimaryKey(r, "users")
@@ -1020,7 +1083,7 @@ func TestGetPrimaryKey_NoSession_ReturnsError(t *testing.T) {
 }
 
 func TestListTablesHandler_DatabaseNotFoundInContext(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
@@ -1036,7 +1099,7 @@ func TestListTablesHandler_DatabaseNotFoundInContext(t *testing.T) {
 }
 
 func TestListTablesHandler_InvalidDatabaseTypeInContext(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
@@ -1053,7 +1116,7 @@ func TestListTablesHandler_InvalidDatabaseTypeInContext(t *testing.T) {
 }
 
 func TestListTablesHandler_QueryError(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -1061,7 +1124,7 @@ func TestListTablesHandler_QueryError(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery(`SELECT table_name FROM information_schema\.tables`).WillReturnError(errors.New("boom"))
+	mock.ExpectQuery(`SELECT table_name, .* FROM information_schema\.tables`).WillReturnError(errors.New("boom"))
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
@@ -1082,7 +1145,7 @@ func TestListTablesHandler_QueryError(t *testing.T) {
 }
 
 func TestListTablesHandler_ScanError(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -1092,7 +1155,7 @@ func TestListTablesHandler_ScanError(t *testing.T) {
 
 	// Force rows.Scan(&tableName) to fail by returning 2 columns but scanning only 1.
 	rows := sqlmock.NewRows([]string{"table_name", "extra"}).AddRow("users", "x")
-	mock.ExpectQuery(`SELECT table_name FROM information_schema\.tables`).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT table_name, .* FROM information_schema\.tables`).WillReturnRows(rows)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
@@ -1156,15 +1219,13 @@ func TestTableStructureHandler_ForeignKeyBranch(t *testing.T) {
 	}
 	defer db.Close()
 
-	app := &App{SessionStore: map[string]*SessionData{
-		"tok": {DB: db},
-	}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{
+		hashSessionToken("tok"): {DB: db},
+	})}
 
 	// One row where referenced table/column are present to hit the foreign key branch.
-	rows := sqlmock.NewRows([]string{
-		"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT",
-		"IS_PRIMARY_KEY", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME",
-	}).AddRow("user_id", "int", "NO", nil, false, "roles", "id")
+	rows := sqlmock.NewRows(tableStructureColumns).
+		AddRow("user_id", "int", "NO", nil, false, "roles", "id", "int", nil, 10, 0, "", "", nil, "")
 
 	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(rows)
 
@@ -1195,7 +1256,7 @@ func TestTableStructureHandler_ForeignKeyBranch(t *testing.T) {
 }
 
 func TestTableStructureHandler_MissingCookie(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/table-structure?table=users", nil)
@@ -1208,7 +1269,7 @@ func TestTableStructureHandler_MissingCookie(t *testing.T) {
 }
 
 func TestTableStructureHandler_MissingTableParam(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/table-structure", nil)
@@ -1234,9 +1295,9 @@ func TestTableStructureHandler_QueryError(t *testing.T) {
 	}
 	defer db.Close()
 
-	app := &App{SessionStore: map[string]*SessionData{
-		"tok": {DB: db},
-	}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{
+		hashSessionToken("tok"): {DB: db},
+	})}
 
 	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnError(errors.New("boom"))
 
@@ -1262,11 +1323,11 @@ func TestTableStructureHandler_ScanError(t *testing.T) {
 	}
 	defer db.Close()
 
-	app := &App{SessionStore: map[string]*SessionData{
-		"tok": {DB: db},
-	}}
+	app := &App{SessionStore: newTestStore(map[string]*SessionData{
+		hashSessionToken("tok"): {DB: db},
+	})}
 
-	// Force rows.Scan(...) to fail by returning 8 columns while the handler scans 7.
+	// Force rows.Scan(...) to fail by returning 8 columns while the handler scans 15.
 	rows := sqlmock.NewRows([]string{
 		"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT",
 		"IS_PRIMARY_KEY", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME",
@@ -1294,7 +1355,7 @@ func TestTableStructureHandler_ScanError(t *testing.T) {
 }
 
 func TestTableStructureHandler_SessionNotFound(t *testing.T) {
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/v1/table-structure?table=users", nil)
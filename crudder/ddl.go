@@ -0,0 +1,636 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// struct describes a table to create; columns and indexes mirror the table-structure responses
+type TableDefinition struct {
+	TableName    string       `json:"table_name"`
+	Engine       string       `json:"engine,omitempty"`
+	TableComment string       `json:"table_comment,omitempty"`
+	Columns      []ColumnInfo `json:"columns"`
+	Indexes      []IndexInfo  `json:"indexes,omitempty"`
+}
+
+// struct represents the outcome of a DDL request
+type DDLResult struct {
+	Message string `json:"message"`
+	SQL     string `json:"sql"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// simple column types such as "int", "varchar(100)", "decimal(10,2) unsigned"
+var columnTypePattern = regexp.MustCompile(`^(?i)[a-z]+( ?\(\s*[0-9]+\s*(,\s*[0-9]+\s*)?\))?( unsigned| signed| zerofill)*$`)
+
+// default values that are SQL keywords rather than literals
+var defaultKeywordPattern = regexp.MustCompile(`^(?i)(NULL|CURRENT_TIMESTAMP(\([0-6]?\))?)$`)
+
+// the ON UPDATE clause MySQL reports in EXTRA, e.g. "DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)"
+var onUpdatePattern = regexp.MustCompile(`(?i)\bon update (CURRENT_TIMESTAMP(\([0-6]?\))?)`)
+
+// columnTypeSQL renders the type of a column from COLUMN_TYPE, or from DATA_TYPE plus its limits
+func columnTypeSQL(col ColumnInfo) (string, error) {
+	columnType := strings.TrimSpace(col.ColumnType)
+	if columnType == "" {
+		columnType = col.DataType
+		switch {
+		case len(col.EnumValues) > 0:
+			// rebuilt below from EnumValues
+		case col.CharacterMaxLength != nil:
+			columnType = fmt.Sprintf("%s(%d)", col.DataType, *col.CharacterMaxLength)
+		case col.NumericPrecision != nil && col.NumericScale != nil:
+			columnType = fmt.Sprintf("%s(%d,%d)", col.DataType, *col.NumericPrecision, *col.NumericScale)
+		}
+	}
+
+	lower := strings.ToLower(columnType)
+	if strings.HasPrefix(lower, "enum") || strings.HasPrefix(lower, "set") {
+		values := col.EnumValues
+		if strings.Contains(columnType, "(") {
+			values = parseEnumValues(columnType)
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("column '%s' has no enum or set members", col.ColumnName)
+		}
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = quoteLiteral(v)
+		}
+		keyword := "ENUM"
+		if strings.HasPrefix(lower, "set") {
+			keyword = "SET"
+		}
+		return fmt.Sprintf("%s(%s)", keyword, strings.Join(quoted, ",")), nil
+	}
+
+	if !columnTypePattern.MatchString(columnType) {
+		return "", fmt.Errorf("invalid type '%s' for column '%s'", columnType, col.ColumnName)
+	}
+	return columnType, nil
+}
+
+// columnDefinitionSQL renders a column as used by CREATE TABLE and ALTER TABLE ... ADD/MODIFY COLUMN
+func columnDefinitionSQL(col ColumnInfo) (string, error) {
+	if !isAlphaNumeric(col.ColumnName) {
+		return "", fmt.Errorf("invalid column name '%s'", col.ColumnName)
+	}
+	columnType, err := columnTypeSQL(col)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{quoteIdentifier(col.ColumnName), columnType}
+	if col.Collation != nil {
+		if !isAlphaNumeric(*col.Collation) {
+			return "", fmt.Errorf("invalid collation '%s'", *col.Collation)
+		}
+		parts = append(parts, "COLLATE "+*col.Collation)
+	}
+	if col.GenerationExpression != nil {
+		if !validExpression(*col.GenerationExpression) {
+			return "", fmt.Errorf("invalid generation expression for column '%s'", col.ColumnName)
+		}
+		storage := "VIRTUAL"
+		if strings.Contains(strings.ToUpper(col.Extra), "STORED") {
+			storage = "STORED"
+		}
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", *col.GenerationExpression, storage))
+	}
+	if col.IsNullable {
+		parts = append(parts, "NULL")
+	} else {
+		parts = append(parts, "NOT NULL")
+	}
+	if col.ColumnDefault != nil {
+		switch {
+		case defaultKeywordPattern.MatchString(*col.ColumnDefault):
+			parts = append(parts, "DEFAULT "+strings.ToUpper(*col.ColumnDefault))
+		case strings.Contains(strings.ToUpper(col.Extra), "DEFAULT_GENERATED"):
+			// an expression default such as curdate() or (uuid())
+			if !validExpression(*col.ColumnDefault) {
+				return "", fmt.Errorf("invalid default expression for column '%s'", col.ColumnName)
+			}
+			parts = append(parts, fmt.Sprintf("DEFAULT (%s)", *col.ColumnDefault))
+		default:
+			parts = append(parts, "DEFAULT "+quoteLiteral(*col.ColumnDefault))
+		}
+	}
+	if m := onUpdatePattern.FindStringSubmatch(col.Extra); m != nil {
+		parts = append(parts, "ON UPDATE "+strings.ToUpper(m[1]))
+	}
+	if col.IsAutoIncrement {
+		parts = append(parts, "AUTO_INCREMENT")
+	}
+	if col.ColumnComment != "" {
+		parts = append(parts, "COMMENT "+quoteLiteral(col.ColumnComment))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// validExpression reports whether a generation expression stays inside the parentheses
+// it is wrapped in: its parentheses and quotes are balanced, and outside quotes it has
+// no statement separator or comment that could end the clause early
+func validExpression(expr string) bool {
+	depth := 0
+	var quote rune
+	runes := []rune(expr)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if quote != 0 {
+			switch {
+			case c == '\\' && quote != '`':
+				i++
+			case c == quote && i+1 < len(runes) && runes[i+1] == quote:
+				i++
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return false
+			}
+		case ';', '#':
+			return false
+		case '-', '/':
+			if i+1 < len(runes) && (c == '-' && runes[i+1] == '-' || c == '/' && runes[i+1] == '*') {
+				return false
+			}
+		}
+	}
+	return depth == 0 && quote == 0
+}
+
+// foreignKeySQL renders the FOREIGN KEY clause of a column, or "" when it references nothing
+func foreignKeySQL(col ColumnInfo) (string, error) {
+	if col.ReferencedTable == nil || col.ReferencedColumn == nil {
+		return "", nil
+	}
+	if !isAlphaNumeric(*col.ReferencedTable) || !isAlphaNumeric(*col.ReferencedColumn) {
+		return "", fmt.Errorf("invalid foreign key reference for column '%s'", col.ColumnName)
+	}
+	return fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+		quoteIdentifier(col.ColumnName), quoteIdentifier(*col.ReferencedTable), quoteIdentifier(*col.ReferencedColumn)), nil
+}
+
+// indexColumnsSQL renders the column list of an index, e.g. (`a`, `b`(10) DESC)
+func indexColumnsSQL(index IndexInfo) (string, error) {
+	if len(index.Columns) == 0 {
+		return "", fmt.Errorf("index '%s' has no columns", index.IndexName)
+	}
+	columns := make([]string, 0, len(index.Columns))
+	for _, c := range index.Columns {
+		if !isAlphaNumeric(c.ColumnName) {
+			return "", fmt.Errorf("invalid column name '%s'", c.ColumnName)
+		}
+		column := quoteIdentifier(c.ColumnName)
+		if c.SubPart != nil {
+			column += fmt.Sprintf("(%d)", *c.SubPart)
+		}
+		if c.Descending {
+			column += " DESC"
+		}
+		columns = append(columns, column)
+	}
+	return "(" + strings.Join(columns, ", ") + ")", nil
+}
+
+// indexDefinitionSQL renders an index as used inside CREATE TABLE and ALTER TABLE ... ADD
+func indexDefinitionSQL(index IndexInfo) (string, error) {
+	columns, err := indexColumnsSQL(index)
+	if err != nil {
+		return "", err
+	}
+	if index.IsPrimary {
+		return "PRIMARY KEY " + columns, nil
+	}
+	if !isAlphaNumeric(index.IndexName) {
+		return "", fmt.Errorf("invalid index name '%s'", index.IndexName)
+	}
+	if index.IsUnique {
+		return fmt.Sprintf("UNIQUE KEY %s %s", quoteIdentifier(index.IndexName), columns), nil
+	}
+	return fmt.Sprintf("KEY %s %s", quoteIdentifier(index.IndexName), columns), nil
+}
+
+// createTableSQL renders the CREATE TABLE statement of a table definition
+func createTableSQL(def TableDefinition) (string, error) {
+	if !isAlphaNumeric(def.TableName) {
+		return "", fmt.Errorf("invalid table name '%s'", def.TableName)
+	}
+	if len(def.Columns) == 0 {
+		return "", fmt.Errorf("table '%s' has no columns", def.TableName)
+	}
+
+	var lines, primaryKey, foreignKeys []string
+	for _, col := range def.Columns {
+		column, err := columnDefinitionSQL(col)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, column)
+		if col.IsPrimaryKey {
+			primaryKey = append(primaryKey, quoteIdentifier(col.ColumnName))
+		}
+		fk, err := foreignKeySQL(col)
+		if err != nil {
+			return "", err
+		}
+		if fk != "" {
+			foreignKeys = append(foreignKeys, fk)
+		}
+	}
+	if len(primaryKey) > 0 {
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKey, ", ")))
+	}
+	for _, index := range def.Indexes {
+		if index.IsPrimary && len(primaryKey) > 0 {
+			continue
+		}
+		definition, err := indexDefinitionSQL(index)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, definition)
+	}
+	lines = append(lines, foreignKeys...)
+
+	query := fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", quoteIdentifier(def.TableName), strings.Join(lines, ",\n  "))
+	if def.Engine != "" {
+		if !isAlphaNumeric(def.Engine) {
+			return "", fmt.Errorf("invalid engine '%s'", def.Engine)
+		}
+		query += " ENGINE=" + def.Engine
+	}
+	if def.TableComment != "" {
+		query += " COMMENT=" + quoteLiteral(def.TableComment)
+	}
+	return query, nil
+}
+
+// addColumnSQL renders the ALTER TABLE statement that adds a column and its foreign key
+func addColumnSQL(tableName string, col ColumnInfo) (string, error) {
+	column, err := columnDefinitionSQL(col)
+	if err != nil {
+		return "", err
+	}
+	clauses := []string{"ADD COLUMN " + column}
+	if col.IsPrimaryKey {
+		clauses = append(clauses, fmt.Sprintf("ADD PRIMARY KEY (%s)", quoteIdentifier(col.ColumnName)))
+	}
+	fk, err := foreignKeySQL(col)
+	if err != nil {
+		return "", err
+	}
+	if fk != "" {
+		clauses = append(clauses, "ADD "+fk)
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s", quoteIdentifier(tableName), strings.Join(clauses, ", ")), nil
+}
+
+// modifyColumnSQL renders the ALTER TABLE statement that redefines (and possibly renames) a column
+func modifyColumnSQL(tableName, columnName string, col ColumnInfo) (string, error) {
+	if col.ColumnName == "" {
+		col.ColumnName = columnName
+	}
+	column, err := columnDefinitionSQL(col)
+	if err != nil {
+		return "", err
+	}
+	if col.ColumnName != columnName {
+		return fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", quoteIdentifier(tableName), quoteIdentifier(columnName), column), nil
+	}
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", quoteIdentifier(tableName), column), nil
+}
+
+// authorizeDefinition checks the columns and indexes of a DDL body against the policy:
+// the user must be allowed to use every column they name on table, and to see the
+// tables and columns their foreign keys reference. It writes the error response itself.
+func (app *App) authorizeDefinition(w http.ResponseWriter, r *http.Request, table string, columns []ColumnInfo, indexes []IndexInfo) bool {
+	if app.Policy == nil {
+		return true
+	}
+	session := app.getSession(r)
+	names := make(map[string]interface{})
+	for _, col := range columns {
+		names[col.ColumnName] = nil
+	}
+	for _, index := range indexes {
+		for _, col := range index.Columns {
+			names[col.ColumnName] = nil
+		}
+	}
+	if !authorizeColumns(w, app.tableAccess(session, table), names) {
+		return false
+	}
+
+	for _, col := range columns {
+		if col.ReferencedTable == nil {
+			continue
+		}
+		referenced := app.tableAccess(session, *col.ReferencedTable)
+		if !referenced.visible() {
+			WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+			return false
+		}
+		if col.ReferencedColumn != nil && !referenced.column(*col.ReferencedColumn) {
+			WriteErrorResponse(w, http.StatusForbidden, fmt.Sprintf(errColumnDenied, *col.ReferencedColumn))
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeIndex reports an index over columns the user may not see as not found, as
+// the index listings leave it out, writing the error response itself
+func (app *App) authorizeIndex(w http.ResponseWriter, r *http.Request, table, indexName string) bool {
+	if app.Policy == nil {
+		return true
+	}
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
+		return false
+	}
+	indexes, err := fetchIndexes(db, table)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table indexes")
+		log.Println("error fetching indexes:", err)
+		return false
+	}
+	access := app.tableAccess(app.getSession(r), table)
+	for _, index := range indexes {
+		if strings.EqualFold(index.IndexName, indexName) && len(visibleIndexes(access, []IndexInfo{index})) == 0 {
+			WriteErrorResponse(w, http.StatusNotFound, errIndexNotFound)
+			return false
+		}
+	}
+	return true
+}
+
+// runDDL executes a generated statement, or only returns it when the request asks for ?dry_run=true
+func (app *App) runDDL(w http.ResponseWriter, r *http.Request, query, message string) {
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSONResponseWithStatus(w, http.StatusOK, DDLResult{Message: "Dry run, statement not executed", SQL: query, DryRun: true})
+		return
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
+		return
+	}
+
+	if _, err := db.Exec(query); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error executing DDL: %v", err))
+		log.Println("error executing DDL:", query, err)
+		return
+	}
+	app.invalidateSchemaCache(r)
+	writeJSONResponseWithStatus(w, http.StatusOK, DDLResult{Message: message, SQL: query})
+}
+
+// ddlPathVars validates the identifiers taken from the route, and checks that the
+// policy lets the user alter the table and use the column
+func (app *App) ddlPathVars(w http.ResponseWriter, r *http.Request, names ...string) (map[string]string, bool) {
+	vars := mux.Vars(r)
+	for _, name := range names {
+		if !isAlphaNumeric(vars[name]) {
+			WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+			return nil, false
+		}
+	}
+	if !app.authorizeTable(w, r, vars["table"], opAlter) {
+		return nil, false
+	}
+	if column, ok := vars["column"]; ok {
+		if !app.tableAccess(app.getSession(r), vars["table"]).column(column) {
+			WriteErrorResponse(w, http.StatusForbidden, fmt.Sprintf(errColumnDenied, column))
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+// @Summary Create Table
+// @Description Creates a table from a JSON description whose columns and indexes mirror the table-structure responses. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Accept json
+// @Produce json
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Param body body TableDefinition true "Table definition"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid table definition"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables [post]
+func (app *App) createTableHandler(w http.ResponseWriter, r *http.Request) {
+	var def TableDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+		return
+	}
+	query, err := createTableSQL(def)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !app.authorizeTable(w, r, def.TableName, opAlter) || !app.authorizeDefinition(w, r, def.TableName, def.Columns, def.Indexes) {
+		return
+	}
+	app.runDDL(w, r, query, "Table created")
+}
+
+// @Summary Drop Table
+// @Description Drops a table. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Produce json
+// @Param table path string true "Table name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid table name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table} [delete]
+func (app *App) dropTableHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table")
+	if !ok {
+		return
+	}
+	app.runDDL(w, r, "DROP TABLE "+quoteIdentifier(vars["table"]), "Table dropped")
+}
+
+// @Summary Add Column
+// @Description Adds a column, described like a table-structure entry, to a table. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Accept json
+// @Produce json
+// @Param table path string true "Table name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Param body body ColumnInfo true "Column definition"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid column definition"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table}/columns [post]
+func (app *App) addColumnHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table")
+	if !ok {
+		return
+	}
+	var col ColumnInfo
+	if err := json.NewDecoder(r.Body).Decode(&col); err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+		return
+	}
+	query, err := addColumnSQL(vars["table"], col)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !app.authorizeDefinition(w, r, vars["table"], []ColumnInfo{col}, nil) {
+		return
+	}
+	app.runDDL(w, r, query, "Column added")
+}
+
+// @Summary Modify Column
+// @Description Redefines a column; a different column_name in the body renames it. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Accept json
+// @Produce json
+// @Param table path string true "Table name"
+// @Param column path string true "Column name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Param body body ColumnInfo true "Column definition"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid column definition"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table}/columns/{column} [put]
+func (app *App) modifyColumnHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table", "column")
+	if !ok {
+		return
+	}
+	var col ColumnInfo
+	if err := json.NewDecoder(r.Body).Decode(&col); err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+		return
+	}
+	query, err := modifyColumnSQL(vars["table"], vars["column"], col)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// a rename must not give the column a name the user may not use
+	if !app.authorizeDefinition(w, r, vars["table"], []ColumnInfo{col}, nil) {
+		return
+	}
+	app.runDDL(w, r, query, "Column modified")
+}
+
+// @Summary Drop Column
+// @Description Drops a column from a table. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Produce json
+// @Param table path string true "Table name"
+// @Param column path string true "Column name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid table or column name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table}/columns/{column} [delete]
+func (app *App) dropColumnHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table", "column")
+	if !ok {
+		return
+	}
+	query := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteIdentifier(vars["table"]), quoteIdentifier(vars["column"]))
+	app.runDDL(w, r, query, "Column dropped")
+}
+
+// @Summary Create Index
+// @Description Creates an index, described like a table-structure/{table}/indexes entry. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Accept json
+// @Produce json
+// @Param table path string true "Table name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Param body body IndexInfo true "Index definition"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid index definition"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table}/indexes [post]
+func (app *App) createIndexHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table")
+	if !ok {
+		return
+	}
+	var index IndexInfo
+	if err := json.NewDecoder(r.Body).Decode(&index); err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+		return
+	}
+	definition, err := indexDefinitionSQL(index)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// a unique index over a hidden column would reveal its values through duplicate key errors
+	if !app.authorizeDefinition(w, r, vars["table"], nil, []IndexInfo{index}) {
+		return
+	}
+	app.runDDL(w, r, fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdentifier(vars["table"]), definition), "Index created")
+}
+
+// @Summary Drop Index
+// @Description Drops an index; the PRIMARY index drops the primary key. With dry_run=true the generated SQL is returned without being executed.
+// @Tags Schema
+// @Produce json
+// @Param table path string true "Table name"
+// @Param index path string true "Index name"
+// @Param dry_run query bool false "Only return the generated SQL"
+// @Success 200 {object} DDLResult "Generated SQL and outcome"
+// @Failure 400 {object} map[string]string "Invalid table or index name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 404 {object} map[string]string "Index over columns hidden by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Error executing DDL"
+// @Router /schema/tables/{table}/indexes/{index} [delete]
+func (app *App) dropIndexHandler(w http.ResponseWriter, r *http.Request) {
+	vars, ok := app.ddlPathVars(w, r, "table", "index")
+	if !ok {
+		return
+	}
+	if !app.authorizeIndex(w, r, vars["table"], vars["index"]) {
+		return
+	}
+	query := fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", quoteIdentifier(vars["table"]), quoteIdentifier(vars["index"]))
+	if vars["index"] == "PRIMARY" {
+		query = fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", quoteIdentifier(vars["table"]))
+	}
+	app.runDDL(w, r, query, "Index dropped")
+}
@@ -0,0 +1,320 @@
+package crudder
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestColumnDefinitionSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		col      ColumnInfo
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "Auto increment key",
+			col:      ColumnInfo{ColumnName: "id", ColumnType: "int unsigned", IsAutoIncrement: true},
+			expected: "`id` int unsigned NOT NULL AUTO_INCREMENT",
+		},
+		{
+			name:     "Type from data type and length",
+			col:      ColumnInfo{ColumnName: "name", DataType: "varchar", CharacterMaxLength: int64Ptr(100), IsNullable: true, ColumnDefault: strPtr("it's"), ColumnComment: "display name"},
+			expected: "`name` varchar(100) NULL DEFAULT 'it''s' COMMENT 'display name'",
+		},
+		{
+			name:     "Type from precision and scale",
+			col:      ColumnInfo{ColumnName: "price", DataType: "decimal", NumericPrecision: int64Ptr(10), NumericScale: int64Ptr(2), ColumnDefault: strPtr("0")},
+			expected: "`price` decimal(10,2) NOT NULL DEFAULT '0'",
+		},
+		{
+			name:     "Enum from members",
+			col:      ColumnInfo{ColumnName: "status", DataType: "enum", EnumValues: []string{"on", "o'ff"}, Collation: strPtr("utf8mb4_bin")},
+			expected: "`status` ENUM('on','o''ff') COLLATE utf8mb4_bin NOT NULL",
+		},
+		{
+			name:     "Set from column type",
+			col:      ColumnInfo{ColumnName: "perms", ColumnType: "set('r','w')", IsNullable: true},
+			expected: "`perms` SET('r','w') NULL",
+		},
+		{
+			name:     "Default keyword",
+			col:      ColumnInfo{ColumnName: "created_at", ColumnType: "timestamp", ColumnDefault: strPtr("current_timestamp")},
+			expected: "`created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP",
+		},
+		{
+			name:     "Expression default",
+			col:      ColumnInfo{ColumnName: "born_on", ColumnType: "date", ColumnDefault: strPtr("curdate()"), Extra: "DEFAULT_GENERATED"},
+			expected: "`born_on` date NOT NULL DEFAULT (curdate())",
+		},
+		{
+			name: "On update",
+			col: ColumnInfo{ColumnName: "updated_at", ColumnType: "datetime(3)", ColumnDefault: strPtr("CURRENT_TIMESTAMP(3)"),
+				Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)"},
+			expected: "`updated_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3)",
+		},
+		{
+			name:     "Stored generated column",
+			col:      ColumnInfo{ColumnName: "total", ColumnType: "decimal(10,2)", GenerationExpression: strPtr("`price` * `qty`"), Extra: "STORED GENERATED", IsNullable: true},
+			expected: "`total` decimal(10,2) GENERATED ALWAYS AS (`price` * `qty`) STORED NULL",
+		},
+		{
+			name:     "Generated column with quoted parentheses",
+			col:      ColumnInfo{ColumnName: "label", ColumnType: "varchar(50)", GenerationExpression: strPtr("concat(`name`, ' (', `code`, ');')"), IsNullable: true},
+			expected: "`label` varchar(50) GENERATED ALWAYS AS (concat(`name`, ' (', `code`, ');')) VIRTUAL NULL",
+		},
+		{name: "Injected type", col: ColumnInfo{ColumnName: "x", ColumnType: "int; DROP TABLE users"}, wantErr: true},
+		{name: "Invalid name", col: ColumnInfo{ColumnName: "x y", ColumnType: "int"}, wantErr: true},
+		{name: "Invalid collation", col: ColumnInfo{ColumnName: "x", ColumnType: "text", Collation: strPtr("a b")}, wantErr: true},
+		{name: "Empty enum", col: ColumnInfo{ColumnName: "x", DataType: "enum"}, wantErr: true},
+		{name: "Generation expression with statement", col: ColumnInfo{ColumnName: "x", ColumnType: "int", GenerationExpression: strPtr("1); DROP TABLE t; --")}, wantErr: true},
+		{name: "Generation expression with comment", col: ColumnInfo{ColumnName: "x", ColumnType: "int", GenerationExpression: strPtr("(1 -- )")}, wantErr: true},
+		{name: "Generation expression with open quote", col: ColumnInfo{ColumnName: "x", ColumnType: "int", GenerationExpression: strPtr("'it''s")}, wantErr: true},
+		{name: "Default expression closing the clause", col: ColumnInfo{ColumnName: "x", ColumnType: "int", ColumnDefault: strPtr("1), ADD COLUMN y INT DEFAULT (1"), Extra: "DEFAULT_GENERATED"}, wantErr: true},
+		{name: "Generation expression closing the clause", col: ColumnInfo{ColumnName: "x", ColumnType: "int", GenerationExpression: strPtr("1) VIRTUAL, ADD COLUMN y INT AS (1")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := columnDefinitionSQL(tt.col)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	def := TableDefinition{
+		TableName:    "orders",
+		Engine:       "InnoDB",
+		TableComment: "customer orders",
+		Columns: []ColumnInfo{
+			{ColumnName: "order_id", ColumnType: "int", IsPrimaryKey: true, IsAutoIncrement: true},
+			{ColumnName: "user_id", ColumnType: "int", ReferencedTable: strPtr("users"), ReferencedColumn: strPtr("user_id")},
+			{ColumnName: "code", ColumnType: "varchar(20)"},
+		},
+		Indexes: []IndexInfo{
+			{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "order_id"}}},
+			{IndexName: "code", IsUnique: true, Columns: []IndexColumn{{ColumnName: "code", SubPart: int64Ptr(10)}}},
+			{IndexName: "user_code", Columns: []IndexColumn{{ColumnName: "user_id"}, {ColumnName: "code", Descending: true}}},
+		},
+	}
+
+	got, err := createTableSQL(def)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE `orders` (\n"+
+		"  `order_id` int NOT NULL AUTO_INCREMENT,\n"+
+		"  `user_id` int NOT NULL,\n"+
+		"  `code` varchar(20) NOT NULL,\n"+
+		"  PRIMARY KEY (`order_id`),\n"+
+		"  UNIQUE KEY `code` (`code`(10)),\n"+
+		"  KEY `user_code` (`user_id`, `code` DESC),\n"+
+		"  FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`)\n"+
+		") ENGINE=InnoDB COMMENT='customer orders'", got)
+
+	_, err = createTableSQL(TableDefinition{TableName: "empty"})
+	assert.Error(t, err)
+
+	_, err = createTableSQL(TableDefinition{TableName: "bad name", Columns: def.Columns})
+	assert.Error(t, err)
+
+	_, err = createTableSQL(TableDefinition{TableName: "t", Engine: "x;y", Columns: def.Columns})
+	assert.Error(t, err)
+
+	_, err = createTableSQL(TableDefinition{TableName: "t", Columns: []ColumnInfo{
+		{ColumnName: "a", ColumnType: "int", ReferencedTable: strPtr("bad table"), ReferencedColumn: strPtr("id")},
+	}})
+	assert.Error(t, err)
+}
+
+func TestAlterStatementsSQL(t *testing.T) {
+	got, err := addColumnSQL("orders", ColumnInfo{ColumnName: "role_id", ColumnType: "int", IsNullable: true, ReferencedTable: strPtr("roles"), ReferencedColumn: strPtr("role_id")})
+	require.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `orders` ADD COLUMN `role_id` int NULL, ADD FOREIGN KEY (`role_id`) REFERENCES `roles` (`role_id`)", got)
+
+	got, err = modifyColumnSQL("orders", "code", ColumnInfo{ColumnType: "varchar(40)"})
+	require.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `orders` MODIFY COLUMN `code` varchar(40) NOT NULL", got)
+
+	got, err = modifyColumnSQL("orders", "code", ColumnInfo{ColumnName: "order_code", ColumnType: "varchar(40)"})
+	require.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `orders` CHANGE COLUMN `code` `order_code` varchar(40) NOT NULL", got)
+
+	_, err = indexDefinitionSQL(IndexInfo{IndexName: "empty"})
+	assert.Error(t, err)
+}
+
+func newDDLRequest(method, target, body string, vars map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = mux.SetURLVars(req, vars)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	return req
+}
+
+func TestDDLHandlers(t *testing.T) {
+	t.Run("Create table dry run", func(t *testing.T) {
+		app, mock := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.createTableHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables?dry_run=true",
+			`{"table_name":"tags","columns":[{"column_name":"tag","column_type":"varchar(50)","is_primary_key":true}]}`, nil))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"message":"Dry run, statement not executed","sql":"CREATE TABLE `+"`tags`"+` (\n  `+"`tag`"+` varchar(50) NOT NULL,\n  PRIMARY KEY (`+"`tag`"+`)\n)","dry_run":true}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Create table", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE `tags`")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.createTableHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables",
+			`{"table_name":"tags","columns":[{"column_name":"tag","column_type":"varchar(50)"}]}`, nil))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"message":"Table created"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Create table with invalid definition", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.createTableHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables",
+			`{"table_name":"tags","columns":[{"column_name":"tag","column_type":"varchar(50)) ; DROP TABLE users; --"}]}`, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Create table with invalid JSON", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.createTableHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables", `{`, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Drop table", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("DROP TABLE `tags`")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags", "", map[string]string{"table": "tags"}))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Drop table with invalid name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/x", "", map[string]string{"table": "x`y"}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Add column", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE `tags` ADD COLUMN `color` varchar(7) NULL")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.addColumnHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables/tags/columns",
+			`{"column_name":"color","data_type":"varchar","character_maximum_length":7,"is_nullable":true}`, map[string]string{"table": "tags"}))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Modify column dry run", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.modifyColumnHandler(w, newDDLRequest(http.MethodPut, "/api/v1/schema/tables/tags/columns/color?dry_run=true",
+			`{"column_type":"varchar(9)"}`, map[string]string{"table": "tags", "column": "color"}))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "MODIFY COLUMN `color` varchar(9) NOT NULL")
+	})
+
+	t.Run("Drop column", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE `tags` DROP COLUMN `color`")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.dropColumnHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags/columns/color", "",
+			map[string]string{"table": "tags", "column": "color"}))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Create index", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE `tags` ADD UNIQUE KEY `color` (`color`)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.createIndexHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables/tags/indexes",
+			`{"index_name":"color","is_unique":true,"columns":[{"column_name":"color"}]}`, map[string]string{"table": "tags"}))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Drop index and primary key", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE `tags` DROP INDEX `color`")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE `tags` DROP PRIMARY KEY")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		w := httptest.NewRecorder()
+		app.dropIndexHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags/indexes/color", "",
+			map[string]string{"table": "tags", "index": "color"}))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = httptest.NewRecorder()
+		app.dropIndexHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags/indexes/PRIMARY", "",
+			map[string]string{"table": "tags", "index": "PRIMARY"}))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Execution error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectExec(regexp.QuoteMeta("DROP TABLE `tags`")).WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags", "", map[string]string{"table": "tags"}))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"message":"Error executing DDL: boom"}`, w.Body.String())
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+
+		w := httptest.NewRecorder()
+		app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags", "", map[string]string{"table": "tags"}))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,279 @@
+package crudder
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// svg layout, in pixels
+const (
+	diagramCharWidth    = 7
+	diagramHeaderHeight = 26
+	diagramRowHeight    = 20
+	diagramPadding      = 10
+	diagramGap          = 80
+)
+
+type diagramColumn struct {
+	name     string
+	dataType string
+	keys     []string // PK and/or FK
+}
+
+type diagramTable struct {
+	name    string
+	columns []diagramColumn
+}
+
+// an edge goes from the referencing column to the referenced one
+type diagramEdge struct {
+	name         string
+	fromTable    string
+	fromColumn   string
+	toTable      string
+	toColumn     string
+	fromNullable bool
+}
+
+// buildDiagram extracts the tables, key markers and foreign key edges of a snapshot
+func buildDiagram(snapshot *SchemaSnapshot) ([]diagramTable, []diagramEdge) {
+	tables := make([]diagramTable, 0, len(snapshot.Tables))
+	edges := []diagramEdge{}
+
+	for _, table := range snapshot.Tables {
+		keys := make(map[string][]string)
+		for _, index := range table.Indexes {
+			if index.IsPrimary {
+				for _, c := range index.Columns {
+					keys[c.ColumnName] = append(keys[c.ColumnName], "PK")
+				}
+			}
+		}
+		for _, fk := range table.ForeignKeys {
+			for _, c := range fk.Columns {
+				keys[c] = append(keys[c], "FK")
+			}
+		}
+
+		t := diagramTable{name: table.TableName}
+		nullable := make(map[string]bool, len(table.Columns))
+		for _, col := range table.Columns {
+			t.columns = append(t.columns, diagramColumn{name: col.ColumnName, dataType: col.DataType, keys: uniqueStrings(keys[col.ColumnName])})
+			nullable[col.ColumnName] = col.IsNullable
+		}
+		tables = append(tables, t)
+
+		for _, fk := range table.ForeignKeys {
+			if fk.ReferencedTable == nil || len(fk.Columns) == 0 || len(fk.ReferencedColumns) == 0 {
+				continue
+			}
+			edges = append(edges, diagramEdge{
+				name:         fk.ConstraintName,
+				fromTable:    table.TableName,
+				fromColumn:   fk.Columns[0],
+				toTable:      *fk.ReferencedTable,
+				toColumn:     fk.ReferencedColumns[0],
+				fromNullable: nullable[fk.Columns[0]],
+			})
+		}
+	}
+	return tables, edges
+}
+
+// renderMermaid renders the schema as a Mermaid erDiagram
+func renderMermaid(tables []diagramTable, edges []diagramEdge) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, table := range tables {
+		fmt.Fprintf(&b, "    %s {\n", table.name)
+		for _, col := range table.columns {
+			fmt.Fprintf(&b, "        %s %s", strings.ReplaceAll(col.dataType, " ", "_"), col.name)
+			if len(col.keys) > 0 {
+				b.WriteString(" " + strings.Join(col.keys, ","))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, edge := range edges {
+		// many children to exactly one parent, or to zero or one when the column is nullable
+		parent := "||"
+		if edge.fromNullable {
+			parent = "o|"
+		}
+		fmt.Fprintf(&b, "    %s }o--%s %s : %q\n", edge.fromTable, parent, edge.toTable, edge.name)
+	}
+	return b.String()
+}
+
+// renderDOT renders the schema as a Graphviz digraph with one HTML-like table per node
+func renderDOT(tables []diagramTable, edges []diagramEdge) string {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=plaintext, fontname=\"Helvetica\"];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, table := range tables {
+		fmt.Fprintf(&b, "    %q [label=<<TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\">", table.name)
+		fmt.Fprintf(&b, "<TR><TD BGCOLOR=\"#007bff\"><FONT COLOR=\"white\"><B>%s</B></FONT></TD></TR>", html.EscapeString(table.name))
+		for _, col := range table.columns {
+			fmt.Fprintf(&b, "<TR><TD PORT=%q ALIGN=\"LEFT\">%s</TD></TR>", col.name, html.EscapeString(diagramColumnLabel(col)))
+		}
+		b.WriteString("</TABLE>>];\n")
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "    %q:%q -> %q:%q [label=%q];\n", edge.fromTable, edge.fromColumn, edge.toTable, edge.toColumn, edge.name)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func diagramColumnLabel(col diagramColumn) string {
+	label := col.name + " " + col.dataType
+	if len(col.keys) > 0 {
+		label += " " + strings.Join(col.keys, ",")
+	}
+	return label
+}
+
+type diagramBox struct {
+	x, y, width, height int
+	rows                map[string]int // column name -> row index
+}
+
+// renderSVG lays the tables out on a grid and draws the foreign keys as curves between
+// the referencing and referenced columns, so no external renderer is needed
+func renderSVG(tables []diagramTable, edges []diagramEdge) string {
+	perRow := int(math.Ceil(math.Sqrt(float64(len(tables)))))
+	if perRow == 0 {
+		perRow = 1
+	}
+
+	widths := make([]int, perRow)
+	heights := make([]int, (len(tables)+perRow-1)/perRow)
+	for i, table := range tables {
+		width := len(table.name)
+		for _, col := range table.columns {
+			width = max(width, len(diagramColumnLabel(col)))
+		}
+		widths[i%perRow] = max(widths[i%perRow], width*diagramCharWidth+2*diagramPadding)
+		heights[i/perRow] = max(heights[i/perRow], diagramHeaderHeight+len(table.columns)*diagramRowHeight)
+	}
+
+	boxes := make(map[string]*diagramBox, len(tables))
+	totalWidth, totalHeight := diagramGap/2, diagramGap/2
+	for i, table := range tables {
+		x := diagramGap / 2
+		for c := 0; c < i%perRow; c++ {
+			x += widths[c] + diagramGap
+		}
+		y := diagramGap / 2
+		for r := 0; r < i/perRow; r++ {
+			y += heights[r] + diagramGap
+		}
+		box := &diagramBox{x: x, y: y, width: widths[i%perRow], height: diagramHeaderHeight + len(table.columns)*diagramRowHeight, rows: map[string]int{}}
+		for j, col := range table.columns {
+			box.rows[col.name] = j
+		}
+		boxes[table.name] = box
+		totalWidth = max(totalWidth, x+box.width+diagramGap/2)
+		totalHeight = max(totalHeight, y+box.height+diagramGap/2)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		totalWidth, totalHeight, totalWidth, totalHeight)
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse">` +
+		`<path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>` + "\n")
+
+	for _, edge := range edges {
+		from, to := boxes[edge.fromTable], boxes[edge.toTable]
+		if from == nil || to == nil {
+			continue
+		}
+		y1 := from.y + diagramHeaderHeight + from.rows[edge.fromColumn]*diagramRowHeight + diagramRowHeight/2
+		y2 := to.y + diagramHeaderHeight + to.rows[edge.toColumn]*diagramRowHeight + diagramRowHeight/2
+		// leave from the side facing the referenced table; tables in the same grid column loop around the right side
+		bend := diagramGap / 2
+		x1, c1, x2, c2 := from.x+from.width, from.x+from.width+bend, to.x, to.x-bend
+		switch {
+		case to.x+to.width <= from.x:
+			x1, c1, x2, c2 = from.x, from.x-bend, to.x+to.width, to.x+to.width+bend
+		case to.x == from.x:
+			x2, c2 = to.x+to.width, to.x+to.width+bend
+		}
+		fmt.Fprintf(&b, `<path d="M %d %d C %d %d, %d %d, %d %d" fill="none" stroke="#555" marker-end="url(#arrow)"><title>%s</title></path>`+"\n",
+			x1, y1, c1, y1, c2, y2, x2, y2, html.EscapeString(edge.name))
+	}
+
+	for _, table := range tables {
+		box := boxes[table.name]
+		fmt.Fprintf(&b, `<g id="table-%s">`+"\n", html.EscapeString(table.name))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="white" stroke="#2c3e50"/>`+"\n", box.x, box.y, box.width, box.height)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#007bff"/>`+"\n", box.x, box.y, box.width, diagramHeaderHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="white" font-weight="bold">%s</text>`+"\n",
+			box.x+diagramPadding, box.y+diagramHeaderHeight-8, html.EscapeString(table.name))
+		for j, col := range table.columns {
+			weight := "normal"
+			if len(col.keys) > 0 && col.keys[0] == "PK" {
+				weight = "bold"
+			}
+			fmt.Fprintf(&b, `<text x="%d" y="%d" font-weight="%s">%s</text>`+"\n",
+				box.x+diagramPadding, box.y+diagramHeaderHeight+(j+1)*diagramRowHeight-6, weight, html.EscapeString(diagramColumnLabel(col)))
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// @Summary Schema Diagram
+// @Description Renders an entity-relationship diagram of the current database: every table with its columns, primary and foreign key markers, and one edge per foreign key. Mermaid and DOT are returned as text for external renderers, svg is drawn by crudder itself.
+// @Tags Schema
+// @Produce plain
+// @Produce image/svg+xml
+// @Param format query string false "Output format" Enums(mermaid, dot, svg) default(mermaid)
+// @Success 200 {string} string "Diagram source or SVG image"
+// @Failure 400 {object} map[string]string "Unsupported format"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /schema/diagram [get]
+func (app *App) schemaDiagramHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "mermaid"
+	}
+	if format != "mermaid" && format != "dot" && format != "svg" {
+		WriteErrorResponse(w, http.StatusBadRequest, "Unsupported format, use mermaid, dot or svg")
+		return
+	}
+
+	if app.getDBFromSession(r) == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	snapshot, err := app.currentSnapshot(r)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error reading schema structure")
+		log.Println("error reading schema snapshot:", err)
+		return
+	}
+
+	tables, edges := buildDiagram(app.visibleSnapshot(app.getSession(r), snapshot))
+	switch format {
+	case "dot":
+		w.Header().Set(headerContentType, "text/vnd.graphviz; charset=utf-8")
+		fmt.Fprint(w, renderDOT(tables, edges))
+	case "svg":
+		w.Header().Set(headerContentType, "image/svg+xml")
+		fmt.Fprint(w, renderSVG(tables, edges))
+	default:
+		w.Header().Set(headerContentType, "text/plain; charset=utf-8")
+		fmt.Fprint(w, renderMermaid(tables, edges))
+	}
+}
@@ -0,0 +1,131 @@
+package crudder
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diagramTestSnapshot() *SchemaSnapshot {
+	return &SchemaSnapshot{Schema: "crudder_db_test", Tables: []TableSnapshot{
+		{
+			TableName: "roles",
+			Columns:   []ColumnInfo{{ColumnName: "role_id", DataType: "int"}, {ColumnName: "role", DataType: "varchar"}},
+			Indexes:   []IndexInfo{{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "role_id"}}}},
+		},
+		{
+			TableName: "user_roles",
+			Columns:   []ColumnInfo{{ColumnName: "user_id", DataType: "int"}, {ColumnName: "role_id", DataType: "int", IsNullable: true}},
+			Indexes:   []IndexInfo{{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "user_id"}, {ColumnName: "role_id"}}}},
+			ForeignKeys: []ConstraintInfo{
+				{ConstraintName: "user_roles_ibfk_1", Columns: []string{"user_id"}, ReferencedTable: strPtr("users"), ReferencedColumns: []string{"user_id"}},
+				{ConstraintName: "user_roles_ibfk_2", Columns: []string{"role_id"}, ReferencedTable: strPtr("roles"), ReferencedColumns: []string{"role_id"}},
+			},
+		},
+		{
+			TableName: "users",
+			Columns:   []ColumnInfo{{ColumnName: "user_id", DataType: "int"}, {ColumnName: "username", DataType: "varchar"}},
+			Indexes:   []IndexInfo{{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "user_id"}}}},
+		},
+	}}
+}
+
+func TestRenderMermaid(t *testing.T) {
+	tables, edges := buildDiagram(diagramTestSnapshot())
+
+	assert.Equal(t, `erDiagram
+    roles {
+        int role_id PK
+        varchar role
+    }
+    user_roles {
+        int user_id PK,FK
+        int role_id PK,FK
+    }
+    users {
+        int user_id PK
+        varchar username
+    }
+    user_roles }o--|| users : "user_roles_ibfk_1"
+    user_roles }o--o| roles : "user_roles_ibfk_2"
+`, renderMermaid(tables, edges))
+}
+
+func TestRenderDOT(t *testing.T) {
+	tables, edges := buildDiagram(diagramTestSnapshot())
+	dot := renderDOT(tables, edges)
+
+	assert.True(t, strings.HasPrefix(dot, "digraph schema {\n"))
+	assert.Contains(t, dot, `<TR><TD PORT="role_id" ALIGN="LEFT">role_id int PK,FK</TD></TR>`)
+	assert.Contains(t, dot, `"user_roles":"user_id" -> "users":"user_id" [label="user_roles_ibfk_1"];`)
+	assert.Contains(t, dot, `"user_roles":"role_id" -> "roles":"role_id" [label="user_roles_ibfk_2"];`)
+}
+
+func TestRenderSVG(t *testing.T) {
+	tables, edges := buildDiagram(diagramTestSnapshot())
+	svg := renderSVG(tables, edges)
+
+	// the output must be well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			assert.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+
+	assert.Contains(t, svg, `<g id="table-users">`)
+	assert.Contains(t, svg, `font-weight="bold">user_id int PK</text>`)
+	assert.Equal(t, 2, strings.Count(svg, `marker-end="url(#arrow)"`))
+	assert.Contains(t, svg, `<title>user_roles_ibfk_2</title>`)
+
+	assert.Contains(t, renderSVG(nil, nil), `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="40"`)
+}
+
+func TestSchemaDiagramHandler(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+		contains    string
+	}{
+		{"", "text/plain; charset=utf-8", "users }o--o| roles : \"users_ibfk_1\""},
+		{"dot", "text/vnd.graphviz; charset=utf-8", `"users":"role_id" -> "roles":"role_id"`},
+		{"svg", "image/svg+xml", `<g id="table-roles">`},
+	}
+
+	for _, tt := range tests {
+		t.Run("Format "+tt.format, func(t *testing.T) {
+			app, mock := newMockApp(t)
+			mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+			expectSnapshot(mock, "crudder_db_test", true)
+
+			w := httptest.NewRecorder()
+			app.schemaDiagramHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diagram?format="+tt.format))
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tt.contentType, w.Header().Get(headerContentType))
+			assert.Contains(t, w.Body.String(), tt.contains)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.schemaDiagramHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diagram?format=png"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.schemaDiagramHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diagram"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
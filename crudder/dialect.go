@@ -0,0 +1,373 @@
+package crudder
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect isolates the SQL that differs between database engines. The CRUD routes,
+// table listing and introspection go through it; the admin routes (DDL, migrations,
+// schema diff, routines) are written for MySQL only, see requireDialect.
+type Dialect interface {
+	// Name is the engine chosen at login, e.g. "mysql"
+	Name() string
+	DriverName() string
+	// DSN returns the data source name of a login on the configured server
+	DSN(conn *ConnConfig, username, password, dbName string) string
+	// Placeholder returns the bind parameter for the n-th argument, starting at 1
+	Placeholder(n int) string
+	QuoteIdentifier(name string) string
+	// TablesQuery lists the tables of the current schema as read by listTablesHandler
+	TablesQuery() string
+	// ColumnsQuery reads the columns of a table as scanned by scanColumns
+	ColumnsQuery() string
+	PrimaryKeyQuery() string
+	// Returning is appended to an INSERT to read the generated key back. It is empty
+	// when the driver reports the key through LastInsertId instead.
+	Returning(column string) string
+	// UpsertClause turns an INSERT into an update of the row that has the same key
+	UpsertClause(columns []string, key string) string
+}
+
+var dialects = map[string]Dialect{
+	"mysql":    mysqlDialect{},
+	"postgres": postgresDialect{},
+	"sqlite":   sqliteDialect{},
+}
+
+// dialectFor returns the dialect of the engine chosen at login, MySQL when none is given
+func dialectFor(engine string) (Dialect, bool) {
+	if engine == "" {
+		engine = "mysql"
+	}
+	d, ok := dialects[engine]
+	return d, ok
+}
+
+// Dialect returns the dialect of the session's connection
+func (s *SessionData) Dialect() Dialect {
+	if s.dialect == nil {
+		return mysqlDialect{}
+	}
+	return s.dialect
+}
+
+// Middleware for the routes whose SQL is written for a single engine
+func (app *App) requireDialect(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session := app.getSession(r); session != nil && session.Dialect().Name() != name {
+			WriteErrorResponse(w, http.StatusNotImplemented, fmt.Sprintf(errNotSupported, session.Dialect().Name()))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string       { return "mysql" }
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+func (mysqlDialect) DSN(conn *ConnConfig, username, password, dbName string) string {
+	return conn.mysqlConfig(username, password, dbName).FormatDSN()
+}
+
+func (mysqlDialect) Placeholder(n int) string { return "?" }
+
+func (mysqlDialect) QuoteIdentifier(name string) string { return quoteIdentifier(name) }
+
+func (mysqlDialect) TablesQuery() string {
+	return `
+        SELECT table_name, table_type, engine, table_rows, table_comment
+        FROM information_schema.tables
+        WHERE table_schema = DATABASE()
+        ORDER BY table_name
+    `
+}
+
+func (mysqlDialect) ColumnsQuery() string { return tableColumnsQuery }
+
+func (mysqlDialect) PrimaryKeyQuery() string {
+	return `
+        SELECT COLUMN_NAME
+        FROM information_schema.KEY_COLUMN_USAGE
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+    `
+}
+
+func (mysqlDialect) Returning(column string) string { return "" }
+
+// ON DUPLICATE KEY matches any unique key, not only the primary key
+func (d mysqlDialect) UpsertClause(columns []string, key string) string {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col != key {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", d.QuoteIdentifier(col), d.QuoteIdentifier(col)))
+		}
+	}
+	if len(updates) == 0 {
+		updates = append(updates, fmt.Sprintf("%s = %s", d.QuoteIdentifier(key), d.QuoteIdentifier(key)))
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string       { return "postgres" }
+func (postgresDialect) DriverName() string { return "postgres" }
+
+// DSN maps the TLS mode to sslmode and the dial timeout to connect_timeout; the
+// MySQL only settings are ignored
+func (postgresDialect) DSN(conn *ConnConfig, username, password, dbName string) string {
+	dsn := url.URL{Scheme: "postgres", User: url.UserPassword(username, password), Host: conn.address(5432), Path: "/" + dbName}
+	query := url.Values{}
+	if conn.Socket != "" {
+		dsn.Host = ""
+		query.Set("host", conn.Socket)
+		if conn.Port != 0 {
+			query.Set("port", strconv.Itoa(conn.Port))
+		}
+	}
+	if conn.TLSMode != "" {
+		query.Set("sslmode", conn.TLSMode)
+	}
+	if conn.TLSCA != "" {
+		query.Set("sslrootcert", conn.TLSCA)
+	}
+	if conn.Timeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(int(math.Ceil(conn.Timeout.Seconds()))))
+	}
+	for name, value := range conn.Params {
+		query.Set(name, value)
+	}
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDialect) TablesQuery() string {
+	return `
+        SELECT t.table_name, t.table_type, NULL, NULLIF(cl.reltuples, -1)::bigint,
+               COALESCE(obj_description(cl.oid, 'pg_class'), '')
+        FROM information_schema.tables AS t
+        LEFT JOIN pg_catalog.pg_class AS cl
+        ON cl.oid = (quote_ident(t.table_schema) || '.' || quote_ident(t.table_name))::regclass
+        WHERE t.table_schema = current_schema()
+        ORDER BY t.table_name
+    `
+}
+
+// ColumnsQuery maps the PostgreSQL types to the MySQL names the JSON Schema and the
+// forms understand, reports serial and identity columns as auto_increment and
+// expression defaults as DEFAULT_GENERATED, and strips the cast of literal defaults
+func (postgresDialect) ColumnsQuery() string {
+	return `
+        SELECT
+            c.column_name,
+            CASE
+                WHEN t.typtype = 'e' THEN 'enum'
+                ELSE CASE c.udt_name
+                    WHEN 'int2' THEN 'smallint' WHEN 'int4' THEN 'int' WHEN 'int8' THEN 'bigint'
+                    WHEN 'float4' THEN 'float' WHEN 'float8' THEN 'double' WHEN 'numeric' THEN 'decimal'
+                    WHEN 'bool' THEN 'boolean' WHEN 'bpchar' THEN 'char' WHEN 'bytea' THEN 'blob'
+                    WHEN 'timestamptz' THEN 'timestamp' WHEN 'timetz' THEN 'time' WHEN 'jsonb' THEN 'json'
+                    ELSE c.udt_name
+                END
+            END,
+            c.is_nullable,
+            CASE
+                WHEN c.column_default LIKE 'nextval(%' THEN NULL
+                WHEN c.column_default ~ '^''.*''::' THEN replace(substring(c.column_default from '^''(.*)''::'), '''''', '''')
+                ELSE c.column_default
+            END,
+            EXISTS (
+                SELECT 1 FROM information_schema.table_constraints AS tc
+                JOIN information_schema.key_column_usage AS pk
+                ON pk.constraint_schema = tc.constraint_schema AND pk.constraint_name = tc.constraint_name
+                WHERE tc.table_schema = c.table_schema
+                  AND tc.table_name = c.table_name
+                  AND tc.constraint_type = 'PRIMARY KEY'
+                  AND pk.column_name = c.column_name
+            ),
+            fk.referenced_table,
+            fk.referenced_column,
+            CASE
+                WHEN t.typtype = 'e' THEN 'enum(' || (
+                    SELECT string_agg(quote_literal(e.enumlabel), ',' ORDER BY e.enumsortorder)
+                    FROM pg_catalog.pg_enum AS e WHERE e.enumtypid = t.oid
+                ) || ')'
+                ELSE format_type(a.atttypid, a.atttypmod)
+            END,
+            c.character_maximum_length,
+            c.numeric_precision,
+            c.numeric_scale,
+            CASE
+                WHEN c.column_default LIKE 'nextval(%' OR c.is_identity = 'YES' THEN 'auto_increment'
+                WHEN c.column_default LIKE '%(%' THEN 'DEFAULT_GENERATED'
+                ELSE ''
+            END,
+            CASE WHEN c.is_generated = 'ALWAYS' THEN c.generation_expression END,
+            c.collation_name,
+            COALESCE(col_description(a.attrelid, a.attnum), '')
+        FROM information_schema.columns AS c
+        JOIN pg_catalog.pg_attribute AS a
+        ON a.attrelid = (quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass
+           AND a.attname = c.column_name
+        JOIN pg_catalog.pg_type AS t ON t.oid = a.atttypid
+        LEFT JOIN LATERAL (
+            -- one row per column, even when it takes part in several keys
+            SELECT ccu.table_name AS referenced_table, ccu.column_name AS referenced_column
+            FROM information_schema.table_constraints AS tc
+            JOIN information_schema.key_column_usage AS k
+            ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+            JOIN information_schema.constraint_column_usage AS ccu
+            ON ccu.constraint_schema = tc.constraint_schema AND ccu.constraint_name = tc.constraint_name
+            WHERE tc.constraint_type = 'FOREIGN KEY'
+              AND tc.table_schema = c.table_schema
+              AND tc.table_name = c.table_name
+              AND k.column_name = c.column_name
+            ORDER BY tc.constraint_name
+            LIMIT 1
+        ) AS fk ON true
+        WHERE c.table_schema = current_schema() AND c.table_name = $1
+        ORDER BY c.ordinal_position
+    `
+}
+
+func (postgresDialect) PrimaryKeyQuery() string {
+	return `
+        SELECT k.column_name
+        FROM information_schema.table_constraints AS tc
+        JOIN information_schema.key_column_usage AS k
+        ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+        WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type = 'PRIMARY KEY'
+        ORDER BY k.ordinal_position
+    `
+}
+
+func (d postgresDialect) Returning(column string) string {
+	return " RETURNING " + d.QuoteIdentifier(column)
+}
+
+func (d postgresDialect) UpsertClause(columns []string, key string) string {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col != key {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", d.QuoteIdentifier(col), d.QuoteIdentifier(col)))
+		}
+	}
+	// DO NOTHING would return no row to RETURNING
+	if len(updates) == 0 {
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", d.QuoteIdentifier(key), d.QuoteIdentifier(key)))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", d.QuoteIdentifier(key), strings.Join(updates, ", "))
+}
+
+// sqliteDialect opens the file set in SQLITE_PATH instead of a server login, with the
+// pure Go driver so crudder still builds without CGO
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string       { return "sqlite" }
+func (sqliteDialect) DriverName() string { return "sqlite" }
+
+// DSN ignores the credentials; foreign keys are enforced as on the server engines
+func (sqliteDialect) DSN(conn *ConnConfig, username, password, dbName string) string {
+	return "file:" + conn.SQLitePath + "?_pragma=foreign_keys(1)"
+}
+
+func (sqliteDialect) Placeholder(n int) string { return "?" }
+
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) TablesQuery() string {
+	return `
+        SELECT name, CASE type WHEN 'view' THEN 'VIEW' ELSE 'BASE TABLE' END, NULL, NULL, ''
+        FROM sqlite_master
+        WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+        ORDER BY name
+    `
+}
+
+// ColumnsQuery reads pragma_table_xinfo, binding the table name once as ?1. The
+// declared type is reduced to its first word, INTEGER becoming bigint as SQLite
+// integers are 64 bit, and an INTEGER PRIMARY KEY, an alias of the rowid, is
+// reported as auto_increment. SQLite does not expose the expression of generated
+// columns, they report "generated" instead.
+func (sqliteDialect) ColumnsQuery() string {
+	return `
+        SELECT
+            p.name,
+            CASE t.base
+                WHEN 'int' THEN 'bigint' WHEN 'integer' THEN 'bigint'
+                WHEN 'bool' THEN 'boolean' WHEN 'character' THEN 'char'
+                ELSE t.base
+            END,
+            CASE WHEN p."notnull" = 0 AND p.pk = 0 THEN 'YES' ELSE 'NO' END,
+            CASE
+                WHEN p.dflt_value LIKE '''%''' THEN replace(substr(p.dflt_value, 2, length(p.dflt_value) - 2), '''''', '''')
+                ELSE p.dflt_value
+            END,
+            p.pk > 0,
+            fk."table",
+            COALESCE(fk."to", (SELECT r.name FROM pragma_table_info(fk."table") AS r WHERE r.pk = 1)),
+            lower(p.type),
+            CASE WHEN t.base IN ('char', 'character', 'varchar', 'nchar', 'nvarchar') AND instr(p.type, '(') > 0
+                THEN CAST(substr(p.type, instr(p.type, '(') + 1) AS INTEGER)
+            END,
+            NULL,
+            NULL,
+            CASE
+                WHEN p.pk = 1 AND t.base = 'integer' AND (SELECT count(*) FROM pragma_table_info(?1) WHERE pk > 0) = 1 THEN 'auto_increment'
+                WHEN p.dflt_value LIKE '(%' THEN 'DEFAULT_GENERATED'
+                ELSE ''
+            END,
+            CASE WHEN p.hidden IN (2, 3) THEN 'generated' END,
+            NULL,
+            ''
+        FROM pragma_table_xinfo(?1) AS p
+        JOIN (
+            SELECT cid, lower(trim(CASE WHEN instr(type, '(') > 0 THEN substr(type, 1, instr(type, '(') - 1) ELSE type END)) AS base
+            FROM pragma_table_xinfo(?1)
+        ) AS t ON t.cid = p.cid
+        LEFT JOIN (
+            -- one row per column, even when it takes part in several keys
+            SELECT "from", MIN("table") AS "table", "to" FROM pragma_foreign_key_list(?1) GROUP BY "from"
+        ) AS fk ON fk."from" = p.name
+        WHERE p.hidden <> 1
+        ORDER BY p.cid
+    `
+}
+
+func (sqliteDialect) PrimaryKeyQuery() string {
+	return `SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk`
+}
+
+// Returning is not needed, the driver reports the rowid through LastInsertId
+func (sqliteDialect) Returning(column string) string { return "" }
+
+func (d sqliteDialect) UpsertClause(columns []string, key string) string {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col != key {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", d.QuoteIdentifier(col), d.QuoteIdentifier(col)))
+		}
+	}
+	if len(updates) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", d.QuoteIdentifier(key))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", d.QuoteIdentifier(key), strings.Join(updates, ", "))
+}
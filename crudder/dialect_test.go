@@ -0,0 +1,181 @@
+package crudder
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectFor(t *testing.T) {
+	d, ok := dialectFor("")
+	require.True(t, ok)
+	assert.Equal(t, "mysql", d.Name())
+
+	d, ok = dialectFor("postgres")
+	require.True(t, ok)
+	assert.Equal(t, "postgres", d.Name())
+
+	_, ok = dialectFor("oracle")
+	assert.False(t, ok)
+
+	assert.Equal(t, "mysql", (&SessionData{}).Dialect().Name())
+}
+
+func TestMySQLDialect(t *testing.T) {
+	d := mysqlDialect{}
+	assert.Equal(t, "u:p@tcp(db:3306)/crudder_db_test", d.DSN(&ConnConfig{Host: "db"}, "u", "p", "crudder_db_test"))
+	assert.Equal(t, "?", d.Placeholder(2))
+	assert.Equal(t, "`users`", d.QuoteIdentifier("users"))
+	assert.Empty(t, d.Returning("id"))
+	assert.Equal(t, " ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)", d.UpsertClause([]string{"id", "name"}, "id"))
+	assert.Equal(t, " ON DUPLICATE KEY UPDATE `id` = `id`", d.UpsertClause([]string{"id"}, "id"))
+}
+
+func TestPostgresDialect(t *testing.T) {
+	d := postgresDialect{}
+
+	assert.Equal(t, "postgres://u:p%40ss@db:5432/crudder_db_test", d.DSN(&ConnConfig{Host: "db"}, "u", "p@ss", "crudder_db_test"))
+	assert.Equal(t, "postgres://u:p@db:6432/crudder_db_test?connect_timeout=3&sslmode=verify-full&sslrootcert=%2Fetc%2Fca.pem",
+		d.DSN(&ConnConfig{Host: "db", Port: 6432, TLSMode: "verify-full", TLSCA: "/etc/ca.pem", Timeout: 2500 * time.Millisecond}, "u", "p", "crudder_db_test"))
+	assert.Equal(t, "postgres://u:p@/crudder_db_test?application_name=crudder&host=%2Fvar%2Frun%2Fpostgresql",
+		d.DSN(&ConnConfig{Socket: "/var/run/postgresql", Params: map[string]string{"application_name": "crudder"}}, "u", "p", "crudder_db_test"))
+
+	assert.Equal(t, "$3", d.Placeholder(3))
+	assert.Equal(t, `"my""table"`, d.QuoteIdentifier(`my"table`))
+	assert.Equal(t, ` RETURNING "id"`, d.Returning("id"))
+	assert.Equal(t, ` ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`, d.UpsertClause([]string{"id", "name"}, "id"))
+	assert.Equal(t, ` ON CONFLICT ("id") DO UPDATE SET "id" = EXCLUDED."id"`, d.UpsertClause([]string{"id"}, "id"))
+}
+
+func TestPostgresCrud(t *testing.T) {
+	t.Run("Create reads the key with RETURNING", func(t *testing.T) {
+		app, mock := newMockApp(t, postgresDialect{})
+		mock.ExpectQuery(`FROM information_schema\.columns AS c`).WithArgs("users").WillReturnRows(usersColumnRows())
+		mock.ExpectQuery(`FROM information_schema\.table_constraints AS tc`).WithArgs("users").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "users" ("first_name","last_name") VALUES ($1,$2) RETURNING "id"`)).
+			WithArgs("Ana", "Lima").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(42)))
+
+		w := httptest.NewRecorder()
+		app.createRecord(w, newDDLRequest(http.MethodPost, "/api/v1/crud/users", `{"first_name":"Ana","last_name":"Lima"}`, nil), "users")
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"id":42,"first_name":"Ana","last_name":"Lima"}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		app, mock := newMockApp(t, postgresDialect{})
+		mock.ExpectQuery(`FROM information_schema\.columns AS c`).WithArgs("users").WillReturnRows(usersColumnRows())
+		mock.ExpectQuery(`FROM information_schema\.table_constraints AS tc`).WithArgs("users").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "users" ("first_name","id","last_name") VALUES ($1,$2,$3)`+
+			` ON CONFLICT ("id") DO UPDATE SET "first_name" = EXCLUDED."first_name", "last_name" = EXCLUDED."last_name" RETURNING "id"`)).
+			WithArgs("Ana", int64(7), "Lima").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+
+		w := httptest.NewRecorder()
+		app.createRecord(w, newDDLRequest(http.MethodPost, "/api/v1/crud/users?upsert=true", `{"id":7,"first_name":"Ana","last_name":"Lima"}`, nil), "users")
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Read by id", func(t *testing.T) {
+		app, mock := newMockApp(t, postgresDialect{})
+		mock.ExpectQuery(`FROM information_schema\.table_constraints AS tc`).WithArgs("users").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE "id" = $1`)).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Ana"))
+
+		w := httptest.NewRecorder()
+		app.readRecordByID(w, newDDLRequest(http.MethodGet, "/api/v1/crud/users/1", "", nil), "users", 1)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"id":1,"name":"Ana"}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresView(t *testing.T) {
+	app, mock := newMockApp(t, postgresDialect{})
+	view := &SavedQuery{Name: "by_role", SQL: "SELECT username FROM users WHERE role = :role AND created_at > :since::date"}
+	view.query, view.args = compileNamedQuery(view.SQL)
+	app.SavedQueries = map[string]*SavedQuery{"by_role": view}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE role = $1 AND created_at > $2::date")).
+		WithArgs("admin", "2024-01-01").WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("ana"))
+	mock.ExpectRollback()
+
+	req := newDDLRequest(http.MethodGet, "/api/v1/views/by_role?role=admin&since=2024-01-01", "", map[string]string{"name": "by_role"})
+	w := httptest.NewRecorder()
+	app.viewHandler(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequireDialect(t *testing.T) {
+	app, _ := newMockApp(t, postgresDialect{})
+	handler := SetupRouter(app)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/routines", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.JSONEq(t, `{"message":"Not supported for postgres databases"}`, w.Body.String())
+}
+
+func TestLoginHandlerEngine(t *testing.T) {
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+	t.Setenv("DB_HOST", "db")
+
+	login := func(app *App, engine string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"u"}, "password": {"p"}, "dbname": {"crudder_db_test"}, "engine": {engine}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		app.loginHandler(w, req)
+		return w
+	}
+
+	t.Run("Postgres", func(t *testing.T) {
+		var driver, dsn string
+		sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+			driver, dsn = driverName, dataSourceName
+			db, _, err := sqlmock.New()
+			return db, err
+		}
+
+		app := &App{SessionStore: NewMemoryStore()}
+		w := login(app, "postgres")
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "postgres", driver)
+		assert.Equal(t, "postgres://u:p@db:5432/crudder_db_test", dsn)
+		session := loginSession(t, app, w)
+		assert.Equal(t, "postgres", session.Dialect().Name())
+		assert.Nil(t, session.databases)
+	})
+
+	t.Run("Unknown engine", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := login(app, "oracle")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Unsupported database engine"}`, w.Body.String())
+		assert.Zero(t, app.SessionStore.Len())
+	})
+}
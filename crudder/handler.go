@@ -1,10 +1,15 @@
 package crudder
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -20,8 +25,29 @@ type ServerInterface interface {
 
 type RealServer struct{}
 
+// shutdownTimeout is how long the requests in flight get to finish on SIGINT or SIGTERM
+const shutdownTimeout = 10 * time.Second
+
+// ListenAndServe serves until the process gets SIGINT or SIGTERM, then stops accepting
+// connections and waits for the requests in flight before returning
 func (r RealServer) ListenAndServe(addr string, handler http.Handler) error {
-	return http.ListenAndServe(addr, handler)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down the server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
 }
 
 var tmpl *template.Template
@@ -40,11 +66,43 @@ func SetupRouter(app *App) *mux.Router {
 	apiRouter := router.PathPrefix("/api/v1").Subrouter()
 
 	apiRouter.HandleFunc("/login", app.loginHandler).Methods("POST")
-	apiRouter.HandleFunc("/logout", app.logoutHandler).Methods("GET")
+	apiRouter.HandleFunc("/logout", app.logoutHandler).Methods("POST")
+	apiRouter.HandleFunc("/profiles", app.listProfilesHandler).Methods("GET")
+	apiRouter.Handle("/tokens", app.authMiddleware(http.HandlerFunc(app.listTokensHandler))).Methods("GET")
+	apiRouter.Handle("/tokens", app.authMiddleware(http.HandlerFunc(app.createTokenHandler))).Methods("POST")
+	apiRouter.Handle("/tokens/{id:[0-9a-f]+}", app.authMiddleware(http.HandlerFunc(app.revokeTokenHandler))).Methods("DELETE")
 	apiRouter.Handle("/crud/{table}", app.authMiddleware(http.HandlerFunc(app.crudHandler))).Methods("POST", "GET")
 	apiRouter.Handle("/crud/{table}/{id:[0-9]+}", app.authMiddleware(http.HandlerFunc(app.crudHandler))).Methods("GET", "PUT", "DELETE")
 	apiRouter.Handle("/tables", app.authMiddleware(http.HandlerFunc(app.listTablesHandler)))
+	apiRouter.Handle("/databases", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.listDatabasesHandler)))).Methods("GET")
+	apiRouter.Handle("/databases/{schema}/use", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.useDatabaseHandler)))).Methods("POST")
+	apiRouter.Handle("/databases/{schema}/tables", app.authMiddleware(app.requireDialect("mysql", app.databaseMiddleware(http.HandlerFunc(app.listTablesHandler))))).Methods("GET")
+	apiRouter.Handle("/databases/{schema}/crud/{table}", app.authMiddleware(app.requireDialect("mysql", app.databaseMiddleware(http.HandlerFunc(app.crudHandler))))).Methods("POST", "GET")
+	apiRouter.Handle("/databases/{schema}/crud/{table}/{id:[0-9]+}", app.authMiddleware(app.requireDialect("mysql", app.databaseMiddleware(http.HandlerFunc(app.crudHandler))))).Methods("GET", "PUT", "DELETE")
+	apiRouter.Handle("/routines", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.listRoutinesHandler)))).Methods("GET")
+	apiRouter.Handle("/call/{procedure}", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.callProcedureHandler)))).Methods("POST")
 	apiRouter.Handle("/table-structure", app.authMiddleware(http.HandlerFunc(app.tableStructureHandler)))
+	apiRouter.Handle("/table-structure/{table}/indexes", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.tableIndexesHandler)))).Methods("GET")
+	apiRouter.Handle("/table-structure/{table}/constraints", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.tableConstraintsHandler)))).Methods("GET")
+	apiRouter.Handle("/schema/tables", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.createTableHandler)))).Methods("POST")
+	apiRouter.Handle("/schema/tables/{table}", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.dropTableHandler)))).Methods("DELETE")
+	apiRouter.Handle("/schema/tables/{table}/columns", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.addColumnHandler)))).Methods("POST")
+	apiRouter.Handle("/schema/tables/{table}/columns/{column}", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.modifyColumnHandler)))).Methods("PUT")
+	apiRouter.Handle("/schema/tables/{table}/columns/{column}", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.dropColumnHandler)))).Methods("DELETE")
+	apiRouter.Handle("/schema/tables/{table}/indexes", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.createIndexHandler)))).Methods("POST")
+	apiRouter.Handle("/schema/tables/{table}/indexes/{index}", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.dropIndexHandler)))).Methods("DELETE")
+	apiRouter.Handle("/schema/refresh", app.authMiddleware(http.HandlerFunc(app.refreshSchemaCacheHandler))).Methods("POST")
+	apiRouter.Handle("/schema/snapshot", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.schemaSnapshotHandler)))).Methods("GET")
+	apiRouter.Handle("/schema/diff", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.schemaDiffHandler)))).Methods("GET")
+	apiRouter.Handle("/schema/diff", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.snapshotDiffHandler)))).Methods("POST")
+	apiRouter.Handle("/schema/{table:[A-Za-z0-9_-]+}.json", app.authMiddleware(http.HandlerFunc(app.tableJSONSchemaHandler))).Methods("GET")
+	apiRouter.Handle("/openapi.json", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.openAPIHandler)))).Methods("GET")
+	apiRouter.Handle("/schema/diagram", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.schemaDiagramHandler)))).Methods("GET")
+	apiRouter.Handle("/migrations", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.migrationStatusHandler)))).Methods("GET")
+	apiRouter.Handle("/migrations/up", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.migrateUpHandler)))).Methods("POST")
+	apiRouter.Handle("/migrations/down", app.authMiddleware(app.requireDialect("mysql", http.HandlerFunc(app.migrateDownHandler)))).Methods("POST")
+	apiRouter.Handle("/views", app.authMiddleware(http.HandlerFunc(app.listViewsHandler))).Methods("GET")
+	apiRouter.Handle("/views/{name}", app.authMiddleware(http.HandlerFunc(app.viewHandler))).Methods("GET")
 
 	router.HandleFunc("/login", app.loginPageHandler)
 	router.HandleFunc("/welcome", app.welcomePageHandler)
@@ -53,7 +111,7 @@ func SetupRouter(app *App) *mux.Router {
 	router.HandleFunc("/table-crud-edit", app.tableCrudEditPageHandler)
 	router.HandleFunc("/table-crud-delete", app.tableCrudDeletePageHandler)
 
-	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(httpSwagger.UIConfig(map[string]string{"urls": swaggerUIURLs})))
 	return router
 }
 
@@ -68,45 +126,55 @@ func (app *App) loginPageHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) welcomePageHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
-		Title string
+		Title     string
+		CSRFToken string
 	}{
-		Title: "Crudder Go:: welcome",
+		Title:     "Crudder Go:: welcome",
+		CSRFToken: app.pageCSRFToken(r),
 	}
 	renderTemplate(w, "base.html", "welcome.html", data)
 }
 
 func (app *App) tableCrudPageHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
-		Title string
+		Title     string
+		CSRFToken string
 	}{
-		Title: "Crudder Go:: ",
+		Title:     "Crudder Go:: ",
+		CSRFToken: app.pageCSRFToken(r),
 	}
 	renderTemplate(w, "base.html", "table_crud.html", data)
 }
 
 func (app *App) tableCrudEditPageHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
-		Title string
+		Title     string
+		CSRFToken string
 	}{
-		Title: "Crudder Go:: ",
+		Title:     "Crudder Go:: ",
+		CSRFToken: app.pageCSRFToken(r),
 	}
 	renderTemplate(w, "base.html", "table_crud_edit.html", data)
 }
 
 func (app *App) tableCrudAddPageHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
-		Title string
+		Title     string
+		CSRFToken string
 	}{
-		Title: "Crudder Go:: ",
+		Title:     "Crudder Go:: ",
+		CSRFToken: app.pageCSRFToken(r),
 	}
 	renderTemplate(w, "base.html", "table_crud_add.html", data)
 }
 
 func (app *App) tableCrudDeletePageHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
-		Title string
+		Title     string
+		CSRFToken string
 	}{
-		Title: "Crudder Go:: ",
+		Title:     "Crudder Go:: ",
+		CSRFToken: app.pageCSRFToken(r),
 	}
 	renderTemplate(w, "base.html", "table_crud_delete.html", data)
 }
@@ -136,13 +204,77 @@ func StartServer(server ServerInterface) {
 		log.Println(".env file not found")
 	}
 
+	// A broken file would silently serve no views at all
+	savedQueries, err := LoadSavedQueries(os.Getenv("SAVED_QUERIES_FILE"))
+	if err != nil {
+		log.Fatal("error loading saved queries: ", err)
+	}
+
+	// A broken directory would report that there is nothing to migrate
+	migrations, err := LoadMigrations(os.Getenv("MIGRATIONS_DIR"))
+	if err != nil {
+		log.Fatal("error loading migrations: ", err)
+	}
+
+	schemaTTL := defaultSchemaCacheTTL
+	if value := os.Getenv("SCHEMA_CACHE_TTL"); value != "" {
+		if schemaTTL, err = time.ParseDuration(value); err != nil {
+			log.Println("invalid SCHEMA_CACHE_TTL:", err)
+			schemaTTL = defaultSchemaCacheTTL
+		}
+	}
+
+	conn, err := LoadConnConfig()
+	if err != nil {
+		log.Fatal("invalid database connection settings: ", err)
+	}
+
+	cookie, err := LoadCookieConfig()
+	if err != nil {
+		log.Fatal("invalid session cookie settings: ", err)
+	}
+
+	// A policy that cannot be read must not leave every table open
+	policy, err := LoadPolicy(os.Getenv("POLICY_FILE"))
+	if err != nil {
+		log.Fatal("error loading permissions policy: ", err)
+	}
+
+	// Without its profiles the server would drop their database restrictions
+	profiles, err := LoadProfiles(os.Getenv("PROFILES_FILE"), conn)
+	if err != nil {
+		log.Fatal("error loading server profiles: ", err)
+	}
+
 	app := &App{
-		SessionStore: make(map[string]*SessionData),
+		SavedQueries: savedQueries,
+		Migrations:   migrations,
+		SchemaTTL:    schemaTTL,
+		Conn:         conn,
+		Profiles:     profiles,
+		Cookie:       cookie,
+		Policy:       policy,
+
+		SessionIdleTimeout: durationFromEnv("SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout),
+		SessionMaxAge:      durationFromEnv("SESSION_MAX_AGE", defaultSessionMaxAge),
+		LoginLimiter:       LoadLoginLimiter(),
 	}
 
+	if app.SessionStore, err = app.loadSessionStore(); err != nil {
+		log.Fatal("invalid session store settings: ", err)
+	}
+
+	// Expired sessions are closed in the background; the rest when the server stops
+	ctx, stopReaper := context.WithCancel(context.Background())
+	reaperDone := app.startSessionReaper(ctx, sessionReapInterval)
+
 	router := SetupRouter(app)
 	log.Println("Server running at http://localhost:9091")
-	err := server.ListenAndServe(":9091", router)
+	err = server.ListenAndServe(":9091", router)
+
+	stopReaper()
+	<-reaperDone
+	app.SessionStore.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
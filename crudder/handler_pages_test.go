@@ -114,7 +114,7 @@ func TestPageHandlers_Render200(t *testing.T) {
 	restore := createMinimalTemplates(t)
 	defer restore()
 
-	app := &App{SessionStore: map[string]*SessionData{}}
+	app := &App{SessionStore: NewMemoryStore()}
 
 	cases := []struct {
 		name   string
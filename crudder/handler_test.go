@@ -54,7 +54,7 @@ func TestLoadEnv(t *testing.T) {
 // TestSetupRouter verifica a configuração do roteador
 func TestSetupRouter(t *testing.T) {
 	app := &App{
-		SessionStore: make(map[string]*SessionData),
+		SessionStore: NewMemoryStore(),
 	}
 
 	router := SetupRouter(app)
@@ -73,7 +73,7 @@ func TestSetupRouter(t *testing.T) {
 	// Adiciona uma sessão mockada
 	dbMock, _, _ := sqlmock.New()
 	sessionToken := "mockSession"
-	app.SessionStore[sessionToken] = &SessionData{DB: dbMock}
+	app.SessionStore.Save(hashSessionToken(sessionToken), &SessionData{DB: dbMock})
 
 	tests := []struct {
 		name       string
@@ -84,7 +84,7 @@ func TestSetupRouter(t *testing.T) {
 		statusCode int
 	}{
 		{"Login Route", "POST", "/api/v1/login", "username=mockuser&password=mockpassword&dbname=mockdb", nil, http.StatusOK},
-		{"Logout Route", "GET", "/api/v1/logout", "", &http.Cookie{Name: "session_token", Value: sessionToken}, http.StatusOK},
+		{"Logout Route", "POST", "/api/v1/logout", "", &http.Cookie{Name: "session_token", Value: sessionToken}, http.StatusOK},
 		{"CRUD Route - POST", "POST", "/api/v1/crud/test", "", nil, http.StatusUnauthorized},
 	}
 
@@ -100,6 +100,7 @@ func TestSetupRouter(t *testing.T) {
 
 			if tt.cookie != nil {
 				req.AddCookie(tt.cookie)
+				req.Header.Set(csrfHeader, csrfToken(tt.cookie.Value))
 			}
 
 			rec := httptest.NewRecorder()
@@ -132,12 +133,12 @@ func TestStartServer(t *testing.T) {
 func TestRoutes(t *testing.T) {
 	t.Skip("covered by TestSetupRouter (SetupRouter uses /api/v1 paths)")
 	app := &App{
-		SessionStore: make(map[string]*SessionData),
+		SessionStore: NewMemoryStore(),
 	}
 
 	router := mux.NewRouter()
 	router.HandleFunc("/login", app.loginHandler).Methods("POST")
-	router.HandleFunc("/logout", app.logoutHandler).Methods("GET")
+	router.HandleFunc("/logout", app.logoutHandler).Methods("POST")
 	router.Handle("/crud/{table}", app.authMiddleware(http.HandlerFunc(app.crudHandler))).Methods("POST", "GET")
 	router.Handle("/crud/{table}/{id:[0-9]+}", app.authMiddleware(http.HandlerFunc(app.crudHandler))).Methods("GET", "PUT", "DELETE")
 	router.Handle("/tables", app.authMiddleware(http.HandlerFunc(app.listTablesHandler)))
@@ -157,7 +158,7 @@ func TestRoutes(t *testing.T) {
 	// Adiciona uma sessão mockada com sqlmock
 	dbMock, _, _ := sqlmock.New()
 	sessionToken := "mockSession"
-	app.SessionStore[sessionToken] = &SessionData{DB: dbMock}
+	app.SessionStore.Save(hashSessionToken(sessionToken), &SessionData{DB: dbMock})
 
 	tests := []struct {
 		name       string
@@ -168,7 +169,7 @@ func TestRoutes(t *testing.T) {
 		statusCode int
 	}{
 		{"Login Route", "POST", "/api/v1/login", "username=testuser&password=testpass&dbname=testdb", nil, http.StatusOK},
-		{"Logout Route", "GET", "/api/v1/logout", "", &http.Cookie{Name: "session_token", Value: sessionToken}, http.StatusOK},
+		{"Logout Route", "POST", "/api/v1/logout", "", &http.Cookie{Name: "session_token", Value: sessionToken}, http.StatusOK},
 		{"CRUD Route - POST", "POST", "/api/v1/crud/test", "", nil, http.StatusUnauthorized},
 		{"CRUD Route - GET", "GET", "/crud/test", "", nil, http.StatusUnauthorized},
 		{"CRUD ID Route - GET", "GET", "/crud/test/1", "", nil, http.StatusUnauthorized},
@@ -190,6 +191,7 @@ func TestRoutes(t *testing.T) {
 
 			if tt.cookie != nil {
 				req.AddCookie(tt.cookie)
+				req.Header.Set(csrfHeader, csrfToken(tt.cookie.Value))
 			}
 
 			rec := httptest.NewRecorder()
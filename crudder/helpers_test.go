@@ -0,0 +1,24 @@
+package crudder
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockApp returns an app whose "mockSession" runs on sqlmock, opened with the
+// given dialect or with the default (MySQL) one
+func newMockApp(t *testing.T, dialect ...Dialect) (*App, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	session := &SessionData{DB: db}
+	if len(dialect) > 0 {
+		session.dialect = dialect[0]
+	}
+	return &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): session})}, mock
+}
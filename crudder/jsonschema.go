@@ -0,0 +1,431 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// MySQL renders DATETIME/TIMESTAMP and TIME values in these shapes, which are not RFC 3339.
+// With DB_PARSE_TIME the datetimes come back as RFC 3339 instead, with nanoseconds and a zone.
+const (
+	dateTimePattern = `^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d{1,9})?(Z|[+-]\d{2}:\d{2})?$`
+	timePattern     = `^-?\d{1,3}:\d{2}(:\d{2}(\.\d{1,6})?)?$`
+)
+
+// size in bits of the integer types with a fixed range
+var integerBits = map[string]uint{"tinyint": 8, "smallint": 16, "mediumint": 24, "int": 32, "integer": 32}
+
+// a number without exponent, as BIGINT and DECIMAL values are sent to keep their digits
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// JSONSchema is the subset of JSON Schema (draft 2020-12) produced for a table and
+// enforced on the bodies of the create and update endpoints
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"` // a type name, or a list when the column is nullable
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	MaxLength            *int64                 `json:"maxLength,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+
+	// exact bounds and scale used to validate values, as a float64 cannot hold the
+	// limits of BIGINT and wide DECIMAL columns
+	minimum, maximum *big.Rat
+	scale            *int64
+}
+
+// struct represents a single value of a request body that does not match the table schema
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// struct represents the 400 response of a create or update rejected by the table schema
+type ValidationErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// tableJSONSchema builds the JSON Schema of a table from its column metadata
+func tableJSONSchema(tableName string, columns []ColumnInfo) *JSONSchema {
+	additional := false
+	schema := &JSONSchema{
+		Schema:               jsonSchemaDraft,
+		ID:                   fmt.Sprintf("/api/v1/schema/%s.json", tableName),
+		Title:                tableName,
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema, len(columns)),
+		Required:             []string{},
+		AdditionalProperties: &additional,
+	}
+
+	for _, col := range columns {
+		schema.Properties[col.ColumnName] = columnJSONSchema(col)
+		if !col.IsNullable && col.ColumnDefault == nil && !col.IsAutoIncrement && col.GenerationExpression == nil {
+			schema.Required = append(schema.Required, col.ColumnName)
+		}
+	}
+	return schema
+}
+
+// columnJSONSchema maps the MySQL type of a column to a JSON Schema
+func columnJSONSchema(col ColumnInfo) *JSONSchema {
+	prop := &JSONSchema{
+		Title:       col.ColumnName,
+		Description: col.ColumnComment,
+		ReadOnly:    col.IsAutoIncrement || col.GenerationExpression != nil,
+	}
+	dataType := strings.ToLower(col.DataType)
+	unsigned := strings.Contains(strings.ToLower(col.ColumnType), "unsigned")
+
+	jsonType := ""
+	switch dataType {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "bit", "year":
+		jsonType = "integer"
+		if bits, ok := integerBits[dataType]; ok {
+			minimum, maximum := -math.Pow(2, float64(bits-1)), math.Pow(2, float64(bits-1))-1
+			if unsigned {
+				minimum, maximum = 0, math.Pow(2, float64(bits))-1
+			}
+			prop.Minimum, prop.Maximum = &minimum, &maximum
+		} else if unsigned || dataType == "bit" || dataType == "year" {
+			minimum := 0.0
+			prop.Minimum = &minimum
+		}
+		prop.minimum, prop.maximum = integerRange(dataType, unsigned)
+	case "decimal", "numeric", "float", "double", "real":
+		jsonType = "number"
+		if unsigned {
+			minimum := 0.0
+			prop.Minimum = &minimum
+			prop.minimum = new(big.Rat)
+		}
+		if (dataType == "decimal" || dataType == "numeric") && col.NumericPrecision != nil && col.NumericScale != nil {
+			maximum := math.Pow(10, float64(*col.NumericPrecision-*col.NumericScale)) - math.Pow(10, -float64(*col.NumericScale))
+			prop.Maximum = &maximum
+			// (10^precision - 1) / 10^scale, such as 999.99 for DECIMAL(5,2)
+			prop.maximum = new(big.Rat).SetFrac(new(big.Int).Sub(pow10(*col.NumericPrecision), big.NewInt(1)), pow10(*col.NumericScale))
+			if !unsigned {
+				prop.minimum = new(big.Rat).Neg(prop.maximum)
+			}
+			prop.scale = col.NumericScale
+		}
+	case "enum":
+		jsonType = "string"
+		for _, v := range col.EnumValues {
+			prop.Enum = append(prop.Enum, v)
+		}
+		if col.IsNullable {
+			prop.Enum = append(prop.Enum, nil)
+		}
+	case "set":
+		jsonType = "string"
+		members := make([]string, len(col.EnumValues))
+		for i, v := range col.EnumValues {
+			members[i] = regexp.QuoteMeta(v)
+		}
+		member := "(?:" + strings.Join(members, "|") + ")"
+		prop.Pattern = "^$|^" + member + "(?:," + member + ")*$"
+	case "boolean":
+		jsonType = "boolean"
+	case "date":
+		jsonType = "string"
+		prop.Format = "date"
+	case "datetime", "timestamp":
+		jsonType = "string"
+		prop.Pattern = dateTimePattern
+	case "time":
+		jsonType = "string"
+		prop.Pattern = timePattern
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext",
+		"binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		jsonType = "string"
+		prop.MaxLength = col.CharacterMaxLength
+	}
+	// json and spatial columns accept any value
+
+	if jsonType != "" {
+		if col.IsNullable {
+			prop.Type = []string{jsonType, "null"}
+		} else {
+			prop.Type = jsonType
+		}
+	}
+
+	// expression defaults such as CURRENT_TIMESTAMP have no JSON equivalent
+	if col.ColumnDefault != nil && !defaultKeywordPattern.MatchString(*col.ColumnDefault) &&
+		!strings.Contains(strings.ToUpper(col.Extra), "DEFAULT_GENERATED") {
+		prop.Default = *col.ColumnDefault
+		switch jsonType {
+		case "integer":
+			if v, err := strconv.ParseInt(*col.ColumnDefault, 10, 64); err == nil {
+				prop.Default = v
+			}
+		case "number":
+			if v, err := strconv.ParseFloat(*col.ColumnDefault, 64); err == nil {
+				prop.Default = v
+			}
+		case "boolean":
+			if v, err := strconv.ParseBool(*col.ColumnDefault); err == nil {
+				prop.Default = v
+			}
+		}
+	}
+	return prop
+}
+
+// integerRange returns the exact bounds of an integer type, nil where it has none
+func integerRange(dataType string, unsigned bool) (*big.Rat, *big.Rat) {
+	bits, ok := integerBits[dataType]
+	if dataType == "bigint" {
+		bits, ok = 64, true
+	}
+	if !ok {
+		if unsigned || dataType == "bit" || dataType == "year" {
+			return new(big.Rat), nil
+		}
+		return nil, nil
+	}
+	if unsigned {
+		maximum := new(big.Int).Lsh(big.NewInt(1), bits)
+		return new(big.Rat), new(big.Rat).SetInt(maximum.Sub(maximum, big.NewInt(1)))
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), bits-1)
+	minimum := new(big.Rat).SetInt(new(big.Int).Neg(limit))
+	return minimum, new(big.Rat).SetInt(limit.Sub(limit, big.NewInt(1)))
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+// numericValue reads a number decoded as float64 or json.Number, or a string holding
+// one, without losing digits
+func numericValue(value interface{}) (*big.Rat, bool) {
+	var s string
+	switch v := value.(type) {
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		s = string(v)
+		// a big exponent would make big.Rat build a huge power of ten
+		if strings.ContainsAny(s, "eE") {
+			f, err := v.Float64()
+			if err != nil {
+				return nil, false
+			}
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case string:
+		s = v
+	default:
+		return nil, false
+	}
+	if !decimalPattern.MatchString(s) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// validate checks a request body against a table schema. With partial set, as for
+// updates, required columns may be left out.
+func (schema *JSONSchema) validate(item map[string]interface{}, partial bool) []FieldError {
+	errs := []FieldError{}
+	if !partial {
+		for _, name := range schema.Required {
+			if _, ok := item[name]; !ok {
+				errs = append(errs, FieldError{Field: name, Message: "is required"})
+			}
+		}
+	}
+	for name, value := range item {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			errs = append(errs, FieldError{Field: name, Message: "is not a column of the table"})
+			continue
+		}
+		if message := prop.validateValue(value); message != "" {
+			errs = append(errs, FieldError{Field: name, Message: message})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// validateValue checks a single decoded JSON value and returns why it is invalid, or ""
+func (prop *JSONSchema) validateValue(value interface{}) string {
+	types := []string{}
+	switch t := prop.Type.(type) {
+	case string:
+		types = append(types, t)
+	case []string:
+		types = t
+	}
+	if len(types) == 0 {
+		return ""
+	}
+
+	if value == nil {
+		if types[len(types)-1] == "null" {
+			return ""
+		}
+		return "must not be null"
+	}
+
+	if len(prop.Enum) > 0 {
+		for _, v := range prop.Enum {
+			if v == value {
+				return ""
+			}
+		}
+		return "must be one of the allowed values"
+	}
+
+	switch types[0] {
+	case "integer", "number":
+		// numbers may also come as strings, so BIGINT and DECIMAL values keep all digits
+		n, ok := numericValue(value)
+		if ok && types[0] == "integer" && !n.IsInt() {
+			ok = false
+		}
+		if !ok && types[0] == "integer" {
+			return "must be an integer"
+		}
+		if !ok {
+			return "must be a number"
+		}
+		if prop.scale != nil && !new(big.Rat).Mul(n, new(big.Rat).SetInt(pow10(*prop.scale))).IsInt() {
+			return fmt.Sprintf("must have at most %d decimal places", *prop.scale)
+		}
+		if prop.minimum != nil && n.Cmp(prop.minimum) < 0 {
+			return "must be at least " + prop.bound(prop.minimum)
+		}
+		if prop.maximum != nil && n.Cmp(prop.maximum) > 0 {
+			return "must be at most " + prop.bound(prop.maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if prop.MaxLength != nil && int64(utf8.RuneCountInString(s)) > *prop.MaxLength {
+			return fmt.Sprintf("must be at most %d characters", *prop.MaxLength)
+		}
+		if prop.Format == "date" {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return "must be a date (YYYY-MM-DD)"
+			}
+		}
+		if prop.Pattern != "" {
+			if matched, err := regexp.MatchString(prop.Pattern, s); err == nil && !matched {
+				return "does not match the expected format"
+			}
+		}
+	}
+	return ""
+}
+
+// bound formats an exact bound with the scale of the column
+func (prop *JSONSchema) bound(r *big.Rat) string {
+	if r.IsInt() || prop.scale == nil {
+		return r.RatString()
+	}
+	return r.FloatString(int(*prop.scale))
+}
+
+// validateRecord checks a create or update body against the schema of the table,
+// writing the error response itself when the body is rejected
+func validateRecord(w http.ResponseWriter, session *SessionData, tableName string, item map[string]interface{}, partial bool) bool {
+	columns, err := session.schema.columns(session.DB, session.Dialect(), tableName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table structure")
+		log.Println("error fetching columns:", err)
+		return false
+	}
+	if len(columns) == 0 {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return false
+	}
+
+	if errs := tableJSONSchema(tableName, columns).validate(item, partial); len(errs) > 0 {
+		writeJSONResponseWithStatus(w, http.StatusBadRequest, ValidationErrorResponse{
+			Message: "Request body does not match the table schema",
+			Errors:  errs,
+		})
+		return false
+	}
+	return true
+}
+
+// @Summary Table JSON Schema
+// @Description Returns a JSON Schema (draft 2020-12) of a table, built from column types, nullability, defaults, enum members and maximum lengths. The create and update endpoints validate request bodies against this schema.
+// @Tags Schema
+// @Produce json
+// @Param table path string true "Table name" default(users)
+// @Success 200 {object} JSONSchema "JSON Schema of the table"
+// @Failure 400 {object} map[string]string "Invalid table name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /schema/{table}.json [get]
+func (app *App) tableJSONSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	tableName := mux.Vars(r)["table"]
+	if !isAlphaNumeric(tableName) {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	session := app.getSession(r)
+	if session == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	access := app.tableAccess(session, tableName)
+	if !access.visible() {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return
+	}
+
+	columns, err := fetchTableColumns(session.DB, session.Dialect(), tableName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table structure")
+		log.Println("error fetching columns:", err)
+		return
+	}
+	if len(columns) == 0 {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return
+	}
+
+	w.Header().Set(headerContentType, "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tableJSONSchema(tableName, access.visibleColumns(columns)))
+}
@@ -0,0 +1,208 @@
+package crudder
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jsonSchemaTestColumns() []ColumnInfo {
+	return []ColumnInfo{
+		{ColumnName: "id", DataType: "int", ColumnType: "int unsigned", IsAutoIncrement: true, Extra: "auto_increment", IsPrimaryKey: true},
+		{ColumnName: "username", DataType: "varchar", ColumnType: "varchar(100)", CharacterMaxLength: int64Ptr(100), ColumnComment: "login name"},
+		{ColumnName: "status", DataType: "enum", ColumnType: "enum('active','blocked')", EnumValues: []string{"active", "blocked"},
+			IsNullable: true, ColumnDefault: strPtr("active")},
+		{ColumnName: "level", DataType: "tinyint", ColumnType: "tinyint", ColumnDefault: strPtr("1")},
+		{ColumnName: "balance", DataType: "decimal", ColumnType: "decimal(5,2)", NumericPrecision: int64Ptr(5), NumericScale: int64Ptr(2), IsNullable: true},
+		{ColumnName: "born_on", DataType: "date", ColumnType: "date", IsNullable: true},
+		{ColumnName: "created_at", DataType: "timestamp", ColumnType: "timestamp", ColumnDefault: strPtr("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED"},
+		{ColumnName: "roles", DataType: "set", ColumnType: "set('read','write')", EnumValues: []string{"read", "write"}, ColumnDefault: strPtr("")},
+		{ColumnName: "settings", DataType: "json", ColumnType: "json", IsNullable: true},
+	}
+}
+
+func TestTableJSONSchema(t *testing.T) {
+	schema := tableJSONSchema("users", jsonSchemaTestColumns())
+
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema.Schema)
+	assert.Equal(t, "/api/v1/schema/users.json", schema.ID)
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"username"}, schema.Required)
+	require.NotNil(t, schema.AdditionalProperties)
+	assert.False(t, *schema.AdditionalProperties)
+
+	id := schema.Properties["id"]
+	assert.Equal(t, "integer", id.Type)
+	assert.True(t, id.ReadOnly)
+	assert.Equal(t, 0.0, *id.Minimum)
+	assert.Equal(t, 4294967295.0, *id.Maximum)
+
+	username := schema.Properties["username"]
+	assert.Equal(t, "string", username.Type)
+	assert.Equal(t, int64(100), *username.MaxLength)
+	assert.Equal(t, "login name", username.Description)
+
+	status := schema.Properties["status"]
+	assert.Equal(t, []string{"string", "null"}, status.Type)
+	assert.Equal(t, []interface{}{"active", "blocked", nil}, status.Enum)
+	assert.Equal(t, "active", status.Default)
+
+	level := schema.Properties["level"]
+	assert.Equal(t, int64(1), level.Default)
+	assert.Equal(t, -128.0, *level.Minimum)
+	assert.Equal(t, 127.0, *level.Maximum)
+
+	assert.Equal(t, 999.99, *schema.Properties["balance"].Maximum)
+	assert.Equal(t, "date", schema.Properties["born_on"].Format)
+	assert.Nil(t, schema.Properties["created_at"].Default)
+	assert.Equal(t, dateTimePattern, schema.Properties["created_at"].Pattern)
+	assert.Equal(t, "^$|^(?:read|write)(?:,(?:read|write))*$", schema.Properties["roles"].Pattern)
+	assert.Nil(t, schema.Properties["settings"].Type)
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema := tableJSONSchema("users", jsonSchemaTestColumns())
+
+	tests := []struct {
+		name     string
+		item     map[string]interface{}
+		partial  bool
+		expected []FieldError
+	}{
+		{"Valid", map[string]interface{}{"username": "ana", "status": nil, "level": 5.0, "balance": 10.5,
+			"born_on": "1990-05-01", "created_at": "2024-01-02 03:04:05", "roles": "read,write", "settings": map[string]interface{}{"a": 1.0}}, false, []FieldError{}},
+		{"Missing required", map[string]interface{}{}, false, []FieldError{{"username", "is required"}}},
+		{"Partial update", map[string]interface{}{"level": 2.0}, true, []FieldError{}},
+		{"Unknown column", map[string]interface{}{"username": "ana", "email": "x"}, false, []FieldError{{"email", "is not a column of the table"}}},
+		{"Wrong types", map[string]interface{}{"username": 1.0, "level": "five", "balance": true}, true, []FieldError{
+			{"balance", "must be a number"}, {"level", "must be an integer"}, {"username", "must be a string"}}},
+		{"Null on NOT NULL", map[string]interface{}{"username": nil}, true, []FieldError{{"username", "must not be null"}}},
+		{"Limits", map[string]interface{}{"username": strings.Repeat("a", 101), "level": 128.0, "balance": 1000.0, "id": 1.5}, true, []FieldError{
+			{"balance", "must be at most 999.99"}, {"id", "must be an integer"}, {"level", "must be at most 127"}, {"username", "must be at most 100 characters"}}},
+		{"Enum and formats", map[string]interface{}{"status": "deleted", "born_on": "01/05/1990", "created_at": "yesterday", "roles": "admin"}, true, []FieldError{
+			{"born_on", "must be a date (YYYY-MM-DD)"}, {"created_at", "does not match the expected format"}, {"roles", "does not match the expected format"},
+			{"status", "must be one of the allowed values"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, schema.validate(tt.item, tt.partial))
+		})
+	}
+}
+
+func TestJSONSchemaExactNumbers(t *testing.T) {
+	schema := tableJSONSchema("ledger", []ColumnInfo{
+		{ColumnName: "id", DataType: "bigint", ColumnType: "bigint"},
+		{ColumnName: "hits", DataType: "bigint", ColumnType: "bigint unsigned"},
+		{ColumnName: "amount", DataType: "decimal", ColumnType: "decimal(20,2)", NumericPrecision: int64Ptr(20), NumericScale: int64Ptr(2)},
+	})
+
+	// digits beyond float64 are kept, whether sent as JSON numbers or strings
+	var item map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(`{"id":9007199254740993,"hits":"18446744073709551615","amount":"-123456789012345678.99"}`))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&item))
+	assert.Equal(t, []FieldError{}, schema.validate(item, false))
+
+	numberValues(item)
+	assert.Equal(t, int64(9007199254740993), item["id"])
+	assert.Equal(t, "18446744073709551615", item["hits"])
+	assert.Equal(t, "-123456789012345678.99", item["amount"])
+
+	assert.Equal(t, []FieldError{
+		{"amount", "must be at most 999999999999999999.99"},
+		{"hits", "must be at least 0"},
+		{"id", "must be at most 9223372036854775807"},
+	}, schema.validate(map[string]interface{}{"id": json.Number("9223372036854775808"), "hits": "-1", "amount": "1000000000000000000"}, false))
+
+	assert.Equal(t, []FieldError{{"amount", "must have at most 2 decimal places"}, {"hits", "must be an integer"}, {"id", "must be an integer"}},
+		schema.validate(map[string]interface{}{"id": "1e3", "hits": "1.5", "amount": json.Number("1.005")}, false))
+}
+
+func TestJSONSchemaParseTime(t *testing.T) {
+	schema := tableJSONSchema("users", jsonSchemaTestColumns())
+
+	// a DATETIME scanned with DB_PARSE_TIME, as GET returns it, can be sent back
+	for _, value := range []time.Time{
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.FixedZone("", -3*60*60)),
+	} {
+		encoded, err := json.Marshal(map[string]interface{}{"created_at": value})
+		require.NoError(t, err)
+		var item map[string]interface{}
+		require.NoError(t, json.Unmarshal(encoded, &item))
+		assert.Empty(t, schema.validate(item, true), item["created_at"])
+	}
+	assert.NotEmpty(t, schema.validate(map[string]interface{}{"created_at": "2024-01-02 03:04:05+3"}, true))
+}
+
+func TestTableJSONSchemaHandler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+
+		w := httptest.NewRecorder()
+		app.tableJSONSchemaHandler(w, newTableRequest("/api/v1/schema/users.json", "users"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/schema+json", w.Header().Get(headerContentType))
+		assert.JSONEq(t, `{
+			"$schema":"https://json-schema.org/draft/2020-12/schema","$id":"/api/v1/schema/users.json","title":"users","type":"object",
+			"properties":{
+				"id":{"title":"id","type":"integer","minimum":-2147483648,"maximum":2147483647,"readOnly":true},
+				"name":{"title":"name","type":["string","null"],"maxLength":100},
+				"first_name":{"title":"first_name","type":"string","maxLength":50},
+				"last_name":{"title":"last_name","type":"string","maxLength":50}
+			},
+			"required":["first_name","last_name"],"additionalProperties":false
+		}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Table not found", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("missing").WillReturnRows(sqlmock.NewRows(tableStructureColumns))
+
+		w := httptest.NewRecorder()
+		app.tableJSONSchemaHandler(w, newTableRequest("/api/v1/schema/missing.json", "missing"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.tableJSONSchemaHandler(w, newTableRequest("/api/v1/schema/users.json", "users"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Invalid table name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.tableJSONSchemaHandler(w, newTableRequest("/api/v1/schema/x.json", "bad$name"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Route", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("user_roles").WillReturnRows(usersColumnRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/schema/user_roles.json", nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+		w := httptest.NewRecorder()
+		SetupRouter(app).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
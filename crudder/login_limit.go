@@ -0,0 +1,248 @@
+package crudder
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultLoginMaxAttempts   = 5
+	defaultLoginMaxAttemptsIP = 20
+	defaultLoginWindow        = 15 * time.Minute
+	defaultLoginLockout       = time.Minute
+	defaultLoginMaxLockout    = time.Hour
+)
+
+// LoginLimiter slows down password guessing against /login. Failed attempts are
+// counted per client IP and per database user; once a counter reaches its threshold
+// every further failure locks the IP or the user out, for a time that doubles with
+// each failure up to MaxLockout. An attempt is counted before the database checks the
+// password and given back when the login succeeds or fails for another reason than
+// the credentials. A successful login clears the counter of the user.
+type LoginLimiter struct {
+	MaxAttempts   int           // LOGIN_MAX_ATTEMPTS: failures of a user before lockouts start, 0 disables
+	MaxAttemptsIP int           // LOGIN_MAX_ATTEMPTS_IP: failures of an IP before lockouts start, 0 disables
+	Window        time.Duration // LOGIN_ATTEMPT_WINDOW: failures older than this are forgotten
+	Lockout       time.Duration // LOGIN_LOCKOUT: first lockout, doubled on every further failure
+	MaxLockout    time.Duration // LOGIN_MAX_LOCKOUT: longest lockout
+	TrustProxy    bool          // TRUST_PROXY: take the client IP from X-Forwarded-For
+
+	mutex    sync.Mutex
+	failures map[string]*loginFailures
+	pruned   time.Time
+}
+
+// loginFailures counts the recent failed logins of an IP or a user
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// LoadLoginLimiter reads the login thresholds from the environment
+func LoadLoginLimiter() *LoginLimiter {
+	trustProxy, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY"))
+	return &LoginLimiter{
+		MaxAttempts:   intFromEnv("LOGIN_MAX_ATTEMPTS", defaultLoginMaxAttempts),
+		MaxAttemptsIP: intFromEnv("LOGIN_MAX_ATTEMPTS_IP", defaultLoginMaxAttemptsIP),
+		Window:        durationFromEnv("LOGIN_ATTEMPT_WINDOW", defaultLoginWindow),
+		Lockout:       durationFromEnv("LOGIN_LOCKOUT", defaultLoginLockout),
+		MaxLockout:    durationFromEnv("LOGIN_MAX_LOCKOUT", defaultLoginMaxLockout),
+		TrustProxy:    trustProxy,
+	}
+}
+
+// intFromEnv reads a non-negative integer from the environment, falling back to def
+// when it is unset or invalid
+func intFromEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %q, using %d", name, value, def)
+		return def
+	}
+	return n
+}
+
+// clientIP returns the address /login counts the attempts of r against. Behind a
+// reverse proxy (TRUST_PROXY=true) it is the last X-Forwarded-For entry, the one
+// added by the proxy itself; the earlier ones are up to the client.
+func (l *LoginLimiter) clientIP(r *http.Request) string {
+	if l.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ipKey(ip string) string     { return "ip\x00" + ip }
+func userKey(user string) string { return "user\x00" + user }
+
+// retryAfter returns how long the IP or the user has to wait before trying again,
+// zero when neither is locked out
+func (l *LoginLimiter) retryAfter(ip, user string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.lockedFor(ip, user, now)
+}
+
+// lockedFor is retryAfter for a caller holding the mutex
+func (l *LoginLimiter) lockedFor(ip, user string, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, key := range []string{ipKey(ip), userKey(user)} {
+		if f := l.failures[key]; f != nil && f.lockedUntil.After(now) {
+			wait = max(wait, f.lockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// attempt checks that neither the IP nor the user is locked out and counts the login
+// as failed in the same step, so concurrent guesses cannot all get past the check. It
+// returns how long to wait when the login is refused, or else the failures of the user
+// with the lockout the attempt causes if it fails.
+func (l *LoginLimiter) attempt(ip, user string, now time.Time) (wait time.Duration, failures int, lockout time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if wait := l.lockedFor(ip, user, now); wait > 0 {
+		return wait, 0, 0
+	}
+	if l.failures == nil {
+		l.failures = make(map[string]*loginFailures)
+	}
+	l.prune(now)
+
+	_, ipLockout := l.count(ipKey(ip), l.MaxAttemptsIP, now)
+	failures, lockout = l.count(userKey(user), l.MaxAttempts, now)
+	return 0, failures, max(lockout, ipLockout)
+}
+
+// count adds a failure to a counter, locking it out past threshold; the caller holds
+// the mutex
+func (l *LoginLimiter) count(key string, threshold int, now time.Time) (int, time.Duration) {
+	f := l.failures[key]
+	if f == nil || now.Sub(f.last) > l.Window && !f.lockedUntil.After(now) {
+		f = &loginFailures{}
+		l.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	if threshold == 0 || f.count < threshold {
+		return f.count, 0
+	}
+	lockout := l.lockoutAfter(f.count - threshold)
+	f.lockedUntil = now.Add(lockout)
+	return f.count, lockout
+}
+
+// lockoutAfter returns the lockout for the nth failure past the threshold
+func (l *LoginLimiter) lockoutAfter(n int) time.Duration {
+	d := float64(l.Lockout) * math.Pow(2, float64(min(n, 32)))
+	if l.MaxLockout > 0 && d > float64(l.MaxLockout) {
+		return l.MaxLockout
+	}
+	return time.Duration(d)
+}
+
+// refund gives back an attempt that did not fail on the credentials, lifting the
+// lockouts it caused
+func (l *LoginLimiter) refund(ip, user string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.uncount(ipKey(ip), l.MaxAttemptsIP)
+	l.uncount(userKey(user), l.MaxAttempts)
+}
+
+// uncount removes a failure from a counter; the caller holds the mutex
+func (l *LoginLimiter) uncount(key string, threshold int) {
+	f := l.failures[key]
+	if f == nil {
+		return
+	}
+	if f.count--; f.count < threshold {
+		f.lockedUntil = time.Time{}
+	}
+}
+
+// succeed gives back the attempt of a successful login and clears the failures of
+// the user
+func (l *LoginLimiter) succeed(ip, user string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.uncount(ipKey(ip), l.MaxAttemptsIP)
+	delete(l.failures, userKey(user))
+}
+
+// isAuthError reports whether the database refused a login because of its
+// credentials, the only failures the LoginLimiter counts
+func isAuthError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1045 // ER_ACCESS_DENIED_ERROR
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "28P01" || pqErr.Code == "28000" // invalid_password, invalid_authorization_specification
+	}
+	return false
+}
+
+// prune forgets the counters that are neither recent nor locked, at most once per
+// Window; the caller holds the mutex
+func (l *LoginLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < l.Window {
+		return
+	}
+	l.pruned = now
+	for key, f := range l.failures {
+		if now.Sub(f.last) > l.Window && !f.lockedUntil.After(now) {
+			delete(l.failures, key)
+		}
+	}
+}
+
+// logFailedLogin writes a failed attempt as a structured log record
+func logFailedLogin(r *http.Request, ip string, login *sessionLogin, failures int, lockout time.Duration) {
+	attrs := []any{
+		slog.String("ip", ip),
+		slog.String("engine", login.Engine),
+		slog.String("username", login.Username),
+		slog.String("database", login.Database),
+		slog.Int("failures", failures),
+		slog.String("user_agent", r.UserAgent()),
+	}
+	if login.Profile != "" {
+		attrs = append(attrs, slog.String("profile", login.Profile))
+	}
+	if lockout > 0 {
+		attrs = append(attrs, slog.Duration("lockout", lockout))
+	}
+	slog.Warn("login failed", attrs...)
+}
+
+// writeTooManyAttempts answers a locked out login with 429 and the seconds to wait
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	WriteErrorResponse(w, http.StatusTooManyRequests, errTooManyLogins)
+}
@@ -0,0 +1,191 @@
+package crudder
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter() *LoginLimiter {
+	return &LoginLimiter{MaxAttempts: 3, MaxAttemptsIP: 10, Window: 15 * time.Minute, Lockout: time.Minute, MaxLockout: 5 * time.Minute}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Lockouts double past the threshold", func(t *testing.T) {
+		l := newTestLimiter()
+		var lockouts []time.Duration
+		var lockout time.Duration
+		at := now
+		for i := 0; i < 7; i++ {
+			at = at.Add(lockout)
+			var wait time.Duration
+			wait, _, lockout = l.attempt("10.0.0.1", "ana", at)
+			require.Zero(t, wait)
+			lockouts = append(lockouts, lockout)
+		}
+		assert.Equal(t, []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}, lockouts)
+		assert.Equal(t, 5*time.Minute, l.retryAfter("10.0.0.2", "ana", at), "the user is locked out from any IP")
+		assert.Equal(t, 2*time.Minute, l.retryAfter("10.0.0.2", "ana", at.Add(3*time.Minute)))
+		assert.Zero(t, l.retryAfter("10.0.0.2", "bob", at), "other users are not")
+
+		wait, _, _ := l.attempt("10.0.0.2", "ana", at.Add(time.Minute))
+		assert.Equal(t, 4*time.Minute, wait, "locked out attempts are refused")
+	})
+
+	t.Run("Lockouts outlive the window", func(t *testing.T) {
+		l := newTestLimiter()
+		l.failures = map[string]*loginFailures{userKey("ana"): {count: 6, last: now, lockedUntil: now.Add(time.Hour)}}
+		l.mutex.Lock()
+		failures, lockout := l.count(userKey("ana"), l.MaxAttempts, now.Add(30*time.Minute))
+		l.mutex.Unlock()
+		assert.Equal(t, 7, failures, "a failure past the window but during the lockout does not start over")
+		assert.Equal(t, 5*time.Minute, lockout)
+	})
+
+	t.Run("Per IP", func(t *testing.T) {
+		l := newTestLimiter()
+		for i := 0; i < 10; i++ {
+			l.attempt("10.0.0.1", "user"+string(rune('a'+i)), now)
+		}
+		assert.Equal(t, time.Minute, l.retryAfter("10.0.0.1", "zoe", now))
+		assert.Zero(t, l.retryAfter("10.0.0.2", "zoe", now))
+	})
+
+	t.Run("Old failures are forgotten", func(t *testing.T) {
+		l := newTestLimiter()
+		l.attempt("10.0.0.1", "ana", now)
+		l.attempt("10.0.0.1", "ana", now)
+		_, failures, lockout := l.attempt("10.0.0.1", "ana", now.Add(time.Hour))
+		assert.Equal(t, 1, failures)
+		assert.Zero(t, lockout)
+	})
+
+	t.Run("Success clears the user", func(t *testing.T) {
+		l := newTestLimiter()
+		for i := 0; i < 3; i++ {
+			l.attempt("10.0.0.1", "ana", now)
+		}
+		l.succeed("10.0.0.1", "ana")
+		assert.Zero(t, l.retryAfter("10.0.0.1", "ana", now), "the successful attempt lifts its lockout")
+		_, failures, _ := l.attempt("10.0.0.1", "ana", now)
+		assert.Equal(t, 1, failures)
+	})
+
+	t.Run("Refunds", func(t *testing.T) {
+		l := newTestLimiter()
+		for i := 0; i < 5; i++ {
+			l.attempt("10.0.0.1", "ana", now)
+			l.refund("10.0.0.1", "ana")
+		}
+		_, failures, lockout := l.attempt("10.0.0.1", "ana", now)
+		assert.Equal(t, 1, failures)
+		assert.Zero(t, lockout)
+	})
+
+	t.Run("Concurrent attempts", func(t *testing.T) {
+		l := newTestLimiter()
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if wait, _, _ := l.attempt("10.0.0.1", "ana", now); wait == 0 {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(3), allowed.Load(), "only the attempts up to the threshold reach the database")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		l := &LoginLimiter{Window: time.Minute, Lockout: time.Minute}
+		for i := 0; i < 100; i++ {
+			l.attempt("10.0.0.1", "ana", now)
+		}
+		assert.Zero(t, l.retryAfter("10.0.0.1", "ana", now))
+	})
+}
+
+func TestIsAuthError(t *testing.T) {
+	assert.True(t, isAuthError(&mysql.MySQLError{Number: 1045, Message: "Access denied"}))
+	assert.True(t, isAuthError(fmt.Errorf("ping: %w", &pq.Error{Code: "28P01"})))
+	assert.False(t, isAuthError(&mysql.MySQLError{Number: 1049, Message: "Unknown database"}))
+	assert.False(t, isAuthError(errors.New("dial tcp: connection refused")))
+}
+
+func TestLoginLimiterClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", nil)
+	req.RemoteAddr = "192.0.2.1:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	assert.Equal(t, "192.0.2.1", (&LoginLimiter{}).clientIP(req))
+	assert.Equal(t, "198.51.100.7", (&LoginLimiter{TrustProxy: true}).clientIP(req))
+}
+
+func TestLoadLoginLimiter(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	t.Setenv("LOGIN_MAX_ATTEMPTS_IP", "-1")
+	t.Setenv("LOGIN_LOCKOUT", "30s")
+	l := LoadLoginLimiter()
+	assert.Equal(t, 3, l.MaxAttempts)
+	assert.Equal(t, defaultLoginMaxAttemptsIP, l.MaxAttemptsIP)
+	assert.Equal(t, 30*time.Second, l.Lockout)
+	assert.Equal(t, defaultLoginMaxLockout, l.MaxLockout)
+}
+
+func TestLoginHandlerLockout(t *testing.T) {
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+	opened := 0
+	var pingErr error = &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+	sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+		opened++
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		mock.ExpectPing().WillReturnError(pingErr)
+		return db, err
+	}
+
+	app := &App{SessionStore: NewMemoryStore(), Conn: &ConnConfig{Host: "db"}, LoginLimiter: newTestLimiter()}
+	login := func() *httptest.ResponseRecorder {
+		form := url.Values{"username": {"ana"}, "password": {"guess"}, "dbname": {"crudder_db_test"}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		app.loginHandler(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login().Code)
+	}
+	w := login()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"message":"Too many failed logins, try again later"}`, w.Body.String())
+	assert.Equal(t, 3, opened, "locked out logins do not reach the database")
+
+	// failures that have nothing to do with the password are not counted
+	app.LoginLimiter = newTestLimiter()
+	pingErr = errors.New("dial tcp: connection refused")
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login().Code)
+	}
+}
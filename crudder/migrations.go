@@ -0,0 +1,471 @@
+package crudder
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const migrationsTable = "schema_migrations"
+
+// MySQL named lock taken by migrate up and down, so that servers sharing a database
+// never apply the same migration twice, and how many seconds a run waits for it
+const (
+	migrationLockName = "crudder_migrations"
+	migrationLockWait = 10
+)
+
+// migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_-]+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change loaded from the migrations directory
+type Migration struct {
+	Version int64
+	Name    string
+
+	up   []string
+	down []string
+}
+
+// struct represents the state of a migration in the current database
+type MigrationStatus struct {
+	Version   int64   `json:"version"`
+	Name      string  `json:"name"`
+	Applied   bool    `json:"applied"`
+	AppliedAt *string `json:"applied_at,omitempty"`
+	HasDown   bool    `json:"has_down"`
+}
+
+// struct represents the outcome of a migrate up or migrate down run
+type MigrationResult struct {
+	Message    string            `json:"message"`
+	Migrations []MigrationStatus `json:"migrations"`
+}
+
+// LoadMigrations reads the up/down SQL files of a migrations directory, sorted by version.
+// Every migration needs an up file; the down file is optional but required to roll it back.
+func LoadMigrations(dir string) ([]*Migration, error) {
+	if dir == "" {
+		return []*Migration{}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), err)
+		}
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d (%s and %s)", version, m.Name, match[2])
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		statements := splitStatements(string(content))
+		if match[3] == "up" {
+			m.up = statements
+		} else {
+			m.down = statements
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements splits a SQL script on ';', ignoring semicolons inside quotes and comments.
+// Comment-only fragments are dropped; DELIMITER blocks are not supported.
+func splitStatements(script string) []string {
+	statements := []string{}
+	var current strings.Builder
+	hasCode := false
+
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) && script[end] != c {
+				if script[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(script) {
+				end = len(script) - 1
+			}
+			current.WriteString(script[i : end+1])
+			hasCode = true
+			i = end
+		case c == '#' || isLineComment(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			current.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			i += end + 3
+			current.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasCode = true
+			}
+		}
+	}
+	flush()
+	return statements
+}
+
+// isLineComment reports whether s starts with a '-- ' comment; MySQL requires whitespace after the dashes
+func isLineComment(s string) bool {
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] == ' ' || s[2] == '\t' || s[2] == '\n' || s[2] == '\r')
+}
+
+// ensureMigrationsTable creates the tracking table on first use
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+            version BIGINT NOT NULL PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+	return err
+}
+
+// isMissingTable reports whether MySQL refused a query because its table does not exist
+func isMissingTable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 // ER_NO_SUCH_TABLE
+}
+
+// fetchAppliedMigrations returns the applied_at timestamp of every applied migration, keyed by version
+func fetchAppliedMigrations(db *sql.DB) (map[int64]string, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM " + migrationsTable + " ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes one direction of a migration and records it in the tracking table,
+// all in a single transaction. Note that MySQL commits DDL statements implicitly.
+func runMigration(db *sql.DB, m *Migration, up bool) error {
+	statements := m.down
+	record, recordArgs := "DELETE FROM "+migrationsTable+" WHERE version = ?", []interface{}{m.Version}
+	if up {
+		statements = m.up
+		record, recordArgs = "INSERT INTO "+migrationsTable+" (version, name) VALUES (?, ?)", []interface{}{m.Version, m.Name}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(record, recordArgs...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrationStatus(m *Migration, applied map[int64]string) MigrationStatus {
+	status := MigrationStatus{Version: m.Version, Name: m.Name, HasDown: m.down != nil}
+	if appliedAt, ok := applied[m.Version]; ok {
+		status.Applied = true
+		status.AppliedAt = &appliedAt
+	}
+	return status
+}
+
+// parseSteps reads the optional 'steps' query parameter; 0 means no limit
+func parseSteps(r *http.Request, def int) (int, bool) {
+	value := r.URL.Query().Get("steps")
+	if value == "" {
+		return def, true
+	}
+	steps, err := strconv.Atoi(value)
+	if err != nil || steps < 0 {
+		return 0, false
+	}
+	return steps, true
+}
+
+// migrationsDB returns the session database when the policy allows migrations,
+// writing the error response itself otherwise
+func (app *App) migrationsDB(w http.ResponseWriter, r *http.Request) (*sql.DB, bool) {
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return nil, false
+	}
+	// migrations may touch any table, so POLICY_FILE must grant alter on "*"
+	if !app.tableAccess(app.getSession(r), policyWildcard).allows(opAlter) {
+		WriteErrorResponse(w, http.StatusForbidden, errMigrateDenied)
+		return nil, false
+	}
+	return db, true
+}
+
+// lockMigrations takes the migration lock on a connection of its own and returns the
+// function releasing it, writing the error response itself when the lock is not taken
+func lockMigrations(w http.ResponseWriter, r *http.Request, db *sql.DB) (func(), bool) {
+	conn, err := db.Conn(r.Context())
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return nil, false
+	}
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(r.Context(), "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockWait).Scan(&locked); err != nil {
+		conn.Close()
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return nil, false
+	}
+	if locked.Int64 != 1 {
+		conn.Close()
+		WriteErrorResponse(w, http.StatusConflict, "Another migration run is in progress")
+		return nil, false
+	}
+	return func() {
+		// the lock belongs to the connection, which goes back to the pool
+		if _, err := conn.ExecContext(r.Context(), "SELECT RELEASE_LOCK(?)", migrationLockName); err != nil {
+			log.Println("error releasing migration lock:", err)
+		}
+		conn.Close()
+	}, true
+}
+
+// prepareMigrations takes the migration lock and returns the session database with
+// the tracking table in place, the applied migrations and the function releasing the
+// lock, writing the error response itself on failure
+func (app *App) prepareMigrations(w http.ResponseWriter, r *http.Request) (*sql.DB, map[int64]string, func(), bool) {
+	db, ok := app.migrationsDB(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	unlock, ok := lockMigrations(w, r, db)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	if err := ensureMigrationsTable(db); err != nil {
+		unlock()
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return nil, nil, nil, false
+	}
+	applied, err := fetchAppliedMigrations(db)
+	if err != nil {
+		unlock()
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return nil, nil, nil, false
+	}
+	return db, applied, unlock, true
+}
+
+// @Summary Migration Status
+// @Description Lists the migrations found in the migrations directory and whether each one is applied to the current database.
+// @Tags Migrations
+// @Produce json
+// @Success 200 {array} MigrationStatus "Status of every migration"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /migrations [get]
+func (app *App) migrationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	db, ok := app.migrationsDB(w, r)
+	if !ok {
+		return
+	}
+	// a read does not create the tracking table; without it nothing is applied
+	applied, err := fetchAppliedMigrations(db)
+	if isMissingTable(err) {
+		applied, err = map[int64]string{}, nil
+	}
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return
+	}
+
+	statuses := make([]MigrationStatus, 0, len(app.Migrations))
+	for _, m := range app.Migrations {
+		statuses = append(statuses, migrationStatus(m, applied))
+	}
+	writeJSONResponseWithStatus(w, http.StatusOK, statuses)
+}
+
+// @Summary Migrate Up
+// @Description Applies pending migrations in version order, each one in its own transaction, holding the crudder_migrations lock of the database. Stops at the first failure; migrations applied before it stay applied.
+// @Tags Migrations
+// @Produce json
+// @Param steps query int false "Maximum number of migrations to apply, 0 applies all pending" default(0)
+// @Success 200 {object} MigrationResult "Applied migrations"
+// @Failure 400 {object} map[string]string "Invalid steps"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 409 {object} map[string]string "Another migration run is in progress"
+// @Failure 500 {object} map[string]string "Migration failed"
+// @Router /migrations/up [post]
+func (app *App) migrateUpHandler(w http.ResponseWriter, r *http.Request) {
+	steps, ok := parseSteps(r, 0)
+	if !ok {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	db, applied, unlock, ok := app.prepareMigrations(w, r)
+	if !ok {
+		return
+	}
+	defer unlock()
+	// even a failed run may have changed the schema
+	defer app.invalidateSchemaCache(r)
+
+	result := MigrationResult{Migrations: []MigrationStatus{}}
+	for _, m := range app.Migrations {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+		if steps > 0 && len(result.Migrations) == steps {
+			break
+		}
+		if err := runMigration(db, m, true); err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Migration %d_%s failed: %v", m.Version, m.Name, err))
+			log.Println("error applying migration", m.Version, err)
+			return
+		}
+		result.Migrations = append(result.Migrations, MigrationStatus{Version: m.Version, Name: m.Name, Applied: true, HasDown: m.down != nil})
+	}
+
+	result.Message = fmt.Sprintf("%d migration(s) applied", len(result.Migrations))
+	writeJSONResponseWithStatus(w, http.StatusOK, result)
+}
+
+// @Summary Migrate Down
+// @Description Rolls back applied migrations in reverse version order, each one in its own transaction, holding the crudder_migrations lock of the database.
+// @Tags Migrations
+// @Produce json
+// @Param steps query int false "Number of migrations to roll back, 0 rolls back all" default(1)
+// @Success 200 {object} MigrationResult "Rolled back migrations"
+// @Failure 400 {object} map[string]string "Invalid steps"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not allowed by POLICY_FILE"
+// @Failure 409 {object} map[string]string "Applied migration has no down file, or another migration run is in progress"
+// @Failure 500 {object} map[string]string "Migration failed"
+// @Router /migrations/down [post]
+func (app *App) migrateDownHandler(w http.ResponseWriter, r *http.Request) {
+	steps, ok := parseSteps(r, 1)
+	if !ok {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	db, applied, unlock, ok := app.prepareMigrations(w, r)
+	if !ok {
+		return
+	}
+	defer unlock()
+	// even a failed run may have changed the schema
+	defer app.invalidateSchemaCache(r)
+
+	known := make(map[int64]*Migration, len(app.Migrations))
+	for _, m := range app.Migrations {
+		known[m.Version] = m
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if steps > 0 && steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	rollback := make([]*Migration, 0, len(versions))
+	for _, version := range versions {
+		m, exists := known[version]
+		if !exists || m.down == nil {
+			WriteErrorResponse(w, http.StatusConflict, fmt.Sprintf("Migration %d cannot be rolled back: no down file", version))
+			return
+		}
+		rollback = append(rollback, m)
+	}
+
+	result := MigrationResult{Migrations: []MigrationStatus{}}
+	for _, m := range rollback {
+		if err := runMigration(db, m, false); err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Migration %d_%s failed: %v", m.Version, m.Name, err))
+			log.Println("error rolling back migration", m.Version, err)
+			return
+		}
+		result.Migrations = append(result.Migrations, MigrationStatus{Version: m.Version, Name: m.Name, HasDown: true})
+	}
+
+	result.Message = fmt.Sprintf("%d migration(s) rolled back", len(result.Migrations))
+	writeJSONResponseWithStatus(w, http.StatusOK, result)
+}
@@ -0,0 +1,298 @@
+package crudder
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations`
+	appliedMigrations     = `SELECT version, applied_at FROM schema_migrations ORDER BY version`
+	insertMigration       = `INSERT INTO schema_migrations \(version, name\) VALUES \(\?, \?\)`
+	deleteMigration       = `DELETE FROM schema_migrations WHERE version = \?`
+	getMigrationLock      = `SELECT GET_LOCK\(\?, \?\)`
+	releaseMigrationLock  = `SELECT RELEASE_LOCK\(\?\)`
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)", []string{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)"}},
+		{"-- create; things\nSELECT 1; # trailing; comment\n/* block; */ SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT 1--2;", []string{"SELECT 1--2"}},
+		{"-- only a comment\n;\n", []string{}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, splitStatements(tt.input), tt.input)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Empty path", func(t *testing.T) {
+		migrations, err := LoadMigrations("")
+		require.NoError(t, err)
+		assert.Empty(t, migrations)
+	})
+
+	t.Run("Valid directory", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "0002_add_email.up.sql"), "ALTER TABLE users ADD email VARCHAR(255);")
+		writeFile(t, filepath.Join(dir, "0001_create_users.up.sql"), "CREATE TABLE users (id INT);\nCREATE INDEX idx ON users (id);")
+		writeFile(t, filepath.Join(dir, "0001_create_users.down.sql"), "DROP TABLE users;")
+		writeFile(t, filepath.Join(dir, "README.md"), "not a migration")
+
+		migrations, err := LoadMigrations(dir)
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "create_users", migrations[0].Name)
+		assert.Equal(t, []string{"CREATE TABLE users (id INT)", "CREATE INDEX idx ON users (id)"}, migrations[0].up)
+		assert.Equal(t, []string{"DROP TABLE users"}, migrations[0].down)
+		assert.Equal(t, int64(2), migrations[1].Version)
+		assert.Nil(t, migrations[1].down)
+	})
+
+	t.Run("Missing up file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "0001_orphan.down.sql"), "DROP TABLE x;")
+
+		_, err := LoadMigrations(dir)
+		assert.Error(t, err)
+	})
+
+	t.Run("Duplicate version", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "0001_a.up.sql"), "SELECT 1;")
+		writeFile(t, filepath.Join(dir, "1_b.up.sql"), "SELECT 1;")
+
+		_, err := LoadMigrations(dir)
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing directory", func(t *testing.T) {
+		_, err := LoadMigrations(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
+
+func newMigrationTestApp(t *testing.T) (*App, sqlmock.Sqlmock) {
+	app, mock := newMockApp(t)
+	app.Migrations = []*Migration{
+		{Version: 1, Name: "create_users", up: []string{"CREATE TABLE users (id INT)"}, down: []string{"DROP TABLE users"}},
+		{Version: 2, Name: "add_email", up: []string{"ALTER TABLE users ADD email VARCHAR(255)"}},
+		{Version: 3, Name: "create_roles", up: []string{"CREATE TABLE roles (id INT)"}, down: []string{"DROP TABLE roles"}},
+	}
+	return app, mock
+}
+
+func newMigrationRequest(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	return req
+}
+
+func expectAppliedMigrations(mock sqlmock.Sqlmock, versions ...int64) {
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range versions {
+		rows.AddRow(v, "2026-01-02 03:04:05")
+	}
+	mock.ExpectQuery(appliedMigrations).WillReturnRows(rows)
+}
+
+// expectMigrationRun expects migrate up or down to take the migration lock, create the
+// tracking table and read the applied versions
+func expectMigrationRun(mock sqlmock.Sqlmock, versions ...int64) {
+	mock.ExpectQuery(getMigrationLock).WithArgs("crudder_migrations", 10).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+	mock.ExpectExec(createMigrationsTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectAppliedMigrations(mock, versions...)
+}
+
+func expectMigrationUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(releaseMigrationLock).WithArgs("crudder_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrationStatusHandler(t *testing.T) {
+	app, mock := newMigrationTestApp(t)
+	expectAppliedMigrations(mock, 1)
+
+	w := httptest.NewRecorder()
+	app.migrationStatusHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/migrations"))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[
+		{"version":1,"name":"create_users","applied":true,"applied_at":"2026-01-02 03:04:05","has_down":true},
+		{"version":2,"name":"add_email","applied":false,"has_down":false},
+		{"version":3,"name":"create_roles","applied":false,"has_down":true}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	t.Run("Without the tracking table", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		mock.ExpectQuery(appliedMigrations).WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table 'schema_migrations' doesn't exist"})
+
+		w := httptest.NewRecorder()
+		app.migrationStatusHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/migrations"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), `"applied":true`)
+		assert.NoError(t, mock.ExpectationsWereMet(), "the table is not created")
+	})
+}
+
+func TestMigrateUpHandler(t *testing.T) {
+	t.Run("Applies pending migrations in order", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock, 1)
+		for _, m := range app.Migrations[1:] {
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(m.up[0])).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(insertMigration).WithArgs(m.Version, m.Name).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+		}
+
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"message":"2 migration(s) applied","migrations":[
+			{"version":2,"name":"add_email","applied":true,"has_down":false},
+			{"version":3,"name":"create_roles","applied":true,"has_down":true}
+		]}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Honours steps", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE users`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(insertMigration).WithArgs(int64(1), "create_users").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up?steps=1"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"1 migration(s) applied"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stops at the first failure", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock, 1)
+		mock.ExpectBegin()
+		mock.ExpectExec(`ALTER TABLE users`).WillReturnError(errors.New("duplicate column"))
+		mock.ExpectRollback()
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"message":"Migration 2_add_email failed: duplicate column"}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid steps", func(t *testing.T) {
+		app, _ := newMigrationTestApp(t)
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up?steps=-1"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Tracking table error", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		mock.ExpectQuery(getMigrationLock).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+		mock.ExpectExec(createMigrationsTable).WillReturnError(errors.New("denied"))
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Another run holds the lock", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		mock.ExpectQuery(getMigrationLock).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+
+		w := httptest.NewRecorder()
+		app.migrateUpHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/up"))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "nothing runs without the lock")
+	})
+}
+
+func TestMigrateDownHandler(t *testing.T) {
+	t.Run("Rolls back the latest migration", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock, 1, 3)
+		mock.ExpectBegin()
+		mock.ExpectExec(`DROP TABLE roles`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(deleteMigration).WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateDownHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/down"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"message":"1 migration(s) rolled back","migrations":[
+			{"version":3,"name":"create_roles","applied":false,"has_down":true}
+		]}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Refuses when a down file is missing", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock, 1, 2)
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateDownHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/down?steps=0"))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback failure", func(t *testing.T) {
+		app, mock := newMigrationTestApp(t)
+		expectMigrationRun(mock, 1)
+		mock.ExpectBegin()
+		mock.ExpectExec(`DROP TABLE users`).WillReturnError(errors.New("fk violation"))
+		mock.ExpectRollback()
+		expectMigrationUnlock(mock)
+
+		w := httptest.NewRecorder()
+		app.migrateDownHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/migrations/down"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
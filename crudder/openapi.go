@@ -0,0 +1,475 @@
+package crudder
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAPI 3.0 keeps the spec readable by the bundled Swagger UI; nullable columns use
+// "nullable: true" instead of the type lists of the JSON Schema served per table
+const openAPIVersion = "3.0.3"
+
+// OpenAPIDocument is the OpenAPI description of the data routes of the session's database
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers"`
+	Tags       []OpenAPITag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the OpenAPI 3.0 flavour of a JSON Schema
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Title                string                    `json:"title,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties interface{}               `json:"additionalProperties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	OneOf                []*OpenAPISchema          `json:"oneOf,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Default              interface{}               `json:"default,omitempty"`
+	MaxLength            *int64                    `json:"maxLength,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	ReadOnly             bool                      `json:"readOnly,omitempty"`
+}
+
+func schemaRef(name string) *OpenAPISchema {
+	return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{headerContentTypeJSON: {Schema: schema}}
+}
+
+func errorResponse(description string) *OpenAPIResponse {
+	return &OpenAPIResponse{Description: description, Content: jsonContent(schemaRef("Message"))}
+}
+
+// openAPISchema converts a column schema from tableJSONSchema to OpenAPI 3.0
+func openAPISchema(prop *JSONSchema) *OpenAPISchema {
+	schema := &OpenAPISchema{
+		Title:       prop.Title,
+		Description: prop.Description,
+		Enum:        prop.Enum,
+		Default:     prop.Default,
+		MaxLength:   prop.MaxLength,
+		Minimum:     prop.Minimum,
+		Maximum:     prop.Maximum,
+		Pattern:     prop.Pattern,
+		ReadOnly:    prop.ReadOnly,
+	}
+	switch t := prop.Type.(type) {
+	case string:
+		schema.Type = t
+	case []string:
+		schema.Type = t[0]
+		schema.Nullable = true
+	}
+	if prop.Format == "date" {
+		schema.Format = "date"
+	}
+	// json and spatial columns accept any value, nullable included
+	if schema.Type == "" {
+		schema.Nullable = true
+	}
+	return schema
+}
+
+// tableOpenAPISchemas returns the record schema of a table, used for responses and
+// creates, and the update schema, where every column is optional
+func tableOpenAPISchemas(tableName string, columns []ColumnInfo) (*OpenAPISchema, *OpenAPISchema) {
+	jsonSchema := tableJSONSchema(tableName, columns)
+	record := &OpenAPISchema{
+		Title:                tableName,
+		Type:                 "object",
+		Properties:           make(map[string]*OpenAPISchema, len(columns)),
+		Required:             jsonSchema.Required,
+		AdditionalProperties: false,
+	}
+	update := &OpenAPISchema{
+		Title:                tableName + " (update)",
+		Type:                 "object",
+		Properties:           record.Properties,
+		AdditionalProperties: false,
+	}
+	for _, col := range columns {
+		record.Properties[col.ColumnName] = openAPISchema(jsonSchema.Properties[col.ColumnName])
+	}
+	return record, update
+}
+
+// integerPrimaryKey returns the primary key column of a table when the /crud/{table}/{id}
+// routes can address its rows, that is when it is a single integer column
+func integerPrimaryKey(table TableSnapshot) (ColumnInfo, bool) {
+	for _, index := range table.Indexes {
+		if !index.IsPrimary || len(index.Columns) != 1 {
+			continue
+		}
+		for _, col := range table.Columns {
+			if col.ColumnName == index.Columns[0].ColumnName {
+				_, isInteger := integerBits[strings.ToLower(col.DataType)]
+				return col, isInteger || strings.EqualFold(col.DataType, "bigint")
+			}
+		}
+	}
+	return ColumnInfo{}, false
+}
+
+// the policy operation each method of the crud routes performs
+var crudMethodOperations = map[string]string{"get": opRead, "post": opCreate, "put": opUpdate, "delete": opDelete}
+
+// tableOperations describes the crud routes of a table, limited to the operations
+// access grants
+func tableOperations(table TableSnapshot, access *tableAccess) map[string]map[string]*OpenAPIOperation {
+	name := table.TableName
+	record := schemaRef(name)
+	tags := []string{name}
+	paths := make(map[string]map[string]*OpenAPIOperation)
+
+	paths["/crud/"+name] = map[string]*OpenAPIOperation{
+		"get": {
+			OperationID: "list_" + name,
+			Summary:     "List " + name,
+			Tags:        tags,
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "Every record of the table", Content: jsonContent(&OpenAPISchema{Type: "array", Items: record})},
+				"401": errorResponse("Unauthorized"),
+				"404": errorResponse("The table has no records"),
+				"500": errorResponse("Internal server error"),
+			},
+		},
+		"post": {
+			OperationID: "create_" + name,
+			Summary:     "Create a record in " + name,
+			Tags:        tags,
+			RequestBody: &OpenAPIRequestBody{Required: true, Content: jsonContent(record)},
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "The created record", Content: jsonContent(record)},
+				"400": {Description: "Body does not match the table schema", Content: jsonContent(schemaRef("ValidationError"))},
+				"401": errorResponse("Unauthorized"),
+				"500": errorResponse("Internal server error"),
+			},
+		},
+	}
+
+	pk, ok := integerPrimaryKey(table)
+	if !ok {
+		return allowedOperations(paths, access)
+	}
+	idParam := []OpenAPIParameter{{Name: "id", In: "path", Required: true, Schema: &OpenAPISchema{
+		Type: "integer", Description: "Value of " + pk.ColumnName, Minimum: new(float64),
+	}}}
+	paths["/crud/"+name+"/{id}"] = map[string]*OpenAPIOperation{
+		"get": {
+			OperationID: "get_" + name,
+			Summary:     "Get a record of " + name,
+			Tags:        tags,
+			Parameters:  idParam,
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "The requested record", Content: jsonContent(record)},
+				"401": errorResponse("Unauthorized"),
+				"404": errorResponse("Record not found"),
+				"500": errorResponse("Internal server error"),
+			},
+		},
+		"put": {
+			OperationID: "update_" + name,
+			Summary:     "Update a record of " + name,
+			Tags:        tags,
+			Parameters:  idParam,
+			RequestBody: &OpenAPIRequestBody{Required: true, Content: jsonContent(schemaRef(name + "_update"))},
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "The updated columns and the primary key", Content: jsonContent(schemaRef(name + "_update"))},
+				"400": {Description: "Body does not match the table schema", Content: jsonContent(schemaRef("ValidationError"))},
+				"401": errorResponse("Unauthorized"),
+				"404": errorResponse("Record not found or not updated"),
+				"500": errorResponse("Internal server error"),
+			},
+		},
+		"delete": {
+			OperationID: "delete_" + name,
+			Summary:     "Delete a record of " + name,
+			Tags:        tags,
+			Parameters:  idParam,
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "Delete successful", Content: jsonContent(schemaRef("DeleteResult"))},
+				"401": errorResponse("Unauthorized"),
+				"404": errorResponse("Record not found"),
+				"500": errorResponse("Internal server error"),
+			},
+		},
+	}
+	return allowedOperations(paths, access)
+}
+
+// allowedOperations removes the operations access does not grant, and the paths left
+// without any
+func allowedOperations(paths map[string]map[string]*OpenAPIOperation, access *tableAccess) map[string]map[string]*OpenAPIOperation {
+	for path, operations := range paths {
+		for method := range operations {
+			if !access.allows(crudMethodOperations[method]) {
+				delete(operations, method)
+			}
+		}
+		if len(operations) == 0 {
+			delete(paths, path)
+		}
+	}
+	return paths
+}
+
+// viewOperation describes the route of a saved query; its columns are only known at run time
+func viewOperation(view *SavedQuery) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: "view_" + view.Name,
+		Summary:     "Read view " + view.Name,
+		Description: view.Description,
+		Tags:        []string{"views"},
+		Responses: map[string]*OpenAPIResponse{
+			"200": {Description: "Rows returned by the view", Content: jsonContent(&OpenAPISchema{
+				Type: "array", Items: &OpenAPISchema{Type: "object", AdditionalProperties: true},
+			})},
+			"400": errorResponse("Missing view parameter"),
+			"401": errorResponse("Unauthorized"),
+			"404": errorResponse("The view returned no records"),
+			"500": errorResponse("Internal server error"),
+		},
+	}
+	for _, param := range view.Params {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: param, In: "query", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+	}
+	return op
+}
+
+// buildOpenAPI describes the login and logout routes, the crud routes of every table
+// of the snapshot with the operations accesses grants (all of them for a table it does
+// not list) and the saved query views
+func buildOpenAPI(snapshot *SchemaSnapshot, views map[string]*SavedQuery, accesses map[string]*tableAccess) *OpenAPIDocument {
+	message := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{"message": {Type: "string"}}}
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title: "Crudder Go API: " + snapshot.Schema,
+			Description: "Generated from the live structure of " + snapshot.Schema + ". Covers login, logout, the crud routes of the tables with the operations you are granted, and the saved query views. " +
+				"The schema, DDL, migration, routine, token, database and /databases/{schema}/crud routes are described in the static API documentation.",
+			Version: "1.0",
+		},
+		Servers: []OpenAPIServer{{URL: "/api/v1"}},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: map[string]*OpenAPISchema{
+				"Message": message,
+				"ValidationError": {Type: "object", Properties: map[string]*OpenAPISchema{
+					"message": {Type: "string"},
+					"errors": {Type: "array", Items: &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{
+						"field":   {Type: "string"},
+						"message": {Type: "string"},
+					}}},
+				}},
+				"DeleteResult": {Type: "object", Properties: map[string]*OpenAPISchema{
+					"message":       {Type: "string"},
+					"rows_affected": {Type: "string"},
+				}},
+				"APIKeyInfo": {Type: "object", Properties: map[string]*OpenAPISchema{
+					"id":         {Type: "string"},
+					"name":       {Type: "string"},
+					"token":      {Type: "string", Description: "Key for the Authorization: Bearer header, only returned when the key is issued"},
+					"created_at": {Type: "string", Format: "date-time"},
+					"expires_at": {Type: "string", Format: "date-time"},
+					"last_used":  {Type: "string", Format: "date-time"},
+				}},
+			},
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				"session": {Type: "apiKey", In: "cookie", Name: "session_token"},
+				"csrf":    {Type: "apiKey", In: "header", Name: csrfHeader},
+				"bearer":  {Type: "http", Scheme: "bearer"},
+			},
+		},
+		// The session cookie goes with the X-CSRF-Token header returned by /login
+		Security: []map[string][]string{{"session": {}, "csrf": {}}, {"bearer": {}}},
+	}
+
+	noSecurity := []map[string][]string{}
+	loginField := func(description string) *OpenAPISchema {
+		return &OpenAPISchema{Type: "string", Description: description}
+	}
+	doc.Paths["/login"] = map[string]*OpenAPIOperation{"post": {
+		OperationID: "login",
+		Summary:     "Open a session on the database",
+		Description: "Opens a session with the session cookie, or with api_key=true issues an API key for the Authorization: Bearer header. SQLite logins open the file configured on the server and need no credentials.",
+		Tags:        []string{"authentication"},
+		RequestBody: &OpenAPIRequestBody{Required: true, Content: map[string]*OpenAPIMediaType{
+			"application/x-www-form-urlencoded": {Schema: &OpenAPISchema{
+				Type: "object",
+				Properties: map[string]*OpenAPISchema{
+					"username":   loginField("Database username, required unless the engine is sqlite"),
+					"password":   loginField("Database password, required unless the engine is sqlite"),
+					"dbname":     loginField("Database name; the first database of the profile when empty"),
+					"engine":     {Type: "string", Description: "Database engine", Enum: []interface{}{"mysql", "postgres", "sqlite"}, Default: "mysql"},
+					"profile":    loginField("Server profile from /profiles; replaces DB_HOST and engine"),
+					"api_key":    {Type: "boolean", Description: "Return an API key instead of setting the session cookie"},
+					"key_name":   loginField("Name of the API key"),
+					"expires_in": {Type: "string", Description: "Lifetime of the API key as a Go duration", Default: "720h"},
+				},
+			}},
+		}},
+		Responses: map[string]*OpenAPIResponse{
+			"200": {
+				Description: "Login successful: the session cookie is set and the CSRF token returned in the X-CSRF-Token header, or with api_key=true the new key is returned",
+				Content:     jsonContent(&OpenAPISchema{OneOf: []*OpenAPISchema{schemaRef("Message"), schemaRef("APIKeyInfo")}}),
+			},
+			"400": errorResponse("Missing credentials, unknown profile or engine, or invalid expires_in"),
+			"401": errorResponse("Invalid credentials"),
+			"403": errorResponse("Database not allowed on this server"),
+			"429": errorResponse("Too many failed logins, try again after the seconds in Retry-After"),
+			"500": errorResponse("Error connecting to the database"),
+		},
+		Security: noSecurity,
+	}}
+	doc.Paths["/logout"] = map[string]*OpenAPIOperation{"post": {
+		OperationID: "logout",
+		Summary:     "Close the session",
+		Description: "Cookie sessions must send the X-CSRF-Token header.",
+		Tags:        []string{"authentication"},
+		Responses: map[string]*OpenAPIResponse{
+			"200": {Description: "Logout successful", Content: jsonContent(schemaRef("Message"))},
+			"400": errorResponse("No session found"),
+			"403": errorResponse("Invalid or missing CSRF token"),
+		},
+	}}
+
+	for _, table := range snapshot.Tables {
+		record, update := tableOpenAPISchemas(table.TableName, table.Columns)
+		doc.Components.Schemas[table.TableName] = record
+		doc.Components.Schemas[table.TableName+"_update"] = update
+		doc.Tags = append(doc.Tags, OpenAPITag{Name: table.TableName, Description: table.TableComment})
+		for path, operations := range tableOperations(table, accesses[table.TableName]) {
+			doc.Paths[path] = operations
+		}
+	}
+
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Paths["/views/"+name] = map[string]*OpenAPIOperation{"get": viewOperation(views[name])}
+	}
+	if len(names) > 0 {
+		doc.Tags = append(doc.Tags, OpenAPITag{Name: "views", Description: "Saved queries"})
+	}
+	return doc
+}
+
+// @Summary Live OpenAPI Spec
+// @Description Returns an OpenAPI 3 document generated from the structure of the current database, with typed request and response schemas for login, logout, the crud routes of every visible table limited to the operations POLICY_FILE grants, and the saved query views. The other routes are described by this static documentation. Use it with Swagger UI (select "Live schema" at /swagger/) or an OpenAPI client generator.
+// @Tags Schema
+// @Produce json
+// @Success 200 {object} OpenAPIDocument "OpenAPI document"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /openapi.json [get]
+func (app *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if app.getDBFromSession(r) == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	snapshot, err := app.currentSnapshot(r)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error reading schema structure")
+		log.Println("error reading schema snapshot:", err)
+		return
+	}
+
+	session := app.getSession(r)
+	views := make(map[string]*SavedQuery)
+	for name, view := range app.SavedQueries {
+		if app.allowsView(session, name) {
+			views[name] = view
+		}
+	}
+	visible := app.visibleSnapshot(session, snapshot)
+	accesses := make(map[string]*tableAccess, len(visible.Tables))
+	for _, table := range visible.Tables {
+		accesses[table.TableName] = app.tableAccess(session, table.TableName)
+	}
+	doc := buildOpenAPI(visible, views, accesses)
+	doc.Components.SecuritySchemes["session"].Name = app.cookieConfig().name()
+	writeJSONResponseWithStatus(w, http.StatusOK, doc)
+}
+
+// swaggerUIURLs lists the specs offered by the Swagger UI: the static documentation of
+// every endpoint and the live spec of the logged in session
+const swaggerUIURLs = `[{url: "doc.json", name: "API reference"}, {url: "/api/v1/openapi.json", name: "Live schema"}]`
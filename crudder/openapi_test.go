@@ -0,0 +1,142 @@
+package crudder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOpenAPI(t *testing.T) {
+	snapshot := &SchemaSnapshot{Schema: "crudder_db_test", Tables: []TableSnapshot{
+		{
+			TableName: "users",
+			Columns: []ColumnInfo{
+				{ColumnName: "user_id", DataType: "int", ColumnType: "int", IsAutoIncrement: true},
+				{ColumnName: "username", DataType: "varchar", ColumnType: "varchar(100)", CharacterMaxLength: int64Ptr(100)},
+				{ColumnName: "role", DataType: "enum", ColumnType: "enum('admin','user')", EnumValues: []string{"admin", "user"}, IsNullable: true},
+			},
+			Indexes: []IndexInfo{{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "user_id"}}}},
+		},
+		{
+			TableName: "user_roles",
+			Columns:   []ColumnInfo{{ColumnName: "user_id", DataType: "int"}, {ColumnName: "role_id", DataType: "int"}},
+			Indexes:   []IndexInfo{{IndexName: "PRIMARY", IsPrimary: true, Columns: []IndexColumn{{ColumnName: "user_id"}, {ColumnName: "role_id"}}}},
+		},
+	}}
+	views := map[string]*SavedQuery{"by_role": {Name: "by_role", Params: []string{"role"}}}
+
+	doc := buildOpenAPI(snapshot, views, nil)
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "/api/v1", doc.Servers[0].URL)
+
+	users := doc.Components.Schemas["users"]
+	require.NotNil(t, users)
+	assert.Equal(t, []string{"username"}, users.Required)
+	assert.Equal(t, "integer", users.Properties["user_id"].Type)
+	assert.True(t, users.Properties["user_id"].ReadOnly)
+	assert.Equal(t, int64(100), *users.Properties["username"].MaxLength)
+	assert.Equal(t, "string", users.Properties["role"].Type)
+	assert.True(t, users.Properties["role"].Nullable)
+	assert.Equal(t, []interface{}{"admin", "user", nil}, users.Properties["role"].Enum)
+	assert.Empty(t, doc.Components.Schemas["users_update"].Required)
+
+	require.Contains(t, doc.Paths, "/crud/users")
+	require.Contains(t, doc.Paths, "/crud/users/{id}")
+	assert.Equal(t, "#/components/schemas/users", doc.Paths["/crud/users"]["post"].RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/users", doc.Paths["/crud/users"]["get"].Responses["200"].Content["application/json"].Schema.Items.Ref)
+	assert.Equal(t, "#/components/schemas/users_update", doc.Paths["/crud/users/{id}"]["put"].RequestBody.Content["application/json"].Schema.Ref)
+	assert.Len(t, doc.Paths["/crud/users/{id}"], 3)
+
+	// a composite key cannot be addressed by /crud/{table}/{id}
+	assert.Contains(t, doc.Paths, "/crud/user_roles")
+	assert.NotContains(t, doc.Paths, "/crud/user_roles/{id}")
+
+	// only the operations granted by the policy are described
+	policy, err := LoadPolicy(writePolicy(t, `{"users": {"*": {"tables": {"users": {"operations": ["read"]}, "user_roles": {"operations": ["create"]}}}}}`))
+	require.NoError(t, err)
+	readOnly := buildOpenAPI(snapshot, views, map[string]*tableAccess{
+		"users":      policy.access("ana", "users"),
+		"user_roles": policy.access("ana", "user_roles"),
+	})
+	assert.Equal(t, []string{"get"}, operationMethods(readOnly.Paths["/crud/users"]))
+	assert.Equal(t, []string{"get"}, operationMethods(readOnly.Paths["/crud/users/{id}"]))
+	assert.Equal(t, []string{"post"}, operationMethods(readOnly.Paths["/crud/user_roles"]))
+
+	view := doc.Paths["/views/by_role"]["get"]
+	require.NotNil(t, view)
+	assert.Equal(t, []OpenAPIParameter{{Name: "role", In: "query", Required: true, Schema: &OpenAPISchema{Type: "string"}}}, view.Parameters)
+
+	assert.Equal(t, []map[string][]string{{"session": {}, "csrf": {}}, {"bearer": {}}}, doc.Security)
+	assert.Equal(t, "X-CSRF-Token", doc.Components.SecuritySchemes["csrf"].Name)
+	assert.Empty(t, doc.Paths["/login"]["post"].Security)
+	assert.NotNil(t, doc.Paths["/login"]["post"].Security)
+
+	// generated clients can log in to every engine and request an API key
+	login := doc.Paths["/login"]["post"].RequestBody.Content["application/x-www-form-urlencoded"].Schema
+	assert.Empty(t, login.Required, "SQLite logins need no credentials")
+	for _, field := range []string{"engine", "profile", "api_key", "expires_in"} {
+		assert.Contains(t, login.Properties, field)
+	}
+	assert.Equal(t, "#/components/schemas/APIKeyInfo", doc.Paths["/login"]["post"].Responses["200"].Content["application/json"].Schema.OneOf[1].Ref)
+}
+
+func operationMethods(operations map[string]*OpenAPIOperation) []string {
+	methods := make([]string, 0, len(operations))
+	for method := range operations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+		expectSnapshot(mock, "crudder_db_test", true)
+
+		w := httptest.NewRecorder()
+		app.openAPIHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/openapi.json"))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		paths := doc["paths"].(map[string]interface{})
+		assert.Contains(t, paths, "/crud/roles/{id}")
+		assert.Contains(t, paths, "/crud/users/{id}")
+		assert.NotContains(t, paths, "/crud/active_users")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Snapshot error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow(nil))
+
+		w := httptest.NewRecorder()
+		app.openAPIHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/openapi.json"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.openAPIHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/openapi.json"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestSwaggerUIListsLiveSpec(t *testing.T) {
+	app := &App{SessionStore: NewMemoryStore()}
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `{url: "/api/v1/openapi.json", name: "Live schema"}`)
+}
@@ -0,0 +1,449 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Operations a policy grants on a table
+const (
+	opRead   = "read"
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+	opAlter  = "alter" // the schema management routes, and the migrations on "*"
+)
+
+// policyWildcard stands for the users or tables a policy does not list
+const policyWildcard = "*"
+
+// Policy restricts what each database user may do through crudder, on top of the
+// grants of the database, for accounts shared by several people or tools
+type Policy struct {
+	Roles map[string]*PolicyGrant `json:"roles,omitempty"`
+	Users map[string]*PolicyGrant `json:"users"`
+}
+
+// PolicyGrant gives a user or a role access to tables, views and routines
+type PolicyGrant struct {
+	Roles    []string                `json:"roles,omitempty"`    // users only: roles of the policy whose tables they also get
+	Tables   map[string]*TablePolicy `json:"tables,omitempty"`   // "*" applies to the tables not listed
+	Views    []string                `json:"views,omitempty"`    // saved query views that can be read, "*" for all
+	Routines []string                `json:"routines,omitempty"` // stored routines that can be listed and called, "*" for all
+	Session  map[string]string       `json:"session,omitempty"`  // users only: values of the :session references of row filters
+}
+
+// TablePolicy is what a grant allows on a table
+type TablePolicy struct {
+	Operations  []string `json:"operations"`             // read, create, update, delete and alter
+	Columns     []string `json:"columns,omitempty"`      // columns that can be read and written; empty allows all
+	DenyColumns []string `json:"deny_columns,omitempty"` // columns hidden even when Columns allows them
+	Where       string   `json:"where,omitempty"`        // row filter, e.g. "tenant_id = :session.tenant"
+}
+
+// LoadPolicy reads the permissions config file. The file maps database users (or "*"
+// for everyone else) to the tables they may use, directly or through roles, e.g.:
+//
+//	{"roles": {"support": {"tables": {"users": {"operations": ["read", "update"], "deny_columns": ["pwd"]}}}},
+//	 "users": {"crudder_user": {"roles": ["support"], "tables": {"*": {"operations": ["read"]}}}}}
+//
+// A table entry can also limit the rows with a where predicate such as
+// "tenant_id = :session.tenant", taking the tenant from the session values of the user.
+// Saved query views and stored routines run whatever SQL they hold, so they are granted
+// by name in the views and routines lists of a grant, and never to users with a row
+// filter on any table.
+//
+// Without a file every table is allowed; with one, users get only what it grants.
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+
+	for name, role := range policy.Roles {
+		if role == nil {
+			return nil, fmt.Errorf("role %q has no grants", name)
+		}
+		if len(role.Roles) > 0 || len(role.Session) > 0 {
+			return nil, fmt.Errorf("role %q: roles cannot include other roles or session values", name)
+		}
+		if err := role.validate(); err != nil {
+			return nil, fmt.Errorf("role %q: %v", name, err)
+		}
+	}
+	for name, user := range policy.Users {
+		if user == nil {
+			return nil, fmt.Errorf("user %q has no grants", name)
+		}
+		for _, role := range user.Roles {
+			if policy.Roles[role] == nil {
+				return nil, fmt.Errorf("user %q: unknown role %q", name, role)
+			}
+		}
+		if err := user.validate(); err != nil {
+			return nil, fmt.Errorf("user %q: %v", name, err)
+		}
+		if err := policy.validateRowFilters(name, user); err != nil {
+			return nil, err
+		}
+	}
+	return &policy, nil
+}
+
+func (g *PolicyGrant) validate() error {
+	for _, name := range append(append([]string{}, g.Views...), g.Routines...) {
+		if name != policyWildcard && !isAlphaNumeric(name) {
+			return fmt.Errorf("invalid view or routine name %q", name)
+		}
+	}
+	for table, t := range g.Tables {
+		if table != policyWildcard && !isAlphaNumeric(table) {
+			return fmt.Errorf("invalid table name %q", table)
+		}
+		if t == nil {
+			return fmt.Errorf("table %q has no operations", table)
+		}
+		for _, op := range t.Operations {
+			switch op {
+			case opRead, opCreate, opUpdate, opDelete, opAlter:
+			default:
+				return fmt.Errorf("table %q: unknown operation %q", table, op)
+			}
+		}
+		if strings.Contains(t.Where, ";") {
+			return fmt.Errorf("table %q: the row filter must be a single predicate", table)
+		}
+		for _, column := range append(t.Columns, t.DenyColumns...) {
+			if !isAlphaNumeric(column) {
+				return fmt.Errorf("table %q: invalid column name %q", table, column)
+			}
+		}
+	}
+	return nil
+}
+
+// tableAccess is what a user may do on a table: the union of the grants of the user
+// and of its roles. A nil tableAccess allows everything.
+type tableAccess struct {
+	operations map[string]bool
+	grants     []*TablePolicy
+	values     map[string]string // :session values of the row filters
+}
+
+// grants returns the grant of a database user followed by those of its roles
+func (p *Policy) grants(username string) []*PolicyGrant {
+	user := p.Users[username]
+	if user == nil {
+		user = p.Users[policyWildcard]
+	}
+	if user == nil {
+		return nil
+	}
+	grants := []*PolicyGrant{user}
+	for _, role := range user.Roles {
+		grants = append(grants, p.Roles[role])
+	}
+	return grants
+}
+
+// filtered reports whether any table of a database user has a row filter
+func (p *Policy) filtered(username string) bool {
+	for _, grant := range p.grants(username) {
+		for _, t := range grant.Tables {
+			if t.Where != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// access returns the permissions of a database user on a table
+func (p *Policy) access(username, table string) *tableAccess {
+	a := &tableAccess{operations: make(map[string]bool)}
+	for _, grant := range p.grants(username) {
+		t := grant.Tables[table]
+		if t == nil {
+			t = grant.Tables[policyWildcard]
+		}
+		if t == nil || len(t.Operations) == 0 {
+			continue
+		}
+		a.grants = append(a.grants, t)
+		for _, op := range t.Operations {
+			a.operations[op] = true
+		}
+	}
+	return a
+}
+
+// visible reports whether the table is shown to the user at all
+func (a *tableAccess) visible() bool {
+	return a == nil || len(a.operations) > 0
+}
+
+func (a *tableAccess) allows(op string) bool {
+	return a == nil || a.operations[op]
+}
+
+// column reports whether the user may read and write a column of the table
+func (a *tableAccess) column(name string) bool {
+	if a == nil {
+		return true
+	}
+	for _, t := range a.grants {
+		if (len(t.Columns) == 0 || containsFold(t.Columns, name)) && !containsFold(t.DenyColumns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleColumns returns the columns of the table the user may see
+func (a *tableAccess) visibleColumns(columns []ColumnInfo) []ColumnInfo {
+	if a == nil {
+		return columns
+	}
+	visible := []ColumnInfo{}
+	for _, col := range columns {
+		if a.column(col.ColumnName) {
+			visible = append(visible, col)
+		}
+	}
+	return visible
+}
+
+// columns reports whether the user may use every one of the columns
+func (a *tableAccess) columns(names []string) bool {
+	for _, name := range names {
+		if !a.column(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// hideColumns removes the columns the user may not read from a record
+func (a *tableAccess) hideColumns(item map[string]interface{}) {
+	if a == nil {
+		return
+	}
+	for name := range item {
+		if !a.column(name) {
+			delete(item, name)
+		}
+	}
+}
+
+// deniedColumns returns the columns of a record the user may not write, sorted
+func (a *tableAccess) deniedColumns(item map[string]interface{}) []string {
+	var denied []string
+	for name := range item {
+		if !a.column(name) {
+			denied = append(denied, name)
+		}
+	}
+	sort.Strings(denied)
+	return denied
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionUser returns the database user of session
+func sessionUser(session *SessionData) string {
+	if session != nil && session.login != nil {
+		return session.login.Username
+	}
+	return ""
+}
+
+// tableAccess returns the permissions of the user of session on a table, nil when no
+// POLICY_FILE is set
+func (app *App) tableAccess(session *SessionData, table string) *tableAccess {
+	if app.Policy == nil {
+		return nil
+	}
+	a := app.Policy.access(sessionUser(session), table)
+	a.values = app.sessionValues(session)
+	return a
+}
+
+// crudOperations returns the operations a crud request performs
+func crudOperations(r *http.Request) []string {
+	switch r.Method {
+	case http.MethodPost:
+		if r.URL.Query().Get("upsert") == "true" {
+			return []string{opCreate, opUpdate}
+		}
+		return []string{opCreate}
+	case http.MethodPut:
+		return []string{opUpdate}
+	case http.MethodDelete:
+		return []string{opDelete}
+	default:
+		return []string{opRead}
+	}
+}
+
+// allowsView reports whether the user of session may read a saved query view
+func (app *App) allowsView(session *SessionData, name string) bool {
+	if app.Policy == nil {
+		return true
+	}
+	// the row filters only apply to the crud routes, so users who have any are
+	// refused the SQL of views that could read around them
+	if app.Policy.filtered(sessionUser(session)) {
+		return false
+	}
+	for _, grant := range app.Policy.grants(sessionUser(session)) {
+		if containsFold(grant.Views, name) || containsFold(grant.Views, policyWildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRoutine reports whether the user of session may list and call a stored routine
+func (app *App) allowsRoutine(session *SessionData, name string) bool {
+	if app.Policy == nil {
+		return true
+	}
+	// the row filters only apply to the crud routes, so users who have any are
+	// refused the SQL of routines that could read around them
+	if app.Policy.filtered(sessionUser(session)) {
+		return false
+	}
+	for _, grant := range app.Policy.grants(sessionUser(session)) {
+		if containsFold(grant.Routines, name) || containsFold(grant.Routines, policyWildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeCrud checks a crud request against the policy, writing the error response
+// itself when it is refused. Tables the user cannot see are reported as not found.
+func (app *App) authorizeCrud(w http.ResponseWriter, r *http.Request, table string) bool {
+	return app.authorizeTable(w, r, table, crudOperations(r)...)
+}
+
+// authorizeTable checks operations on a table against the policy like authorizeCrud
+func (app *App) authorizeTable(w http.ResponseWriter, r *http.Request, table string, ops ...string) bool {
+	if app.Policy == nil {
+		return true
+	}
+	access := app.tableAccess(app.getSession(r), table)
+	if !access.visible() {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return false
+	}
+	for _, op := range ops {
+		if !access.allows(op) {
+			WriteErrorResponse(w, http.StatusForbidden, fmt.Sprintf(errOpNotAllowed, op))
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeColumns rejects a create or update body that writes columns the user may
+// not use, writing the error response itself
+func authorizeColumns(w http.ResponseWriter, access *tableAccess, item map[string]interface{}) bool {
+	if access == nil {
+		return true
+	}
+	if denied := access.deniedColumns(item); len(denied) > 0 {
+		WriteErrorResponse(w, http.StatusForbidden, fmt.Sprintf(errColumnDenied, strings.Join(denied, ", ")))
+		return false
+	}
+	return true
+}
+
+// visibleSnapshot returns the part of a snapshot the user of session may see: the
+// visible tables with their columns, and the indexes and foreign keys that only use
+// those. The snapshot itself is cached and left untouched.
+func (app *App) visibleSnapshot(session *SessionData, snapshot *SchemaSnapshot) *SchemaSnapshot {
+	if app.Policy == nil {
+		return snapshot
+	}
+	accesses := make(map[string]*tableAccess)
+	for _, table := range snapshot.Tables {
+		if access := app.tableAccess(session, table.TableName); access.visible() {
+			accesses[table.TableName] = access
+		}
+	}
+
+	visible := *snapshot
+	visible.Tables = []TableSnapshot{}
+	for _, table := range snapshot.Tables {
+		access := accesses[table.TableName]
+		if access == nil {
+			continue
+		}
+		table.Columns = access.visibleColumns(table.Columns)
+		table.Indexes = visibleIndexes(access, table.Indexes)
+		foreignKeys := []ConstraintInfo{}
+		for _, fk := range table.ForeignKeys {
+			if constraintVisible(access, accesses, fk) {
+				foreignKeys = append(foreignKeys, fk)
+			}
+		}
+		table.ForeignKeys = foreignKeys
+		visible.Tables = append(visible.Tables, table)
+	}
+	return &visible
+}
+
+// visibleIndexes returns the indexes whose columns the user may all see
+func visibleIndexes(access *tableAccess, indexes []IndexInfo) []IndexInfo {
+	if access == nil {
+		return indexes
+	}
+	visible := []IndexInfo{}
+	for _, index := range indexes {
+		var names []string
+		for _, col := range index.Columns {
+			names = append(names, col.ColumnName)
+		}
+		if access.columns(names) {
+			visible = append(visible, index)
+		}
+	}
+	return visible
+}
+
+// constraintVisible reports whether the user may see a constraint: its columns, and
+// for a foreign key the referenced table (found in referenced, by name) and columns
+func constraintVisible(access *tableAccess, referenced map[string]*tableAccess, c ConstraintInfo) bool {
+	if access == nil {
+		return true
+	}
+	if !access.columns(c.Columns) {
+		return false
+	}
+	if c.ReferencedTable == nil {
+		return true
+	}
+	target, ok := referenced[*c.ReferencedTable]
+	return ok && target.visible() && target.columns(c.ReferencedColumns)
+}
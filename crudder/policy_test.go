@@ -0,0 +1,253 @@
+package crudder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{
+	"roles": {
+		"support": {"tables": {"users": {"operations": ["read", "update"], "deny_columns": ["status"]}}},
+		"admin": {"tables": {"*": {"operations": ["read", "create", "update", "delete"]}}}
+	},
+	"users": {
+		"support": {"roles": ["support"], "tables": {"roles": {"operations": ["read"], "columns": ["role_id"]}}},
+		"root": {"roles": ["admin"]},
+		"*": {"tables": {"roles": {"operations": ["read"]}}}
+	}
+}`
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("")
+	require.NoError(t, err)
+	assert.Nil(t, policy, "no file, no restrictions")
+
+	policy, err = LoadPolicy(writePolicy(t, testPolicy))
+	require.NoError(t, err)
+	assert.Len(t, policy.Users, 3)
+
+	for name, content := range map[string]string{
+		"unknown role":      `{"users": {"ana": {"roles": ["auditor"]}}}`,
+		"unknown operation": `{"users": {"ana": {"tables": {"users": {"operations": ["truncate"]}}}}}`,
+		"invalid table":     `{"users": {"ana": {"tables": {"users;": {"operations": ["read"]}}}}}`,
+		"invalid column":    `{"users": {"ana": {"tables": {"users": {"operations": ["read"], "columns": ["a b"]}}}}}`,
+		"nested roles":      `{"roles": {"a": {"roles": ["a"]}}, "users": {}}`,
+		"invalid view":      `{"users": {"ana": {"views": ["a b"]}}}`,
+		"invalid JSON":      `{"users": [`,
+	} {
+		_, err := LoadPolicy(writePolicy(t, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestPolicyAccess(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	require.NoError(t, err)
+
+	users := policy.access("support", "users")
+	assert.True(t, users.allows(opRead))
+	assert.True(t, users.allows(opUpdate))
+	assert.False(t, users.allows(opDelete))
+	assert.True(t, users.column("username"))
+	assert.False(t, users.column("STATUS"))
+
+	roles := policy.access("support", "roles")
+	assert.True(t, roles.column("role_id"))
+	assert.False(t, roles.column("name"))
+	assert.False(t, policy.access("support", "orders").visible())
+
+	assert.True(t, policy.access("root", "orders").allows(opDelete), "the * table of a role")
+	assert.True(t, policy.access("ana", "roles").allows(opRead), "the * user")
+	assert.False(t, policy.access("ana", "users").visible())
+
+	var unrestricted *tableAccess
+	assert.True(t, unrestricted.visible())
+	assert.True(t, unrestricted.allows(opDelete))
+	assert.True(t, unrestricted.column("pwd"))
+}
+
+func TestPolicyEnforcement(t *testing.T) {
+	app, _ := newSQLiteTestApp(t)
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	require.NoError(t, err)
+	app.Policy = policy
+	storedSession(app, "mockSession").login = &sessionLogin{Engine: "sqlite", Username: "support"}
+
+	w := serveSQLite(app, http.MethodGet, "/api/v1/tables", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tables []TableInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tables))
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.TableName)
+	}
+	assert.Equal(t, []string{"roles", "users"}, names, "active_users is hidden")
+
+	t.Run("Structure", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodGet, "/api/v1/table-structure?table=roles", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var columns []ColumnInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &columns))
+		require.Len(t, columns, 1)
+		assert.Equal(t, "role_id", columns[0].ColumnName)
+
+		w = serveSQLite(app, http.MethodGet, "/api/v1/table-structure?table=active_users", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serveSQLite(app, http.MethodGet, "/api/v1/schema/users.json", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var schema JSONSchema
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+		assert.Contains(t, schema.Properties, "username")
+		assert.NotContains(t, schema.Properties, "status")
+	})
+
+	t.Run("Crud", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodPost, "/api/v1/crud/users", `{"username":"ana"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"Operation 'create' not allowed on this table"}`, w.Body.String())
+
+		_, err := storedSession(app, "mockSession").DB.Exec(`INSERT INTO users (username, status) VALUES ('ana', 'vip')`)
+		require.NoError(t, err)
+
+		w = serveSQLite(app, http.MethodGet, "/api/v1/crud/users/1", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), "status")
+		w = serveSQLite(app, http.MethodGet, "/api/v1/crud/users", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), "vip")
+
+		w = serveSQLite(app, http.MethodPut, "/api/v1/crud/users/1", `{"status":"active"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"Columns not allowed: status"}`, w.Body.String())
+		w = serveSQLite(app, http.MethodPut, "/api/v1/crud/users/1", `{"username":"ana.lima"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = serveSQLite(app, http.MethodDelete, "/api/v1/crud/users/1", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = serveSQLite(app, http.MethodGet, "/api/v1/crud/active_users", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestPolicyCoversSchemaRoutes(t *testing.T) {
+	app, mock := newMockApp(t)
+	var err error
+	app.Policy, err = LoadPolicy(writePolicy(t, `{"users": {"ana": {
+		"tables": {"users": {"operations": ["read", "alter"], "deny_columns": ["role_id"]}},
+		"views": ["users_by_role"]
+	}}}`))
+	require.NoError(t, err)
+	storedSession(app, "mockSession").login = &sessionLogin{Engine: "mysql", Username: "ana"}
+	query, args := compileNamedQuery("SELECT username FROM users WHERE role = :role")
+	app.SavedQueries = map[string]*SavedQuery{
+		"users_by_role": {Name: "users_by_role", query: query, args: args},
+		"salaries":      {Name: "salaries", query: "SELECT * FROM salaries"},
+	}
+
+	t.Run("Hidden tables are not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		app.tableIndexesHandler(w, newTableRequest("/api/v1/table-structure/roles/indexes", "roles"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = httptest.NewRecorder()
+		app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/roles/constraints", "roles"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = httptest.NewRecorder()
+		app.tableJSONSchemaHandler(w, newTableRequest("/api/v1/schema/roles.json", "roles"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "nothing is read from the database")
+	})
+
+	t.Run("Schema management", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/roles", "", map[string]string{"table": "roles"}))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = httptest.NewRecorder()
+		app.dropColumnHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/users/columns/role_id", "", map[string]string{"table": "users", "column": "role_id"}))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = httptest.NewRecorder()
+		app.migrationStatusHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/migrations"))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = httptest.NewRecorder()
+		app.dropColumnHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/users/columns/username?dry_run=true", "", map[string]string{"table": "users", "column": "username"}))
+		assert.Equal(t, http.StatusOK, w.Code, "alter is granted on users")
+
+		w = httptest.NewRecorder()
+		app.createIndexHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables/users/indexes?dry_run=true",
+			`{"index_name":"uq_role","is_unique":true,"columns":[{"column_name":"role_id"}]}`, map[string]string{"table": "users"}))
+		assert.Equal(t, http.StatusForbidden, w.Code, "indexes cannot use denied columns")
+		w = httptest.NewRecorder()
+		app.modifyColumnHandler(w, newDDLRequest(http.MethodPut, "/api/v1/schema/tables/users/columns/username?dry_run=true",
+			`{"column_name":"role_id","column_type":"varchar(50)"}`, map[string]string{"table": "users", "column": "username"}))
+		assert.Equal(t, http.StatusForbidden, w.Code, "columns cannot be renamed to denied names")
+		w = httptest.NewRecorder()
+		app.addColumnHandler(w, newDDLRequest(http.MethodPost, "/api/v1/schema/tables/users/columns?dry_run=true",
+			`{"column_name":"owner_role","column_type":"int","referenced_table":"roles","referenced_column":"role_id"}`, map[string]string{"table": "users"}))
+		assert.Equal(t, http.StatusNotFound, w.Code, "foreign keys cannot reference hidden tables")
+
+		mock.ExpectQuery(indexesQuery).WithArgs("users").WillReturnRows(sqlmock.NewRows(indexColumns).
+			AddRow("PRIMARY", 0, "user_id", nil, "A", "BTREE", "").
+			AddRow("role_id", 1, "role_id", nil, "A", "BTREE", ""))
+		w = httptest.NewRecorder()
+		app.dropIndexHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/users/indexes/role_id?dry_run=true", "",
+			map[string]string{"table": "users", "index": "role_id"}))
+		assert.Equal(t, http.StatusNotFound, w.Code, "indexes over denied columns are hidden")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+		expectSnapshot(mock, "crudder_db_test", true)
+		w := httptest.NewRecorder()
+		app.schemaSnapshotHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/snapshot"))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var snapshot SchemaSnapshot
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+		require.Len(t, snapshot.Tables, 1, "roles is hidden")
+		users := snapshot.Tables[0]
+		var columns []string
+		for _, col := range users.Columns {
+			columns = append(columns, col.ColumnName)
+		}
+		assert.Equal(t, []string{"user_id", "username"}, columns)
+		assert.Len(t, users.Indexes, 1, "the role_id index is hidden")
+		assert.Empty(t, users.ForeignKeys)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Views and routines", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		app.listViewsHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/views"))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "users_by_role")
+		assert.NotContains(t, w.Body.String(), "salaries")
+
+		w = httptest.NewRecorder()
+		app.viewHandler(w, newDDLRequest(http.MethodGet, "/api/v1/views/salaries", "", map[string]string{"name": "salaries"}))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = httptest.NewRecorder()
+		app.callProcedureHandler(w, newDDLRequest(http.MethodPost, "/api/v1/call/raise_salaries", "", map[string]string{"procedure": "raise_salaries"}))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,126 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// Profile is a database server defined by the operator and picked by name at login,
+// instead of the server set in DB_HOST
+type Profile struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Engine      string   `json:"engine,omitempty"`    // mysql (default) or postgres
+	Databases   []string `json:"databases,omitempty"` // databases users may open; empty allows any
+	Host        string   `json:"host,omitempty"`
+	Port        int      `json:"port,omitempty"`
+	Socket      string   `json:"socket,omitempty"`
+	TLS         string   `json:"tls,omitempty"` // same modes as DB_TLS
+	TLSCA       string   `json:"tls_ca,omitempty"`
+
+	conn *ConnConfig
+}
+
+// struct represents a profile as offered on the login page, without its address
+type ProfileInfo struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Engine      string   `json:"engine"`
+	Databases   []string `json:"databases"`
+}
+
+// LoadProfiles reads the server profiles config file. The file is a JSON object
+// mapping each profile name to its server, e.g.:
+//
+//	{"staging": {"display_name": "Staging", "host": "mysql.staging", "tls": "verify-full", "databases": ["crudder_db"]}}
+//
+// The other connection settings (timeouts, driver parameters...) are taken from base.
+func LoadProfiles(path string, base *ConnConfig) (map[string]*Profile, error) {
+	profiles := make(map[string]*Profile)
+	if path == "" {
+		return profiles, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs map[string]*Profile
+	if err := json.Unmarshal(content, &defs); err != nil {
+		return nil, fmt.Errorf("invalid profiles file %s: %v", path, err)
+	}
+
+	for name, p := range defs {
+		if !isAlphaNumeric(name) {
+			return nil, fmt.Errorf("invalid profile name %q", name)
+		}
+		if p == nil || (p.Host == "" && p.Socket == "") {
+			return nil, fmt.Errorf("profile %q needs a host or a socket", name)
+		}
+		if p.Engine == "" {
+			p.Engine = "mysql"
+		}
+		if p.Engine != "mysql" && p.Engine != "postgres" {
+			return nil, fmt.Errorf("profile %q: unsupported engine %q", name, p.Engine)
+		}
+		for _, database := range p.Databases {
+			if !isAlphaNumeric(database) {
+				return nil, fmt.Errorf("profile %q: invalid database name %q", name, database)
+			}
+		}
+		p.Name = name
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+
+		conn := *base
+		conn.profile = name
+		conn.Host, conn.Port, conn.Socket = p.Host, p.Port, p.Socket
+		if p.TLS != "" || p.TLSCA != "" {
+			conn.TLSMode, conn.TLSCA = p.TLS, p.TLSCA
+		}
+		if err := conn.setupTLS(); err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		p.conn = &conn
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// allows reports whether users of the profile may open the database
+func (p *Profile) allows(database string) bool {
+	if len(p.Databases) == 0 {
+		return true
+	}
+	for _, allowed := range p.Databases {
+		if allowed == database {
+			return true
+		}
+	}
+	return false
+}
+
+// @Summary List Server Profiles
+// @Description Lists the database servers configured in PROFILES_FILE, to be passed as profile at login, with the databases each one allows. Available without a session.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {array} ProfileInfo "List of server profiles"
+// @Router /profiles [get]
+func (app *App) listProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	profiles := make([]ProfileInfo, 0, len(app.Profiles))
+	for _, p := range app.Profiles {
+		databases := p.Databases
+		if databases == nil {
+			databases = []string{}
+		}
+		profiles = append(profiles, ProfileInfo{Name: p.Name, DisplayName: p.DisplayName, Engine: p.Engine, Databases: databases})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	writeJSONResponseWithStatus(w, http.StatusOK, profiles)
+}
@@ -0,0 +1,183 @@
+package crudder
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProfilesFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	base := &ConnConfig{Host: "mysql", TLSMode: "require", Timeout: 5 * time.Second}
+
+	t.Run("No file", func(t *testing.T) {
+		profiles, err := LoadProfiles("", base)
+		require.NoError(t, err)
+		assert.Empty(t, profiles)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		ca := writeTestCA(t)
+		profiles, err := LoadProfiles(writeProfilesFile(t, `{
+			"dev": {"host": "mysql.dev", "databases": ["crudder_db_test"]},
+			"reporting": {"display_name": "Reporting", "engine": "postgres", "host": "reports", "port": 6432, "tls": "verify-full", "tls_ca": "`+ca+`"}
+		}`), base)
+		require.NoError(t, err)
+		require.Len(t, profiles, 2)
+
+		dev := profiles["dev"]
+		assert.Equal(t, "dev", dev.DisplayName)
+		assert.Equal(t, "mysql", dev.Engine)
+		assert.Equal(t, "mysql.dev:3306", dev.conn.address(3306))
+		assert.Equal(t, "require", dev.conn.TLSMode, "TLS is inherited from DB_TLS")
+		assert.Equal(t, 5*time.Second, dev.conn.Timeout)
+		assert.True(t, dev.allows("crudder_db_test"))
+		assert.False(t, dev.allows("mysql"))
+
+		reporting := profiles["reporting"]
+		assert.Equal(t, "reports:6432", reporting.conn.address(5432))
+		assert.Equal(t, "verify-full", reporting.conn.TLSMode)
+		assert.Equal(t, "crudder-reporting", reporting.conn.tlsConfigName())
+		assert.True(t, reporting.allows("anything"))
+		assert.Equal(t, "mysql", base.Host, "the base settings are not changed")
+	})
+
+	t.Run("Invalid files", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"Invalid JSON":     `{"dev": `,
+			"Invalid name":     `{"dev server": {"host": "mysql"}}`,
+			"No host":          `{"dev": {"display_name": "Dev"}}`,
+			"Unknown engine":   `{"dev": {"host": "mysql", "engine": "oracle"}}`,
+			"Invalid database": `{"dev": {"host": "mysql", "databases": ["crudder;drop"]}}`,
+			"Invalid TLS":      `{"dev": {"host": "mysql", "tls": "always"}}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := LoadProfiles(writeProfilesFile(t, content), base)
+				assert.Error(t, err)
+			})
+		}
+
+		_, err := LoadProfiles(filepath.Join(t.TempDir(), "missing.json"), base)
+		assert.Error(t, err)
+	})
+}
+
+// newProfilesTestApp returns an app with the dev profile, which only allows crudder_db_test
+func newProfilesTestApp(t *testing.T) *App {
+	t.Helper()
+	profiles, err := LoadProfiles(writeProfilesFile(t, `{
+		"dev": {"display_name": "Development", "host": "mysql.dev", "port": 3307, "databases": ["crudder_db_test", "crudder_db_staging"]},
+		"reporting": {"display_name": "Reporting", "engine": "postgres", "host": "reports"}
+	}`), &ConnConfig{Host: "mysql"})
+	require.NoError(t, err)
+	return &App{SessionStore: NewMemoryStore(), Conn: &ConnConfig{Host: "mysql"}, Profiles: profiles}
+}
+
+func TestListProfilesHandler(t *testing.T) {
+	app := newProfilesTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.listProfilesHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/profiles", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"name":"dev","display_name":"Development","engine":"mysql","databases":["crudder_db_test","crudder_db_staging"]},
+		{"name":"reporting","display_name":"Reporting","engine":"postgres","databases":[]}
+	]`, w.Body.String())
+}
+
+func TestLoginHandlerProfile(t *testing.T) {
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+
+	var driver, dsn string
+	sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+		driver, dsn = driverName, dataSourceName
+		db, _, err := sqlmock.New()
+		return db, err
+	}
+
+	login := func(app *App, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		app.loginHandler(w, req)
+		return w
+	}
+
+	t.Run("MySQL profile", func(t *testing.T) {
+		app := newProfilesTestApp(t)
+		w := login(app, url.Values{"username": {"u"}, "password": {"p"}, "profile": {"dev"}, "engine": {"postgres"}})
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "mysql", driver)
+		assert.Equal(t, "u:p@tcp(mysql.dev:3307)/crudder_db_test", dsn, "the first database is the default")
+		assert.Same(t, app.Profiles["dev"], loginSession(t, app, w).databases.profile)
+	})
+
+	t.Run("PostgreSQL profile", func(t *testing.T) {
+		app := newProfilesTestApp(t)
+		w := login(app, url.Values{"username": {"u"}, "password": {"p"}, "profile": {"reporting"}, "dbname": {"reports"}})
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "postgres", driver)
+		assert.Equal(t, "postgres://u:p@reports:5432/reports", dsn)
+	})
+
+	t.Run("Database not allowed", func(t *testing.T) {
+		app := newProfilesTestApp(t)
+		w := login(app, url.Values{"username": {"u"}, "password": {"p"}, "profile": {"dev"}, "dbname": {"mysql"}})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"Database not allowed on this server"}`, w.Body.String())
+		assert.Zero(t, app.SessionStore.Len())
+	})
+
+	t.Run("Unknown profile", func(t *testing.T) {
+		app := newProfilesTestApp(t)
+		w := login(app, url.Values{"username": {"u"}, "password": {"p"}, "profile": {"prod"}})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Unknown server profile"}`, w.Body.String())
+	})
+}
+
+func TestProfileLimitsDatabases(t *testing.T) {
+	app, mock, dsns := newDatabasesTestApp(t, nil, errors.New("unexpected open"))
+	profile := &Profile{Name: "dev", Databases: []string{"crudder_db_test", "crudder_db_staging"}}
+	config := mysql.NewConfig()
+	config.DBName = "crudder_db_test"
+	newSessionDatabases(storedSession(app, "mockSession"), config, profile)
+
+	mock.ExpectQuery(`FROM information_schema\.schemata`).WillReturnRows(
+		sqlmock.NewRows([]string{"SCHEMA_NAME", "DEFAULT_CHARACTER_SET_NAME", "DEFAULT_COLLATION_NAME", "CURRENT"}).
+			AddRow("crudder_db_test", "utf8mb4", "utf8mb4_0900_ai_ci", 1).
+			AddRow("mysql", "utf8mb4", "utf8mb4_0900_ai_ci", 0))
+
+	w := httptest.NewRecorder()
+	app.listDatabasesHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/databases"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[{"name":"crudder_db_test","character_set":"utf8mb4","collation":"utf8mb4_0900_ai_ci","current":true}]`, w.Body.String())
+
+	_, err := storedSession(app, "mockSession").database("mysql")
+	assert.Error(t, err)
+	assert.Empty(t, *dsns, "no connection is opened to a database outside the profile")
+}
@@ -0,0 +1,343 @@
+package crudder
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// struct represents a parameter of a stored procedure or function
+type RoutineParameter struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"` // IN, OUT or INOUT (always IN for functions)
+	DataType string `json:"data_type"`
+}
+
+// struct represents a stored procedure or function of the current schema
+type RoutineInfo struct {
+	RoutineName string             `json:"routine_name"`
+	RoutineType string             `json:"routine_type"`
+	ReturnType  *string            `json:"return_type,omitempty"`
+	Comment     string             `json:"comment"`
+	Parameters  []RoutineParameter `json:"parameters"`
+	Signature   string             `json:"signature"`
+}
+
+// @Summary List Routines
+// @Description Retrieves the stored procedures and functions of the current database schema, with their parameter signatures.
+// @Tags Database
+// @Produce json
+// @Success 200 {array} RoutineInfo "List of routines"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /routines [get]
+func (app *App) listRoutinesHandler(w http.ResponseWriter, r *http.Request) {
+	db, ok := r.Context().Value(userDBKey).(*sql.DB)
+	if !ok {
+		http.Error(w, "database not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	parameters, err := fetchRoutineParameters(db)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying routine parameters")
+		log.Println("error fetching routine parameters:", err)
+		return
+	}
+
+	rows, err := db.Query(`
+        SELECT ROUTINE_NAME, ROUTINE_TYPE, DTD_IDENTIFIER, ROUTINE_COMMENT
+        FROM information_schema.routines
+        WHERE ROUTINE_SCHEMA = DATABASE()
+        ORDER BY ROUTINE_NAME
+    `)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying routines")
+		log.Println("error fetching routines:", err)
+		return
+	}
+	defer rows.Close()
+
+	session := app.getSession(r)
+	routines := []RoutineInfo{}
+	for rows.Next() {
+		var routine RoutineInfo
+		var returnType sql.NullString
+		if err := rows.Scan(&routine.RoutineName, &routine.RoutineType, &returnType, &routine.Comment); err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, "Error processing result")
+			log.Println("error reading routines:", err)
+			return
+		}
+		if returnType.Valid && routine.RoutineType == "FUNCTION" {
+			routine.ReturnType = &returnType.String
+		}
+		routine.Parameters = parameters[routine.RoutineName]
+		if routine.Parameters == nil {
+			routine.Parameters = []RoutineParameter{}
+		}
+		routine.Signature = routineSignature(routine)
+		if !app.allowsRoutine(session, routine.RoutineName) {
+			continue
+		}
+		routines = append(routines, routine)
+	}
+	if err := rows.Err(); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, errRows)
+		return
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusOK, routines)
+}
+
+// fetchRoutineParameters returns the parameters of every routine in the current schema, keyed by routine name
+func fetchRoutineParameters(db *sql.DB) (map[string][]RoutineParameter, error) {
+	// ORDINAL_POSITION 0 holds the return value of functions, which is reported separately
+	rows, err := db.Query(`
+        SELECT SPECIFIC_NAME, PARAMETER_MODE, PARAMETER_NAME, DTD_IDENTIFIER
+        FROM information_schema.parameters
+        WHERE SPECIFIC_SCHEMA = DATABASE() AND ORDINAL_POSITION > 0
+        ORDER BY SPECIFIC_NAME, ORDINAL_POSITION
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parameters := make(map[string][]RoutineParameter)
+	for rows.Next() {
+		var routineName string
+		var mode sql.NullString
+		var param RoutineParameter
+		if err := rows.Scan(&routineName, &mode, &param.Name, &param.DataType); err != nil {
+			return nil, err
+		}
+		param.Mode = "IN"
+		if mode.Valid {
+			param.Mode = mode.String
+		}
+		parameters[routineName] = append(parameters[routineName], param)
+	}
+	return parameters, rows.Err()
+}
+
+// routineSignature renders a routine as e.g. "get_total(IN role varchar(100), OUT total int)"
+func routineSignature(routine RoutineInfo) string {
+	params := make([]string, 0, len(routine.Parameters))
+	for _, p := range routine.Parameters {
+		if routine.RoutineType == "PROCEDURE" {
+			params = append(params, fmt.Sprintf("%s %s %s", p.Mode, p.Name, p.DataType))
+		} else {
+			params = append(params, fmt.Sprintf("%s %s", p.Name, p.DataType))
+		}
+	}
+
+	signature := fmt.Sprintf("%s(%s)", routine.RoutineName, strings.Join(params, ", "))
+	if routine.ReturnType != nil {
+		signature += " RETURNS " + *routine.ReturnType
+	}
+	return signature
+}
+
+// struct represents the outcome of a stored procedure call
+type CallResult struct {
+	ResultSets [][]map[string]interface{} `json:"result_sets"`
+	OutParams  map[string]interface{}     `json:"out_params"`
+}
+
+// @Summary Call Stored Procedure
+// @Description Runs CALL on a stored procedure of the current schema, binding the named IN/INOUT parameters from the JSON body. Returns every result set produced plus the values of the OUT/INOUT parameters.
+// @Tags Database
+// @Accept json
+// @Produce json
+// @Param procedure path string true "Name of the procedure"
+// @Param body body object true "JSON object with the procedure parameters by name" example({"role": "role1"})
+// @Success 200 {object} CallResult "Result sets and OUT parameters"
+// @Failure 400 {object} map[string]string "Invalid input or missing parameter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Procedure not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /call/{procedure} [post]
+func (app *App) callProcedureHandler(w http.ResponseWriter, r *http.Request) {
+	procedure := mux.Vars(r)["procedure"]
+	if !isAlphaNumeric(procedure) {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	input := map[string]interface{}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+			return
+		}
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
+		return
+	}
+	// routines not granted by POLICY_FILE are reported as not found
+	if !app.allowsRoutine(app.getSession(r), procedure) {
+		WriteErrorResponse(w, http.StatusNotFound, errProcNotFound)
+		return
+	}
+
+	parameters, err := fetchProcedureParameters(db, procedure)
+	if err == sql.ErrNoRows {
+		WriteErrorResponse(w, http.StatusNotFound, errProcNotFound)
+		return
+	}
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return
+	}
+
+	known := make(map[string]bool, len(parameters))
+	for _, p := range parameters {
+		known[p.Name] = true
+	}
+	for name := range input {
+		if !known[name] {
+			WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown parameter '%s'", name))
+			return
+		}
+	}
+
+	// OUT and INOUT parameters are bound to session variables, so the call and the
+	// reads of those variables must run on the same connection
+	conn, err := db.Conn(r.Context())
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
+		return
+	}
+	defer conn.Close()
+
+	placeholders := make([]string, 0, len(parameters))
+	args := make([]interface{}, 0, len(parameters))
+	outVars := make([]string, 0, len(parameters))
+	outNames := make([]string, 0, len(parameters))
+	for i, p := range parameters {
+		value, provided := input[p.Name]
+		if p.Mode == "IN" {
+			if !provided {
+				WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf(errMissingParam, p.Name))
+				return
+			}
+			placeholders = append(placeholders, "?")
+			args = append(args, value)
+			continue
+		}
+
+		variable := fmt.Sprintf("@crudder_p%d", i+1)
+		if _, err := conn.ExecContext(r.Context(), fmt.Sprintf("SET %s = ?", variable), value); err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+			return
+		}
+		placeholders = append(placeholders, variable)
+		outVars = append(outVars, fmt.Sprintf("%s AS %s", variable, quoteIdentifier(p.Name)))
+		outNames = append(outNames, p.Name)
+	}
+
+	query := fmt.Sprintf("CALL %s(%s)", quoteIdentifier(procedure), strings.Join(placeholders, ", "))
+	rows, err := conn.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error calling procedure: %v", err))
+		return
+	}
+
+	result := CallResult{ResultSets: [][]map[string]interface{}{}, OutParams: map[string]interface{}{}}
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			WriteErrorResponse(w, http.StatusInternalServerError, errColumnNotFound)
+			return
+		}
+		records := []map[string]interface{}{}
+		for rows.Next() {
+			item, err := processRowWithColumns(rows, columns)
+			if err != nil {
+				rows.Close()
+				WriteErrorResponse(w, http.StatusInternalServerError, errRecords)
+				return
+			}
+			records = append(records, item)
+		}
+		if len(columns) > 0 {
+			result.ResultSets = append(result.ResultSets, records)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error calling procedure: %v", err))
+		return
+	}
+
+	if len(outVars) > 0 {
+		outRows, err := conn.QueryContext(r.Context(), "SELECT "+strings.Join(outVars, ", "))
+		if err != nil {
+			WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+			return
+		}
+		defer outRows.Close()
+
+		if outRows.Next() {
+			values, err := processRowWithColumns(outRows, outNames)
+			if err != nil {
+				WriteErrorResponse(w, http.StatusInternalServerError, errScanRow)
+				return
+			}
+			result.OutParams = values
+		}
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusOK, result)
+}
+
+// fetchProcedureParameters returns the parameters of a stored procedure in declaration
+// order, or sql.ErrNoRows when the schema has no procedure with that name
+func fetchProcedureParameters(db *sql.DB, procedure string) ([]RoutineParameter, error) {
+	var count int
+	err := db.QueryRow(`
+        SELECT COUNT(*)
+        FROM information_schema.routines
+        WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_NAME = ? AND ROUTINE_TYPE = 'PROCEDURE'
+    `, procedure).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.Query(`
+        SELECT PARAMETER_MODE, PARAMETER_NAME, DTD_IDENTIFIER
+        FROM information_schema.parameters
+        WHERE SPECIFIC_SCHEMA = DATABASE() AND SPECIFIC_NAME = ? AND ROUTINE_TYPE = 'PROCEDURE'
+        ORDER BY ORDINAL_POSITION
+    `, procedure)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parameters []RoutineParameter
+	for rows.Next() {
+		var param RoutineParameter
+		if err := rows.Scan(&param.Mode, &param.Name, &param.DataType); err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, param)
+	}
+	return parameters, rows.Err()
+}
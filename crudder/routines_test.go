@@ -0,0 +1,248 @@
+package crudder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	routinesQuery          = `SELECT ROUTINE_NAME, ROUTINE_TYPE, DTD_IDENTIFIER, ROUTINE_COMMENT FROM information_schema\.routines`
+	routineParametersQuery = `SELECT SPECIFIC_NAME, PARAMETER_MODE, PARAMETER_NAME, DTD_IDENTIFIER FROM information_schema\.parameters`
+)
+
+func newRoutinesRequest(t *testing.T) (*http.Request, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/routines", nil)
+	return r.WithContext(context.WithValue(r.Context(), userDBKey, db)), mock
+}
+
+func TestListRoutinesHandler(t *testing.T) {
+	r, mock := newRoutinesRequest(t)
+
+	mock.ExpectQuery(routineParametersQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"SPECIFIC_NAME", "PARAMETER_MODE", "PARAMETER_NAME", "DTD_IDENTIFIER"}).
+			AddRow("count_by_role", "IN", "role", "varchar(100)").
+			AddRow("count_by_role", "OUT", "total", "int").
+			AddRow("full_name", nil, "id", "int"))
+	mock.ExpectQuery(routinesQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"ROUTINE_NAME", "ROUTINE_TYPE", "DTD_IDENTIFIER", "ROUTINE_COMMENT"}).
+			AddRow("count_by_role", "PROCEDURE", nil, "counts users").
+			AddRow("full_name", "FUNCTION", "varchar(200)", "").
+			AddRow("noop", "PROCEDURE", nil, ""))
+
+	w := httptest.NewRecorder()
+	(&App{}).listRoutinesHandler(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"routine_name":"count_by_role","routine_type":"PROCEDURE","comment":"counts users",
+		 "parameters":[{"name":"role","mode":"IN","data_type":"varchar(100)"},{"name":"total","mode":"OUT","data_type":"int"}],
+		 "signature":"count_by_role(IN role varchar(100), OUT total int)"},
+		{"routine_name":"full_name","routine_type":"FUNCTION","return_type":"varchar(200)","comment":"",
+		 "parameters":[{"name":"id","mode":"IN","data_type":"int"}],
+		 "signature":"full_name(id int) RETURNS varchar(200)"},
+		{"routine_name":"noop","routine_type":"PROCEDURE","comment":"","parameters":[],"signature":"noop()"}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRoutinesHandler_DatabaseNotFoundInContext(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&App{}).listRoutinesHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/routines", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestListRoutinesHandler_ParametersQueryError(t *testing.T) {
+	r, mock := newRoutinesRequest(t)
+	mock.ExpectQuery(routineParametersQuery).WillReturnError(errors.New("boom"))
+
+	w := httptest.NewRecorder()
+	(&App{}).listRoutinesHandler(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"message":"Error querying routine parameters"}`, w.Body.String())
+}
+
+func TestListRoutinesHandler_RoutinesQueryError(t *testing.T) {
+	r, mock := newRoutinesRequest(t)
+	mock.ExpectQuery(routineParametersQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"SPECIFIC_NAME", "PARAMETER_MODE", "PARAMETER_NAME", "DTD_IDENTIFIER"}))
+	mock.ExpectQuery(routinesQuery).WillReturnError(errors.New("boom"))
+
+	w := httptest.NewRecorder()
+	(&App{}).listRoutinesHandler(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"message":"Error querying routines"}`, w.Body.String())
+}
+
+func TestListRoutinesHandler_ScanError(t *testing.T) {
+	r, mock := newRoutinesRequest(t)
+	mock.ExpectQuery(routineParametersQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"SPECIFIC_NAME", "PARAMETER_MODE", "PARAMETER_NAME", "DTD_IDENTIFIER"}))
+	mock.ExpectQuery(routinesQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"ROUTINE_NAME"}).AddRow("broken"))
+
+	w := httptest.NewRecorder()
+	(&App{}).listRoutinesHandler(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func newCallRequest(procedure, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/call/"+procedure, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"procedure": procedure})
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	return req
+}
+
+func expectProcedure(mock sqlmock.Sqlmock, procedure string, params ...[]string) {
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema\.routines`).
+		WithArgs(procedure).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+
+	rows := sqlmock.NewRows([]string{"PARAMETER_MODE", "PARAMETER_NAME", "DTD_IDENTIFIER"})
+	for _, p := range params {
+		rows.AddRow(p[0], p[1], p[2])
+	}
+	mock.ExpectQuery(`SELECT PARAMETER_MODE, PARAMETER_NAME, DTD_IDENTIFIER FROM information_schema\.parameters`).
+		WithArgs(procedure).
+		WillReturnRows(rows)
+}
+
+func TestCallProcedureHandler(t *testing.T) {
+	t.Run("Success with result sets and OUT parameters", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		expectProcedure(mock, "report",
+			[]string{"IN", "role", "varchar(100)"},
+			[]string{"OUT", "total", "int"},
+			[]string{"INOUT", "counter", "int"})
+
+		mock.ExpectExec(regexp.QuoteMeta("SET @crudder_p2 = ?")).WithArgs(nil).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("SET @crudder_p3 = ?")).WithArgs(float64(5)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("CALL `report`(?, @crudder_p2, @crudder_p3)")).
+			WithArgs("role1").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"username"}).AddRow([]byte("user1")).AddRow("user2"),
+				sqlmock.NewRows([]string{"role_id", "role"}).AddRow(1, "role1"),
+			)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT @crudder_p2 AS `total`, @crudder_p3 AS `counter`")).
+			WillReturnRows(sqlmock.NewRows([]string{"total", "counter"}).AddRow(2, []byte("6")))
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{"role": "role1", "counter": 5}`))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{
+			"result_sets": [
+				[{"username": "user1"}, {"username": "user2"}],
+				[{"role_id": 1, "role": "role1"}]
+			],
+			"out_params": {"total": 2, "counter": "6"}
+		}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Procedure without parameters or results", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		expectProcedure(mock, "cleanup")
+		mock.ExpectQuery(regexp.QuoteMeta("CALL `cleanup`()")).WillReturnRows(sqlmock.NewRows(nil))
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("cleanup", ""))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"result_sets": [], "out_params": {}}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Procedure not found", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema\.routines`).
+			WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("missing", `{}`))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"message":"Procedure not found"}`, w.Body.String())
+	})
+
+	t.Run("Missing IN parameter", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		expectProcedure(mock, "report", []string{"IN", "role", "varchar(100)"})
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Missing parameter 'role'"}`, w.Body.String())
+	})
+
+	t.Run("Unknown parameter", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		expectProcedure(mock, "report", []string{"IN", "role", "varchar(100)"})
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{"role": "x", "other": 1}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Unknown parameter 'other'"}`, w.Body.String())
+	})
+
+	t.Run("Call error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		expectProcedure(mock, "report", []string{"IN", "role", "varchar(100)"})
+		mock.ExpectQuery(regexp.QuoteMeta("CALL `report`(?)")).WithArgs("x").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{"role": "x"}`))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"message":"Error calling procedure: boom"}`, w.Body.String())
+	})
+
+	t.Run("Invalid procedure name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("drop$table", `{}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid JSON body", func(t *testing.T) {
+		app, _ := newMockApp(t)
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+
+		w := httptest.NewRecorder()
+		app.callProcedureHandler(w, newCallRequest("report", `{}`))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
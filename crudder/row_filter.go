@@ -0,0 +1,209 @@
+package crudder
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// sessionValuePattern matches the :session.<name> references of a row filter
+var sessionValuePattern = regexp.MustCompile(`:session\.([A-Za-z0-9_]+)`)
+
+// builtinSessionValues are the values every session offers to row filters; the
+// policy adds its own in the session object of each user
+var builtinSessionValues = []string{"username", "database", "engine", "profile"}
+
+// rowFilter is the SQL predicate limiting the rows of a table a user may use, with
+// its :session values already bound
+type rowFilter struct {
+	parts []string      // the predicate split around each placeholder
+	args  []interface{} // the value of each placeholder
+}
+
+// queryer runs statements on a connection or inside a transaction
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sessionReferences returns the :session names a predicate uses
+func sessionReferences(where string) []string {
+	var names []string
+	for _, match := range sessionValuePattern.FindAllStringSubmatch(where, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// validateRowFilters checks that the session values named by the row filters a user
+// gets are set, so a request never fails on a missing value
+func (p *Policy) validateRowFilters(name string, user *PolicyGrant) error {
+	for key := range user.Session {
+		if containsFold(builtinSessionValues, key) {
+			return fmt.Errorf("user %q: session value %q is set by crudder", name, key)
+		}
+	}
+	grants := []*PolicyGrant{user}
+	for _, role := range user.Roles {
+		grants = append(grants, p.Roles[role])
+	}
+	for _, grant := range grants {
+		for table, t := range grant.Tables {
+			for _, ref := range sessionReferences(t.Where) {
+				if _, ok := user.Session[ref]; !ok && !containsFold(builtinSessionValues, ref) {
+					return fmt.Errorf("user %q: the row filter of table %q needs session value %q", name, table, ref)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// filter returns the row filter of an operation: the predicates of the grants that
+// allow it, ORed together, or nil when one of them has none
+func (a *tableAccess) filter(op string) (*rowFilter, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var predicates []string
+	for _, t := range a.grants {
+		if !containsFold(t.Operations, op) {
+			continue
+		}
+		if strings.TrimSpace(t.Where) == "" {
+			return nil, nil
+		}
+		predicates = append(predicates, "("+t.Where+")")
+	}
+	if len(predicates) == 0 {
+		return nil, nil
+	}
+	return bindSessionValues(strings.Join(predicates, " OR "), a.values)
+}
+
+// bindSessionValues replaces the :session references of a predicate with placeholders
+func bindSessionValues(where string, values map[string]string) (*rowFilter, error) {
+	f := &rowFilter{}
+	last := 0
+	for _, loc := range sessionValuePattern.FindAllStringSubmatchIndex(where, -1) {
+		name := where[loc[2]:loc[3]]
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf(errSessionValue, name)
+		}
+		f.parts = append(f.parts, where[last:loc[0]])
+		f.args = append(f.args, value)
+		last = loc[1]
+	}
+	f.parts = append(f.parts, where[last:])
+	return f, nil
+}
+
+// sql returns the predicate with placeholders numbered from first
+func (f *rowFilter) sql(d Dialect, first int) string {
+	var b strings.Builder
+	for i, part := range f.parts {
+		b.WriteString(part)
+		if i < len(f.args) {
+			b.WriteString(d.Placeholder(first + i))
+		}
+	}
+	return b.String()
+}
+
+// matches reports whether a row with the given key exists and satisfies the filter,
+// as seen by q
+func (f *rowFilter) matches(q queryer, d Dialect, table, primaryKey string, id interface{}) (found, match bool, err error) {
+	query := fmt.Sprintf("SELECT CASE WHEN %s THEN 1 ELSE 0 END FROM %s WHERE %s = %s",
+		f.sql(d, 1), d.QuoteIdentifier(table), d.QuoteIdentifier(primaryKey), d.Placeholder(len(f.args)+1))
+	var result int
+	err = q.QueryRow(query, append(append([]interface{}{}, f.args...), id)...).Scan(&result)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	return err == nil, result == 1, err
+}
+
+// beginFiltered starts a transaction when the rows written must be checked against one
+// of the row filters before they are committed
+func beginFiltered(db *sql.DB, filters ...*rowFilter) (queryer, *sql.Tx, error) {
+	for _, filter := range filters {
+		if filter != nil {
+			tx, err := db.Begin()
+			return tx, tx, err
+		}
+	}
+	return db, nil, nil
+}
+
+// commitFiltered commits tx when the row written in it satisfies filter, so nobody
+// creates rows or moves rows where they could not see them; otherwise it rolls tx back
+// and writes the error response itself. Without a filter tx is committed as is
+func commitFiltered(w http.ResponseWriter, tx *sql.Tx, filter *rowFilter, d Dialect, table, primaryKey string, id interface{}) bool {
+	if tx == nil {
+		return true
+	}
+	if filter != nil {
+		_, match, err := filter.matches(tx, d, table, primaryKey, id)
+		if err != nil {
+			tx.Rollback()
+			WriteErrorResponse(w, http.StatusInternalServerError, "Error checking the row filter")
+			log.Println("error checking row filter:", err)
+			return false
+		}
+		if !match {
+			tx.Rollback()
+			WriteErrorResponse(w, http.StatusForbidden, errRowDenied)
+			return false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error committing the change")
+		log.Println("error committing filtered write:", err)
+		return false
+	}
+	return true
+}
+
+// sessionValues returns the values the row filters of session can use
+func (app *App) sessionValues(session *SessionData) map[string]string {
+	values := make(map[string]string)
+	var login sessionLogin
+	if session != nil && session.login != nil {
+		login = *session.login
+	}
+	user := app.Policy.Users[login.Username]
+	if user == nil {
+		user = app.Policy.Users[policyWildcard]
+	}
+	if user != nil {
+		for name, value := range user.Session {
+			values[name] = value
+		}
+	}
+	values["username"], values["database"], values["engine"], values["profile"] = login.Username, login.Database, login.Engine, login.Profile
+	return values
+}
+
+// rowFilter returns the row filter of the user of session for an operation on a
+// table, writing the error response itself when it cannot be built
+func (app *App) rowFilter(w http.ResponseWriter, session *SessionData, table, op string) (*rowFilter, bool) {
+	filter, err := app.tableAccess(session, table).filter(op)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusForbidden, err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// andClause returns " AND (<filter>)" for a query whose own placeholders end at last,
+// with the arguments to append; nothing without a filter
+func (f *rowFilter) andClause(d Dialect, last int) (string, []interface{}) {
+	if f == nil {
+		return "", nil
+	}
+	return " AND (" + f.sql(d, last+1) + ")", f.args
+}
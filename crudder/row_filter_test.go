@@ -0,0 +1,167 @@
+package crudder
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindSessionValues(t *testing.T) {
+	f, err := bindSessionValues("tenant_id = :session.tenant AND owner <> :session.username", map[string]string{"tenant": "acme", "username": "ana"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"acme", "ana"}, f.args)
+	assert.Equal(t, "tenant_id = ? AND owner <> ?", f.sql(mysqlDialect{}, 1))
+	assert.Equal(t, "tenant_id = $3 AND owner <> $4", f.sql(postgresDialect{}, 3))
+
+	clause, args := f.andClause(postgresDialect{}, 1)
+	assert.Equal(t, " AND (tenant_id = $2 AND owner <> $3)", clause)
+	assert.Len(t, args, 2)
+
+	var none *rowFilter
+	clause, args = none.andClause(mysqlDialect{}, 1)
+	assert.Empty(t, clause)
+	assert.Empty(t, args)
+
+	_, err = bindSessionValues("tenant_id = :session.tenant", nil)
+	assert.EqualError(t, err, "Row filter needs session value 'tenant'")
+}
+
+func TestTableAccessFilter(t *testing.T) {
+	a := &tableAccess{
+		grants: []*TablePolicy{
+			{Operations: []string{"read", "update"}, Where: "tenant_id = :session.tenant"},
+			{Operations: []string{"read"}, Where: "public = 1"},
+		},
+		values: map[string]string{"tenant": "acme"},
+	}
+	f, err := a.filter(opRead)
+	require.NoError(t, err)
+	assert.Equal(t, "(tenant_id = ?) OR (public = 1)", f.sql(mysqlDialect{}, 1))
+
+	f, err = a.filter(opUpdate)
+	require.NoError(t, err)
+	assert.Equal(t, "(tenant_id = ?)", f.sql(mysqlDialect{}, 1))
+
+	a.grants = append(a.grants, &TablePolicy{Operations: []string{"read"}})
+	f, err = a.filter(opRead)
+	require.NoError(t, err)
+	assert.Nil(t, f, "a grant without filter sees every row")
+}
+
+func TestLoadPolicyRowFilters(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing value": `{"users": {"ana": {"tables": {"orders": {"operations": ["read"], "where": "tenant_id = :session.tenant"}}}}}`,
+		"missing value of a role": `{"roles": {"sales": {"tables": {"orders": {"operations": ["read"], "where": "region = :session.region"}}}},
+			"users": {"ana": {"roles": ["sales"], "session": {"tenant": "acme"}}}}`,
+		"builtin value":      `{"users": {"ana": {"session": {"username": "bob"}}}}`,
+		"several statements": `{"users": {"ana": {"tables": {"orders": {"operations": ["read"], "where": "1 = 1; DROP TABLE orders"}}}}}`,
+	} {
+		_, err := LoadPolicy(writePolicy(t, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadPolicy(writePolicy(t, `{"users": {"ana": {"session": {"tenant": "acme"},
+		"tables": {"orders": {"operations": ["read"], "where": "tenant_id = :session.tenant AND owner = :session.username"}}}}}`))
+	assert.NoError(t, err)
+}
+
+func TestRowFilterEnforcement(t *testing.T) {
+	app, db := newSQLiteTestApp(t)
+	_, err := db.Exec(`ALTER TABLE users ADD COLUMN tenant TEXT;
+		INSERT INTO users (id, username, tenant) VALUES (1, 'ana', 'acme'), (2, 'bob', 'globex');`)
+	require.NoError(t, err)
+
+	app.Policy, err = LoadPolicy(writePolicy(t, `{"users": {"*": {
+		"session": {"tenant": "acme"},
+		"tables": {"users": {"operations": ["read", "create", "update", "delete"], "where": "tenant = :session.tenant"}}
+	}}}`))
+	require.NoError(t, err)
+	storedSession(app, "mockSession").login = &sessionLogin{Engine: "sqlite", Username: "ana"}
+
+	tenantOf := func(id int) string {
+		var tenant string
+		require.NoError(t, db.QueryRow(`SELECT tenant FROM users WHERE id = ?`, id).Scan(&tenant))
+		return tenant
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodGet, "/api/v1/crud/users", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rows []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		require.Len(t, rows, 1)
+		assert.Equal(t, "ana", rows[0]["username"])
+
+		w = serveSQLite(app, http.MethodGet, "/api/v1/crud/users/2", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodPut, "/api/v1/crud/users/2", `{"username":"taken"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serveSQLite(app, http.MethodPut, "/api/v1/crud/users/1", `{"tenant":"globex"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "rows cannot be moved to another tenant")
+		assert.Equal(t, "acme", tenantOf(1))
+
+		w = serveSQLite(app, http.MethodPut, "/api/v1/crud/users/1", `{"username":"ana.lima"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Insert", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodPost, "/api/v1/crud/users", `{"username":"eve","tenant":"globex"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"Row not allowed by the row filter of this table"}`, w.Body.String())
+
+		w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users?upsert=true", `{"id":2,"username":"bob","tenant":"acme"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "upserts cannot take over other rows")
+		assert.Equal(t, "globex", tenantOf(2))
+
+		w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users?upsert=true", `{"username":"bob","tenant":"acme"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "filtered upserts need the key")
+
+		w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users?upsert=true", `{"id":1,"username":"ana","tenant":"globex"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "upserts cannot move rows to another tenant")
+		assert.Equal(t, "acme", tenantOf(1))
+
+		w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users?upsert=true", `{"id":1,"username":"ana","tenant":"acme"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var username string
+		require.NoError(t, db.QueryRow(`SELECT username FROM users WHERE id = 1`).Scan(&username))
+		assert.Equal(t, "ana", username)
+
+		w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users", `{"username":"carla","tenant":"acme"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var count int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count))
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodDelete, "/api/v1/crud/users/2", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = serveSQLite(app, http.MethodDelete, "/api/v1/crud/users/1", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
+
+func TestRowFiltersRefuseViewsAndRoutines(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, `{"users": {
+		"acme_app": {"session": {"tenant": "acme"}, "views": ["*"], "routines": ["*"],
+			"tables": {"orders": {"operations": ["read"], "where": "tenant_id = :session.tenant"}}},
+		"ana": {"views": ["*"], "routines": ["*"], "tables": {"orders": {"operations": ["read"]}}}}}`))
+	require.NoError(t, err)
+	app := &App{Policy: policy}
+
+	filtered := &SessionData{login: &sessionLogin{Username: "acme_app"}}
+	assert.False(t, app.allowsView(filtered, "orders_by_day"), "views could read around the row filter")
+	assert.False(t, app.allowsRoutine(filtered, "close_order"), "routines could read around the row filter")
+
+	unfiltered := &SessionData{login: &sessionLogin{Username: "ana"}}
+	assert.True(t, app.allowsView(unfiltered, "orders_by_day"))
+	assert.True(t, app.allowsRoutine(unfiltered, "close_order"))
+}
@@ -0,0 +1,259 @@
+package crudder
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// struct represents a column taking part in an index
+type IndexColumn struct {
+	ColumnName string `json:"column_name"`
+	SubPart    *int64 `json:"sub_part,omitempty"`
+	Descending bool   `json:"descending"`
+}
+
+// struct represents an index of a table, as reported by information_schema.statistics
+type IndexInfo struct {
+	IndexName string        `json:"index_name"`
+	IsUnique  bool          `json:"is_unique"`
+	IsPrimary bool          `json:"is_primary"`
+	IndexType string        `json:"index_type"`
+	Comment   string        `json:"comment"`
+	Columns   []IndexColumn `json:"columns"`
+}
+
+// struct represents a PRIMARY KEY, UNIQUE, FOREIGN KEY or CHECK constraint of a table
+type ConstraintInfo struct {
+	ConstraintName    string   `json:"constraint_name"`
+	ConstraintType    string   `json:"constraint_type"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   *string  `json:"referenced_table,omitempty"`
+	ReferencedColumns []string `json:"referenced_columns,omitempty"`
+	UpdateRule        *string  `json:"update_rule,omitempty"`
+	DeleteRule        *string  `json:"delete_rule,omitempty"`
+	CheckClause       *string  `json:"check_clause,omitempty"`
+}
+
+// @Summary List Table Indexes
+// @Description Retrieves the indexes of a table, one entry per index with its columns in index order.
+// @Tags Database
+// @Produce json
+// @Param table path string true "Table name" default(users)
+// @Success 200 {array} IndexInfo "Indexes of the table"
+// @Failure 400 {object} map[string]string "Invalid table name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /table-structure/{table}/indexes [get]
+func (app *App) tableIndexesHandler(w http.ResponseWriter, r *http.Request) {
+	tableName := mux.Vars(r)["table"]
+	if !isAlphaNumeric(tableName) {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	access := app.tableAccess(app.getSession(r), tableName)
+	if !access.visible() {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return
+	}
+
+	indexes, err := fetchIndexes(db, tableName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table indexes")
+		log.Println("error fetching indexes:", err)
+		return
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusOK, visibleIndexes(access, indexes))
+}
+
+// @Summary List Table Constraints
+// @Description Retrieves the PRIMARY KEY, UNIQUE, FOREIGN KEY and CHECK constraints of a table, including referenced columns, referential actions and check clauses.
+// @Tags Database
+// @Produce json
+// @Param table path string true "Table name" default(user_roles)
+// @Success 200 {array} ConstraintInfo "Constraints of the table"
+// @Failure 400 {object} map[string]string "Invalid table name"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /table-structure/{table}/constraints [get]
+func (app *App) tableConstraintsHandler(w http.ResponseWriter, r *http.Request) {
+	tableName := mux.Vars(r)["table"]
+	if !isAlphaNumeric(tableName) {
+		WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+		return
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	session := app.getSession(r)
+	access := app.tableAccess(session, tableName)
+	if !access.visible() {
+		WriteErrorResponse(w, http.StatusNotFound, errTableNotFound)
+		return
+	}
+
+	constraints, err := fetchConstraints(db, tableName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error querying table constraints")
+		log.Println("error fetching constraints:", err)
+		return
+	}
+
+	// constraints on hidden columns, or referencing hidden tables, are left out
+	referenced := make(map[string]*tableAccess)
+	visible := []ConstraintInfo{}
+	for _, c := range constraints {
+		if c.ReferencedTable != nil {
+			referenced[*c.ReferencedTable] = app.tableAccess(session, *c.ReferencedTable)
+		}
+		if constraintVisible(access, referenced, c) {
+			visible = append(visible, c)
+		}
+	}
+	writeJSONResponseWithStatus(w, http.StatusOK, visible)
+}
+
+// fetchIndexes returns the indexes of a table in the current schema
+func fetchIndexes(db *sql.DB, tableName string) ([]IndexInfo, error) {
+	rows, err := db.Query(`
+        SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, COLLATION, INDEX_TYPE, INDEX_COMMENT
+        FROM information_schema.statistics
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+        ORDER BY INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX
+    `, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var name, indexType, comment string
+		var nonUnique bool
+		var column, collation sql.NullString
+		var subPart sql.NullInt64
+		if err := rows.Scan(&name, &nonUnique, &column, &subPart, &collation, &indexType, &comment); err != nil {
+			return nil, err
+		}
+		indexes = appendIndexRow(indexes, name, nonUnique, column, subPart, collation, indexType, comment)
+	}
+	return indexes, rows.Err()
+}
+
+// appendIndexRow adds one information_schema.statistics row to indexes, which must be
+// ordered by index name and SEQ_IN_INDEX
+func appendIndexRow(indexes []IndexInfo, name string, nonUnique bool, column sql.NullString, subPart sql.NullInt64,
+	collation sql.NullString, indexType, comment string) []IndexInfo {
+	if len(indexes) == 0 || indexes[len(indexes)-1].IndexName != name {
+		indexes = append(indexes, IndexInfo{
+			IndexName: name,
+			IsUnique:  !nonUnique,
+			IsPrimary: name == "PRIMARY",
+			IndexType: indexType,
+			Comment:   comment,
+			Columns:   []IndexColumn{},
+		})
+	}
+
+	// functional index parts have no COLUMN_NAME
+	if column.Valid {
+		indexColumn := IndexColumn{ColumnName: column.String, Descending: collation.String == "D"}
+		if subPart.Valid {
+			indexColumn.SubPart = &subPart.Int64
+		}
+		current := &indexes[len(indexes)-1]
+		current.Columns = append(current.Columns, indexColumn)
+	}
+	return indexes
+}
+
+// fetchConstraints returns the constraints of a table in the current schema
+func fetchConstraints(db *sql.DB, tableName string) ([]ConstraintInfo, error) {
+	rows, err := db.Query(`
+        SELECT tc.CONSTRAINT_NAME, tc.CONSTRAINT_TYPE, rc.UPDATE_RULE, rc.DELETE_RULE, cc.CHECK_CLAUSE
+        FROM information_schema.table_constraints AS tc
+        LEFT JOIN information_schema.referential_constraints AS rc
+        ON rc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+           AND rc.TABLE_NAME = tc.TABLE_NAME
+           AND rc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+        LEFT JOIN information_schema.check_constraints AS cc
+        ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+           AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+           AND tc.CONSTRAINT_TYPE = 'CHECK'
+        WHERE tc.TABLE_SCHEMA = DATABASE() AND tc.TABLE_NAME = ?
+        ORDER BY tc.CONSTRAINT_TYPE = 'PRIMARY KEY' DESC, tc.CONSTRAINT_NAME
+    `, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constraints := []ConstraintInfo{}
+	byName := make(map[string]int)
+	for rows.Next() {
+		var constraint ConstraintInfo
+		var updateRule, deleteRule, checkClause sql.NullString
+		if err := rows.Scan(&constraint.ConstraintName, &constraint.ConstraintType, &updateRule, &deleteRule, &checkClause); err != nil {
+			return nil, err
+		}
+		constraint.Columns = []string{}
+		if updateRule.Valid {
+			constraint.UpdateRule = &updateRule.String
+		}
+		if deleteRule.Valid {
+			constraint.DeleteRule = &deleteRule.String
+		}
+		if checkClause.Valid {
+			constraint.CheckClause = &checkClause.String
+		}
+		byName[constraint.ConstraintName] = len(constraints)
+		constraints = append(constraints, constraint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keyRows, err := db.Query(`
+        SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+        FROM information_schema.key_column_usage
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+        ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION
+    `, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer keyRows.Close()
+
+	for keyRows.Next() {
+		var name, column string
+		var referencedTable, referencedColumn sql.NullString
+		if err := keyRows.Scan(&name, &column, &referencedTable, &referencedColumn); err != nil {
+			return nil, err
+		}
+		i, exists := byName[name]
+		if !exists {
+			continue
+		}
+		constraint := &constraints[i]
+		constraint.Columns = append(constraint.Columns, column)
+		if referencedTable.Valid {
+			constraint.ReferencedTable = &referencedTable.String
+			constraint.ReferencedColumns = append(constraint.ReferencedColumns, referencedColumn.String)
+		}
+	}
+	return constraints, keyRows.Err()
+}
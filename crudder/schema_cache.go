@@ -0,0 +1,172 @@
+package crudder
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultSchemaCacheTTL is used when SCHEMA_CACHE_TTL is not set
+const defaultSchemaCacheTTL = 5 * time.Minute
+
+// schemaCache keeps the table metadata read from information_schema by the routes of
+// a session: the columns and keys of each table, the table list and the snapshot of
+// the whole schema. Entries expire after ttl; a zero ttl disables caching.
+type schemaCache struct {
+	mutex  sync.Mutex
+	ttl    time.Duration
+	tables map[string]*cachedTable
+
+	tableList        []TableInfo
+	tableListExpires time.Time
+	snapshot         *SchemaSnapshot // shared by its readers, never modified
+	snapshotExpires  time.Time
+}
+
+// the columns, with their primary and foreign keys, and the primary key of a table.
+// The primary key may be known before the columns, as the read and delete routes
+// only need the key.
+type cachedTable struct {
+	columns        []ColumnInfo
+	columnsExpires time.Time
+	primaryKey     string
+	primaryExpires time.Time
+}
+
+func (c *schemaCache) entry(tableName string) *cachedTable {
+	if c.tables == nil {
+		c.tables = make(map[string]*cachedTable)
+	}
+	table, exists := c.tables[tableName]
+	if !exists {
+		table = &cachedTable{}
+		c.tables[tableName] = table
+	}
+	return table
+}
+
+// columns returns the columns of a table, reading them from the database when they are
+// not cached or have expired. Unknown tables are not cached.
+func (c *schemaCache) columns(db *sql.DB, d Dialect, tableName string) ([]ColumnInfo, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	table := c.entry(tableName)
+	if now.Before(table.columnsExpires) {
+		return table.columns, nil
+	}
+
+	columns, err := fetchTableColumns(db, d, tableName)
+	if err != nil || len(columns) == 0 {
+		return columns, err
+	}
+	table.columns, table.columnsExpires = columns, now.Add(c.ttl)
+	for _, col := range columns {
+		if col.IsPrimaryKey {
+			table.primaryKey, table.primaryExpires = col.ColumnName, table.columnsExpires
+			break
+		}
+	}
+	return columns, nil
+}
+
+// primaryKey returns the primary key column of a table, from the cached columns when
+// available, otherwise with a single key lookup
+func (c *schemaCache) primaryKey(db *sql.DB, d Dialect, tableName string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	table := c.entry(tableName)
+	if now.Before(table.primaryExpires) {
+		return table.primaryKey, nil
+	}
+
+	var primaryKey string
+	if err := db.QueryRow(d.PrimaryKeyQuery(), tableName).Scan(&primaryKey); err != nil {
+		return "", fmt.Errorf(errFindPrimaryKey, err)
+	}
+	table.primaryKey, table.primaryExpires = primaryKey, now.Add(c.ttl)
+	return primaryKey, nil
+}
+
+// tableInfos returns the tables and views of the schema, as listed by /tables
+func (c *schemaCache) tableInfos(db *sql.DB, d Dialect) ([]TableInfo, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if now.Before(c.tableListExpires) {
+		return c.tableList, nil
+	}
+	tables, err := fetchTableList(db, d)
+	if err != nil {
+		return nil, err
+	}
+	c.tableList, c.tableListExpires = tables, now.Add(c.ttl)
+	return tables, nil
+}
+
+// currentSnapshot returns the structure of the schema, as read by currentSnapshot
+func (c *schemaCache) currentSnapshot(db *sql.DB) (*SchemaSnapshot, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if now.Before(c.snapshotExpires) {
+		return c.snapshot, nil
+	}
+	snapshot, err := currentSnapshot(db)
+	if err != nil {
+		return nil, err
+	}
+	c.snapshot, c.snapshotExpires = snapshot, now.Add(c.ttl)
+	return snapshot, nil
+}
+
+// clear drops every cached table, the table list and the snapshot
+func (c *schemaCache) clear() {
+	c.mutex.Lock()
+	c.tables = nil
+	c.tableList, c.tableListExpires = nil, time.Time{}
+	c.snapshot, c.snapshotExpires = nil, time.Time{}
+	c.mutex.Unlock()
+}
+
+// currentSnapshot returns the structure of the database of the request's session,
+// cached with its other metadata
+func (app *App) currentSnapshot(r *http.Request) (*SchemaSnapshot, error) {
+	session := app.getSession(r)
+	if session == nil {
+		return nil, fmt.Errorf(errSessionNotFound)
+	}
+	return session.schema.currentSnapshot(session.DB)
+}
+
+// invalidateSchemaCache clears the cache of the request's session after its schema changed
+func (app *App) invalidateSchemaCache(r *http.Request) {
+	if session := app.getSession(r); session != nil {
+		session.schema.clear()
+	}
+}
+
+// @Summary Refresh Schema Cache
+// @Description Drops the table metadata cached by the session, so the next requests read the table list, columns and keys from information_schema again. Needed after the schema is changed outside crudder; the DDL and migration endpoints refresh the cache themselves. Entries also expire after SCHEMA_CACHE_TTL.
+// @Tags Schema
+// @Produce json
+// @Success 200 {object} map[string]string "Schema cache cleared"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /schema/refresh [post]
+func (app *App) refreshSchemaCacheHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	if session == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	session.schema.clear()
+	writeJSONResponseWithStatus(w, http.StatusOK, map[string]string{errMessage: "Schema cache cleared"})
+}
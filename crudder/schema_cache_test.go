@@ -0,0 +1,208 @@
+package crudder
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const primaryKeyQuery = `SELECT COLUMN_NAME FROM information_schema\.KEY_COLUMN_USAGE WHERE`
+
+func TestSchemaCacheColumns(t *testing.T) {
+	t.Run("Cached until refreshed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		cache := &schemaCache{ttl: time.Minute}
+
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		for i := 0; i < 2; i++ {
+			columns, err := cache.columns(db, mysqlDialect{}, "users")
+			require.NoError(t, err)
+			assert.Len(t, columns, 4)
+		}
+
+		// the primary key comes with the columns
+		primaryKey, err := cache.primaryKey(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.Equal(t, "id", primaryKey)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		cache.clear()
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		_, err = cache.columns(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expired entry", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		cache := &schemaCache{ttl: time.Minute}
+
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		_, err = cache.columns(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		cache.tables["users"].columnsExpires = time.Now().Add(-time.Second)
+		_, err = cache.columns(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Disabled with zero TTL", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		cache := &schemaCache{}
+
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+		for i := 0; i < 2; i++ {
+			_, err := cache.columns(db, mysqlDialect{}, "users")
+			require.NoError(t, err)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown table and errors are not cached", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		cache := &schemaCache{ttl: time.Minute}
+
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(sqlmock.NewRows(tableStructureColumns))
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnError(errors.New("boom"))
+		mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+
+		columns, err := cache.columns(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.Empty(t, columns)
+		_, err = cache.columns(db, mysqlDialect{}, "users")
+		assert.Error(t, err)
+		columns, err = cache.columns(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.Len(t, columns, 4)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSchemaCachePrimaryKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	cache := &schemaCache{ttl: time.Minute}
+
+	mock.ExpectQuery(primaryKeyQuery).WithArgs("users").WillReturnError(errors.New("boom"))
+	_, err = cache.primaryKey(db, mysqlDialect{}, "users")
+	assert.EqualError(t, err, "Error obtaining primary key: boom")
+
+	mock.ExpectQuery(primaryKeyQuery).WithArgs("users").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("user_id"))
+	for i := 0; i < 2; i++ {
+		primaryKey, err := cache.primaryKey(db, mysqlDialect{}, "users")
+		require.NoError(t, err)
+		assert.Equal(t, "user_id", primaryKey)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaCacheOnWritePaths(t *testing.T) {
+	app, mock := newMockApp(t)
+	storedSession(app, "mockSession").schema.ttl = time.Minute
+
+	// the columns read to validate the first create also give the primary key
+	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("users").WillReturnRows(usersColumnRows())
+	for i := int64(1); i <= 2; i++ {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `users` (`first_name`,`last_name`) VALUES (?,?)")).
+			WithArgs("Ana", "Lima").WillReturnResult(sqlmock.NewResult(i, 1))
+
+		w := httptest.NewRecorder()
+		app.createRecord(w, newDDLRequest(http.MethodPost, "/api/v1/crud/users", `{"first_name":"Ana","last_name":"Lima"}`, nil), "users")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// a DDL statement drops the cache of the session
+	storedSession(app, "mockSession").schema.tableList = []TableInfo{{TableName: "tags"}}
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE `tags`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	w := httptest.NewRecorder()
+	app.dropTableHandler(w, newDDLRequest(http.MethodDelete, "/api/v1/schema/tables/tags", "", map[string]string{"table": "tags"}))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Nil(t, storedSession(app, "mockSession").schema.tables)
+	assert.Nil(t, storedSession(app, "mockSession").schema.tableList)
+}
+
+func TestSchemaCacheTableList(t *testing.T) {
+	app, db := newSQLiteTestApp(t)
+	storedSession(app, "mockSession").schema.ttl = time.Minute
+	tableCount := func() int {
+		w := serveSQLite(app, http.MethodGet, "/api/v1/tables", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var tables []TableInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tables))
+		return len(tables)
+	}
+
+	count := tableCount()
+	_, err := db.Exec(`CREATE TABLE tags (id INTEGER PRIMARY KEY)`)
+	require.NoError(t, err)
+	assert.Equal(t, count, tableCount(), "the list is cached")
+
+	w := serveSQLite(app, http.MethodPost, "/api/v1/schema/refresh", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, count+1, tableCount())
+}
+
+func TestSchemaCacheSnapshot(t *testing.T) {
+	app, mock := newMockApp(t)
+	storedSession(app, "mockSession").schema.ttl = time.Minute
+
+	// the snapshot read for the OpenAPI document is reused by the diagram
+	mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+	expectSnapshot(mock, "crudder_db_test", true)
+	w := httptest.NewRecorder()
+	app.openAPIHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/openapi.json"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = httptest.NewRecorder()
+	app.schemaDiagramHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diagram"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	storedSession(app, "mockSession").schema.clear()
+	mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+	expectSnapshot(mock, "crudder_db_test", false)
+	w = httptest.NewRecorder()
+	app.schemaSnapshotHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/snapshot"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshSchemaCacheHandler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		storedSession(app, "mockSession").schema.entry("users").primaryKey = "id"
+
+		w := httptest.NewRecorder()
+		app.refreshSchemaCacheHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/schema/refresh"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"message":"Schema cache cleared"}`, w.Body.String())
+		assert.Nil(t, storedSession(app, "mockSession").schema.tables)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.refreshSchemaCacheHandler(w, newMigrationRequest(http.MethodPost, "/api/v1/schema/refresh"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,631 @@
+package crudder
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// struct represents the structure of every base table of a schema, as compared by /schema/diff
+type SchemaSnapshot struct {
+	Schema string          `json:"schema"`
+	Tables []TableSnapshot `json:"tables"`
+}
+
+// struct represents the structure of a single table inside a schema snapshot
+type TableSnapshot struct {
+	TableName    string           `json:"table_name"`
+	Engine       string           `json:"engine,omitempty"`
+	TableComment string           `json:"table_comment,omitempty"`
+	Columns      []ColumnInfo     `json:"columns"`
+	Indexes      []IndexInfo      `json:"indexes"`
+	ForeignKeys  []ConstraintInfo `json:"foreign_keys"`
+}
+
+// struct represents a column whose definition differs between the two schemas
+type ColumnChange struct {
+	ColumnName string     `json:"column_name"`
+	From       ColumnInfo `json:"from"`
+	To         ColumnInfo `json:"to"`
+}
+
+// struct represents an index whose definition differs between the two schemas
+type IndexChange struct {
+	IndexName string    `json:"index_name"`
+	From      IndexInfo `json:"from"`
+	To        IndexInfo `json:"to"`
+}
+
+// struct represents a foreign key whose definition differs between the two schemas
+type ForeignKeyChange struct {
+	ConstraintName string         `json:"constraint_name"`
+	From           ConstraintInfo `json:"from"`
+	To             ConstraintInfo `json:"to"`
+}
+
+// struct represents the differences of a table present in both schemas
+type TableDiff struct {
+	TableName          string             `json:"table_name"`
+	AddedColumns       []ColumnInfo       `json:"added_columns"`
+	RemovedColumns     []string           `json:"removed_columns"`
+	ChangedColumns     []ColumnChange     `json:"changed_columns"`
+	AddedIndexes       []IndexInfo        `json:"added_indexes"`
+	RemovedIndexes     []string           `json:"removed_indexes"`
+	ChangedIndexes     []IndexChange      `json:"changed_indexes"`
+	AddedForeignKeys   []ConstraintInfo   `json:"added_foreign_keys"`
+	RemovedForeignKeys []string           `json:"removed_foreign_keys"`
+	ChangedForeignKeys []ForeignKeyChange `json:"changed_foreign_keys"`
+}
+
+// struct represents the changes needed to turn the current schema into the target one.
+// Added items exist only in the target, removed items only in the current schema.
+type SchemaDiff struct {
+	Source        string          `json:"source"`
+	Target        string          `json:"target"`
+	AddedTables   []TableSnapshot `json:"added_tables"`
+	RemovedTables []string        `json:"removed_tables"`
+	ChangedTables []TableDiff     `json:"changed_tables"`
+	Script        []string        `json:"script,omitempty"`
+}
+
+// fetchSchemaSnapshot reads the tables, columns, indexes and foreign keys of a schema
+func fetchSchemaSnapshot(db *sql.DB, schema string) (*SchemaSnapshot, error) {
+	snapshot := &SchemaSnapshot{Schema: schema, Tables: []TableSnapshot{}}
+	byName := make(map[string]*TableSnapshot)
+
+	rows, err := db.Query(`
+        SELECT TABLE_NAME, ENGINE, TABLE_COMMENT
+        FROM information_schema.tables
+        WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+        ORDER BY TABLE_NAME
+    `, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table TableSnapshot
+		var engine, comment sql.NullString
+		if err := rows.Scan(&table.TableName, &engine, &comment); err != nil {
+			return nil, err
+		}
+		table.Engine = engine.String
+		table.TableComment = comment.String
+		table.Columns = []ColumnInfo{}
+		table.Indexes = []IndexInfo{}
+		table.ForeignKeys = []ConstraintInfo{}
+		snapshot.Tables = append(snapshot.Tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range snapshot.Tables {
+		byName[snapshot.Tables[i].TableName] = &snapshot.Tables[i]
+	}
+
+	if err := fetchSnapshotColumns(db, schema, byName); err != nil {
+		return nil, err
+	}
+	if err := fetchSnapshotIndexes(db, schema, byName); err != nil {
+		return nil, err
+	}
+	if err := fetchSnapshotForeignKeys(db, schema, byName); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func fetchSnapshotColumns(db *sql.DB, schema string, tables map[string]*TableSnapshot) error {
+	rows, err := db.Query(`
+        SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COLUMN_TYPE,
+               CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, EXTRA,
+               GENERATION_EXPRESSION, COLLATION_NAME, COLUMN_COMMENT
+        FROM information_schema.columns
+        WHERE TABLE_SCHEMA = ?
+        ORDER BY TABLE_NAME, ORDINAL_POSITION
+    `, schema)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, isNullable string
+		var col ColumnInfo
+		var columnDefault, generationExpression, collation sql.NullString
+		var maxLength, precision, scale sql.NullInt64
+		if err := rows.Scan(&tableName, &col.ColumnName, &col.DataType, &isNullable, &columnDefault, &col.ColumnType,
+			&maxLength, &precision, &scale, &col.Extra, &generationExpression, &collation, &col.ColumnComment); err != nil {
+			return err
+		}
+		table, exists := tables[tableName]
+		if !exists {
+			continue // column of a view
+		}
+		col.IsNullable = isNullable == "YES"
+		if columnDefault.Valid {
+			col.ColumnDefault = &columnDefault.String
+		}
+		setColumnDetails(&col, maxLength, precision, scale, generationExpression, collation)
+		table.Columns = append(table.Columns, col)
+	}
+	return rows.Err()
+}
+
+func fetchSnapshotIndexes(db *sql.DB, schema string, tables map[string]*TableSnapshot) error {
+	rows, err := db.Query(`
+        SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, COLLATION, INDEX_TYPE, INDEX_COMMENT
+        FROM information_schema.statistics
+        WHERE TABLE_SCHEMA = ?
+        ORDER BY TABLE_NAME, INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX
+    `, schema)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, name, indexType, comment string
+		var nonUnique bool
+		var column, collation sql.NullString
+		var subPart sql.NullInt64
+		if err := rows.Scan(&tableName, &name, &nonUnique, &column, &subPart, &collation, &indexType, &comment); err != nil {
+			return err
+		}
+		if table, exists := tables[tableName]; exists {
+			table.Indexes = appendIndexRow(table.Indexes, name, nonUnique, column, subPart, collation, indexType, comment)
+		}
+	}
+	return rows.Err()
+}
+
+func fetchSnapshotForeignKeys(db *sql.DB, schema string, tables map[string]*TableSnapshot) error {
+	rows, err := db.Query(`
+        SELECT k.TABLE_NAME, k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME,
+               rc.UPDATE_RULE, rc.DELETE_RULE
+        FROM information_schema.key_column_usage AS k
+        JOIN information_schema.referential_constraints AS rc
+        ON rc.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA
+           AND rc.TABLE_NAME = k.TABLE_NAME
+           AND rc.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+        WHERE k.TABLE_SCHEMA = ?
+        ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION
+    `, schema)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, name, column, referencedTable, referencedColumn, updateRule, deleteRule string
+		if err := rows.Scan(&tableName, &name, &column, &referencedTable, &referencedColumn, &updateRule, &deleteRule); err != nil {
+			return err
+		}
+		table, exists := tables[tableName]
+		if !exists {
+			continue
+		}
+		fks := table.ForeignKeys
+		if len(fks) == 0 || fks[len(fks)-1].ConstraintName != name {
+			table.ForeignKeys = append(fks, ConstraintInfo{
+				ConstraintName:  name,
+				ConstraintType:  "FOREIGN KEY",
+				Columns:         []string{},
+				ReferencedTable: &referencedTable,
+				UpdateRule:      &updateRule,
+				DeleteRule:      &deleteRule,
+			})
+		}
+		fk := &table.ForeignKeys[len(table.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, referencedColumn)
+	}
+	return rows.Err()
+}
+
+// sameDefinition compares two definitions by their JSON form, so that snapshots read
+// from a file compare equal to the ones read from the database
+func sameDefinition(a, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
+
+// diffSchemas compares the current schema against the target one
+func diffSchemas(current, target *SchemaSnapshot) SchemaDiff {
+	diff := SchemaDiff{
+		Source:        current.Schema,
+		Target:        target.Schema,
+		AddedTables:   []TableSnapshot{},
+		RemovedTables: []string{},
+		ChangedTables: []TableDiff{},
+	}
+
+	currentTables := make(map[string]TableSnapshot, len(current.Tables))
+	for _, table := range current.Tables {
+		currentTables[table.TableName] = table
+	}
+	targetTables := make(map[string]bool, len(target.Tables))
+	for _, table := range target.Tables {
+		targetTables[table.TableName] = true
+		existing, exists := currentTables[table.TableName]
+		if !exists {
+			diff.AddedTables = append(diff.AddedTables, table)
+			continue
+		}
+		if tableDiff, changed := diffTables(existing, table); changed {
+			diff.ChangedTables = append(diff.ChangedTables, tableDiff)
+		}
+	}
+	for _, table := range current.Tables {
+		if !targetTables[table.TableName] {
+			diff.RemovedTables = append(diff.RemovedTables, table.TableName)
+		}
+	}
+
+	sort.Slice(diff.AddedTables, func(i, j int) bool { return diff.AddedTables[i].TableName < diff.AddedTables[j].TableName })
+	sort.Strings(diff.RemovedTables)
+	sort.Slice(diff.ChangedTables, func(i, j int) bool { return diff.ChangedTables[i].TableName < diff.ChangedTables[j].TableName })
+	return diff
+}
+
+func diffTables(current, target TableSnapshot) (TableDiff, bool) {
+	diff := TableDiff{
+		TableName:          target.TableName,
+		AddedColumns:       []ColumnInfo{},
+		RemovedColumns:     []string{},
+		ChangedColumns:     []ColumnChange{},
+		AddedIndexes:       []IndexInfo{},
+		RemovedIndexes:     []string{},
+		ChangedIndexes:     []IndexChange{},
+		AddedForeignKeys:   []ConstraintInfo{},
+		RemovedForeignKeys: []string{},
+		ChangedForeignKeys: []ForeignKeyChange{},
+	}
+
+	columns := make(map[string]ColumnInfo, len(current.Columns))
+	for _, col := range current.Columns {
+		columns[col.ColumnName] = col
+	}
+	kept := make(map[string]bool)
+	for _, col := range target.Columns {
+		kept[col.ColumnName] = true
+		existing, exists := columns[col.ColumnName]
+		switch {
+		case !exists:
+			diff.AddedColumns = append(diff.AddedColumns, col)
+		case !sameDefinition(existing, col):
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{ColumnName: col.ColumnName, From: existing, To: col})
+		}
+	}
+	for _, col := range current.Columns {
+		if !kept[col.ColumnName] {
+			diff.RemovedColumns = append(diff.RemovedColumns, col.ColumnName)
+		}
+	}
+
+	indexes := make(map[string]IndexInfo, len(current.Indexes))
+	for _, index := range current.Indexes {
+		indexes[index.IndexName] = index
+	}
+	kept = make(map[string]bool)
+	for _, index := range target.Indexes {
+		kept[index.IndexName] = true
+		existing, exists := indexes[index.IndexName]
+		switch {
+		case !exists:
+			diff.AddedIndexes = append(diff.AddedIndexes, index)
+		case !sameDefinition(existing, index):
+			diff.ChangedIndexes = append(diff.ChangedIndexes, IndexChange{IndexName: index.IndexName, From: existing, To: index})
+		}
+	}
+	for _, index := range current.Indexes {
+		if !kept[index.IndexName] {
+			diff.RemovedIndexes = append(diff.RemovedIndexes, index.IndexName)
+		}
+	}
+
+	foreignKeys := make(map[string]ConstraintInfo, len(current.ForeignKeys))
+	for _, fk := range current.ForeignKeys {
+		foreignKeys[fk.ConstraintName] = fk
+	}
+	kept = make(map[string]bool)
+	for _, fk := range target.ForeignKeys {
+		kept[fk.ConstraintName] = true
+		existing, exists := foreignKeys[fk.ConstraintName]
+		switch {
+		case !exists:
+			diff.AddedForeignKeys = append(diff.AddedForeignKeys, fk)
+		case !sameDefinition(existing, fk):
+			diff.ChangedForeignKeys = append(diff.ChangedForeignKeys, ForeignKeyChange{ConstraintName: fk.ConstraintName, From: existing, To: fk})
+		}
+	}
+	for _, fk := range current.ForeignKeys {
+		if !kept[fk.ConstraintName] {
+			diff.RemovedForeignKeys = append(diff.RemovedForeignKeys, fk.ConstraintName)
+		}
+	}
+
+	changed := len(diff.AddedColumns)+len(diff.RemovedColumns)+len(diff.ChangedColumns)+
+		len(diff.AddedIndexes)+len(diff.RemovedIndexes)+len(diff.ChangedIndexes)+
+		len(diff.AddedForeignKeys)+len(diff.RemovedForeignKeys)+len(diff.ChangedForeignKeys) > 0
+	return diff, changed
+}
+
+// foreignKeyConstraintSQL renders a named FOREIGN KEY constraint with its referential actions
+func foreignKeyConstraintSQL(fk ConstraintInfo) (string, error) {
+	if !isAlphaNumeric(fk.ConstraintName) || fk.ReferencedTable == nil || !isAlphaNumeric(*fk.ReferencedTable) ||
+		len(fk.Columns) == 0 || len(fk.Columns) != len(fk.ReferencedColumns) {
+		return "", fmt.Errorf("invalid foreign key '%s'", fk.ConstraintName)
+	}
+	columns := make([]string, len(fk.Columns))
+	referenced := make([]string, len(fk.ReferencedColumns))
+	for i := range fk.Columns {
+		if !isAlphaNumeric(fk.Columns[i]) || !isAlphaNumeric(fk.ReferencedColumns[i]) {
+			return "", fmt.Errorf("invalid foreign key '%s'", fk.ConstraintName)
+		}
+		columns[i] = quoteIdentifier(fk.Columns[i])
+		referenced[i] = quoteIdentifier(fk.ReferencedColumns[i])
+	}
+
+	query := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", quoteIdentifier(fk.ConstraintName),
+		strings.Join(columns, ", "), quoteIdentifier(*fk.ReferencedTable), strings.Join(referenced, ", "))
+	clauses := []struct {
+		keyword string
+		rule    *string
+	}{{"ON DELETE", fk.DeleteRule}, {"ON UPDATE", fk.UpdateRule}}
+	for _, clause := range clauses {
+		if clause.rule == nil {
+			continue
+		}
+		switch action := strings.ToUpper(*clause.rule); action {
+		case "RESTRICT", "CASCADE", "SET NULL", "NO ACTION", "SET DEFAULT":
+			query += " " + clause.keyword + " " + action
+		default:
+			return "", fmt.Errorf("invalid referential action '%s'", *clause.rule)
+		}
+	}
+	return query, nil
+}
+
+func dropIndexSQL(tableName, indexName string) string {
+	if indexName == "PRIMARY" {
+		return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", quoteIdentifier(tableName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", quoteIdentifier(tableName), quoteIdentifier(indexName))
+}
+
+// alterScript renders the statements that turn the current schema into the target one.
+// Foreign keys are dropped first and added last, so tables and columns can change freely in between.
+func alterScript(diff SchemaDiff) ([]string, error) {
+	var dropKeys, tables, columns, addIndexes, addKeys, drops []string
+
+	addForeignKey := func(tableName string, fk ConstraintInfo) error {
+		constraint, err := foreignKeyConstraintSQL(fk)
+		if err != nil {
+			return err
+		}
+		addKeys = append(addKeys, fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdentifier(tableName), constraint))
+		return nil
+	}
+	addIndex := func(tableName string, index IndexInfo) error {
+		definition, err := indexDefinitionSQL(index)
+		if err != nil {
+			return err
+		}
+		addIndexes = append(addIndexes, fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdentifier(tableName), definition))
+		return nil
+	}
+
+	for _, table := range diff.AddedTables {
+		query, err := createTableSQL(TableDefinition{TableName: table.TableName, Engine: table.Engine,
+			TableComment: table.TableComment, Columns: table.Columns, Indexes: table.Indexes})
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, query)
+		for _, fk := range table.ForeignKeys {
+			if err := addForeignKey(table.TableName, fk); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, table := range diff.ChangedTables {
+		name := quoteIdentifier(table.TableName)
+		for _, fk := range table.RemovedForeignKeys {
+			dropKeys = append(dropKeys, fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", name, quoteIdentifier(fk)))
+		}
+		for _, change := range table.ChangedForeignKeys {
+			dropKeys = append(dropKeys, fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", name, quoteIdentifier(change.ConstraintName)))
+			if err := addForeignKey(table.TableName, change.To); err != nil {
+				return nil, err
+			}
+		}
+		for _, fk := range table.AddedForeignKeys {
+			if err := addForeignKey(table.TableName, fk); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, index := range table.RemovedIndexes {
+			dropKeys = append(dropKeys, dropIndexSQL(table.TableName, index))
+		}
+		for _, change := range table.ChangedIndexes {
+			dropKeys = append(dropKeys, dropIndexSQL(table.TableName, change.IndexName))
+			if err := addIndex(table.TableName, change.To); err != nil {
+				return nil, err
+			}
+		}
+		for _, index := range table.AddedIndexes {
+			if err := addIndex(table.TableName, index); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, col := range table.AddedColumns {
+			query, err := addColumnSQL(table.TableName, col)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, query)
+		}
+		for _, change := range table.ChangedColumns {
+			query, err := modifyColumnSQL(table.TableName, change.ColumnName, change.To)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, query)
+		}
+		for _, col := range table.RemovedColumns {
+			columns = append(columns, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", name, quoteIdentifier(col)))
+		}
+	}
+
+	for _, table := range diff.RemovedTables {
+		drops = append(drops, "DROP TABLE "+quoteIdentifier(table))
+	}
+
+	script := []string{}
+	for _, group := range [][]string{dropKeys, tables, columns, addIndexes, addKeys, drops} {
+		script = append(script, group...)
+	}
+	return script, nil
+}
+
+// currentSnapshot reads the snapshot of the session's current database
+func currentSnapshot(db *sql.DB) (*SchemaSnapshot, error) {
+	var schema sql.NullString
+	if err := db.QueryRow("SELECT DATABASE()").Scan(&schema); err != nil {
+		return nil, err
+	}
+	if !schema.Valid {
+		return nil, fmt.Errorf("no database selected")
+	}
+	return fetchSchemaSnapshot(db, schema.String)
+}
+
+// writeSchemaDiff compares the current database against target and writes the diff,
+// with the ALTER script when the request asks for ?script=true
+func (app *App) writeSchemaDiff(w http.ResponseWriter, r *http.Request, target *SchemaSnapshot) {
+	current, err := app.currentSnapshot(r)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error reading schema structure")
+		log.Println("error reading schema snapshot:", err)
+		return
+	}
+	// both sides are limited to the tables and columns the policy shows
+	session := app.getSession(r)
+	current, target = app.visibleSnapshot(session, current), app.visibleSnapshot(session, target)
+
+	diff := diffSchemas(current, target)
+	if r.URL.Query().Get("script") == "true" {
+		script, err := alterScript(diff)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("Error generating script: %v", err))
+			return
+		}
+		diff.Script = script
+	}
+	writeJSONResponseWithStatus(w, http.StatusOK, diff)
+}
+
+// @Summary Schema Snapshot
+// @Description Returns the structure of every base table of the current database: columns, indexes and foreign keys. The result can be saved and later compared with POST /schema/diff.
+// @Tags Schema
+// @Produce json
+// @Success 200 {object} SchemaSnapshot "Structure of the current database"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /schema/snapshot [get]
+func (app *App) schemaSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	snapshot, err := app.currentSnapshot(r)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error reading schema structure")
+		log.Println("error reading schema snapshot:", err)
+		return
+	}
+	writeJSONResponseWithStatus(w, http.StatusOK, app.visibleSnapshot(app.getSession(r), snapshot))
+}
+
+// @Summary Diff Against Schema
+// @Description Compares the current database with another schema on the same server and reports added, removed and changed tables, columns, indexes and foreign keys. Added items exist only in the target. With script=true the response includes the ALTER statements that turn the current database into the target.
+// @Tags Schema
+// @Produce json
+// @Param target query string true "Schema to compare against" default(crudder_db_staging)
+// @Param script query bool false "Include the ALTER script" default(false)
+// @Success 200 {object} SchemaDiff "Differences between the schemas"
+// @Failure 400 {object} map[string]string "Invalid target"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Schema not found"
+// @Failure 422 {object} map[string]string "Script cannot be generated"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /schema/diff [get]
+func (app *App) schemaDiffHandler(w http.ResponseWriter, r *http.Request) {
+	targetName := r.URL.Query().Get("target")
+	if !isAlphaNumeric(targetName) {
+		WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf(errMissingParam, "target"))
+		return
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM information_schema.schemata WHERE SCHEMA_NAME = ?", targetName).Scan(&count); err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return
+	}
+	if count == 0 {
+		WriteErrorResponse(w, http.StatusNotFound, errSchemaNotFound)
+		return
+	}
+
+	target, err := fetchSchemaSnapshot(db, targetName)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error reading schema structure")
+		log.Println("error reading schema snapshot:", err)
+		return
+	}
+	app.writeSchemaDiff(w, r, target)
+}
+
+// @Summary Diff Against Snapshot
+// @Description Compares the current database with a snapshot previously saved from GET /schema/snapshot. Added items exist only in the snapshot. With script=true the response includes the ALTER statements that turn the current database into the snapshot.
+// @Tags Schema
+// @Accept json
+// @Produce json
+// @Param body body SchemaSnapshot true "Saved schema snapshot"
+// @Param script query bool false "Include the ALTER script" default(false)
+// @Success 200 {object} SchemaDiff "Differences between the schemas"
+// @Failure 400 {object} map[string]string "Invalid snapshot"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 422 {object} map[string]string "Script cannot be generated"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /schema/diff [post]
+func (app *App) snapshotDiffHandler(w http.ResponseWriter, r *http.Request) {
+	var target SchemaSnapshot
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil || target.Tables == nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid input or JSON decoding error")
+		return
+	}
+
+	if app.getDBFromSession(r) == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	app.writeSchemaDiff(w, r, &target)
+}
@@ -0,0 +1,249 @@
+package crudder
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	snapshotTablesQuery      = `SELECT TABLE_NAME, ENGINE, TABLE_COMMENT FROM information_schema\.tables WHERE TABLE_SCHEMA = \?`
+	snapshotColumnsQuery     = `SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, .* FROM information_schema\.columns WHERE TABLE_SCHEMA = \?`
+	snapshotIndexesQuery     = `SELECT TABLE_NAME, INDEX_NAME, .* FROM information_schema\.statistics WHERE TABLE_SCHEMA = \?`
+	snapshotForeignKeysQuery = `SELECT k\.TABLE_NAME, k\.CONSTRAINT_NAME, .* FROM information_schema\.key_column_usage AS k`
+)
+
+var snapshotColumnColumns = []string{"TABLE_NAME", "COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLUMN_TYPE",
+	"CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE", "EXTRA", "GENERATION_EXPRESSION", "COLLATION_NAME", "COLUMN_COMMENT"}
+
+// expectSnapshot mocks the queries of fetchSchemaSnapshot for a schema holding a users table
+// and, when withRoles is set, a roles table referenced by users.role_id
+func expectSnapshot(mock sqlmock.Sqlmock, schema string, withRoles bool) {
+	tables := sqlmock.NewRows([]string{"TABLE_NAME", "ENGINE", "TABLE_COMMENT"})
+	if withRoles {
+		tables.AddRow("roles", "InnoDB", "")
+	}
+	tables.AddRow("users", "InnoDB", "")
+	mock.ExpectQuery(snapshotTablesQuery).WithArgs(schema).WillReturnRows(tables)
+
+	columns := sqlmock.NewRows(snapshotColumnColumns)
+	if withRoles {
+		columns.AddRow("roles", "role_id", "int", "NO", nil, "int", nil, 10, 0, "auto_increment", "", nil, "")
+	}
+	columns.AddRow("users", "user_id", "int", "NO", nil, "int", nil, 10, 0, "auto_increment", "", nil, "")
+	if withRoles {
+		columns.AddRow("users", "username", "varchar", "NO", nil, "varchar(150)", 150, nil, nil, "", "", "utf8mb4_general_ci", "")
+		columns.AddRow("users", "role_id", "int", "YES", nil, "int", nil, 10, 0, "", "", nil, "")
+	} else {
+		columns.AddRow("users", "username", "varchar", "NO", nil, "varchar(100)", 100, nil, nil, "", "", "utf8mb4_general_ci", "")
+		columns.AddRow("users", "pwd", "varchar", "NO", nil, "varchar(50)", 50, nil, nil, "", "", "utf8mb4_general_ci", "")
+	}
+	columns.AddRow("active_users", "user_id", "int", "NO", nil, "int", nil, 10, 0, "", "", nil, "")
+	mock.ExpectQuery(snapshotColumnsQuery).WithArgs(schema).WillReturnRows(columns)
+
+	indexes := sqlmock.NewRows([]string{"TABLE_NAME", "INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME", "SUB_PART", "COLLATION", "INDEX_TYPE", "INDEX_COMMENT"})
+	if withRoles {
+		indexes.AddRow("roles", "PRIMARY", 0, "role_id", nil, "A", "BTREE", "")
+	}
+	indexes.AddRow("users", "PRIMARY", 0, "user_id", nil, "A", "BTREE", "")
+	if withRoles {
+		indexes.AddRow("users", "role_id", 1, "role_id", nil, "A", "BTREE", "")
+	} else {
+		indexes.AddRow("users", "username", 0, "username", nil, "A", "BTREE", "")
+	}
+	mock.ExpectQuery(snapshotIndexesQuery).WithArgs(schema).WillReturnRows(indexes)
+
+	foreignKeys := sqlmock.NewRows([]string{"TABLE_NAME", "CONSTRAINT_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "UPDATE_RULE", "DELETE_RULE"})
+	if withRoles {
+		foreignKeys.AddRow("users", "users_ibfk_1", "role_id", "roles", "role_id", "NO ACTION", "SET NULL")
+	}
+	mock.ExpectQuery(snapshotForeignKeysQuery).WithArgs(schema).WillReturnRows(foreignKeys)
+}
+
+func TestSchemaSnapshotHandler(t *testing.T) {
+	app, mock := newMockApp(t)
+	mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+	expectSnapshot(mock, "crudder_db_test", true)
+
+	w := httptest.NewRecorder()
+	app.schemaSnapshotHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/snapshot"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var snapshot SchemaSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.Equal(t, "crudder_db_test", snapshot.Schema)
+	require.Len(t, snapshot.Tables, 2)
+
+	users := snapshot.Tables[1]
+	assert.Equal(t, "users", users.TableName)
+	assert.Len(t, users.Columns, 3)
+	assert.True(t, users.Columns[0].IsAutoIncrement)
+	assert.Len(t, users.Indexes, 2)
+	require.Len(t, users.ForeignKeys, 1)
+	assert.Equal(t, []string{"role_id"}, users.ForeignKeys[0].Columns)
+	assert.Equal(t, "roles", *users.ForeignKeys[0].ReferencedTable)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaDiffHandler(t *testing.T) {
+	app, mock := newMockApp(t)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema\.schemata WHERE SCHEMA_NAME = \?`).
+		WithArgs("crudder_db_staging").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	expectSnapshot(mock, "crudder_db_staging", true)
+	mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+	expectSnapshot(mock, "crudder_db_test", false)
+
+	w := httptest.NewRecorder()
+	app.schemaDiffHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diff?target=crudder_db_staging&script=true"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var diff SchemaDiff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(t, "crudder_db_test", diff.Source)
+	assert.Equal(t, "crudder_db_staging", diff.Target)
+	require.Len(t, diff.AddedTables, 1)
+	assert.Equal(t, "roles", diff.AddedTables[0].TableName)
+	assert.Empty(t, diff.RemovedTables)
+
+	require.Len(t, diff.ChangedTables, 1)
+	users := diff.ChangedTables[0]
+	assert.Equal(t, "role_id", users.AddedColumns[0].ColumnName)
+	assert.Equal(t, []string{"pwd"}, users.RemovedColumns)
+	require.Len(t, users.ChangedColumns, 1)
+	assert.Equal(t, "varchar(100)", users.ChangedColumns[0].From.ColumnType)
+	assert.Equal(t, "varchar(150)", users.ChangedColumns[0].To.ColumnType)
+	assert.Equal(t, "role_id", users.AddedIndexes[0].IndexName)
+	assert.Equal(t, []string{"username"}, users.RemovedIndexes)
+	assert.Equal(t, "users_ibfk_1", users.AddedForeignKeys[0].ConstraintName)
+
+	assert.Equal(t, []string{
+		"ALTER TABLE `users` DROP INDEX `username`",
+		"CREATE TABLE `roles` (\n  `role_id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`role_id`)\n) ENGINE=InnoDB",
+		"ALTER TABLE `users` ADD COLUMN `role_id` int NULL",
+		"ALTER TABLE `users` MODIFY COLUMN `username` varchar(150) COLLATE utf8mb4_general_ci NOT NULL",
+		"ALTER TABLE `users` DROP COLUMN `pwd`",
+		"ALTER TABLE `users` ADD KEY `role_id` (`role_id`)",
+		"ALTER TABLE `users` ADD CONSTRAINT `users_ibfk_1` FOREIGN KEY (`role_id`) REFERENCES `roles` (`role_id`) ON DELETE SET NULL ON UPDATE NO ACTION",
+	}, diff.Script)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaDiffHandler_Errors(t *testing.T) {
+	t.Run("Missing target", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.schemaDiffHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diff"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.schemaDiffHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diff?target=other"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Unknown schema", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema\.schemata`).
+			WithArgs("other").
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+
+		w := httptest.NewRecorder()
+		app.schemaDiffHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diff?target=other"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"message":"Schema not found"}`, w.Body.String())
+	})
+
+	t.Run("Snapshot query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema\.schemata`).
+			WithArgs("other").
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+		mock.ExpectQuery(snapshotTablesQuery).WithArgs("other").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.schemaDiffHandler(w, newMigrationRequest(http.MethodGet, "/api/v1/schema/diff?target=other"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestSnapshotDiffHandler(t *testing.T) {
+	t.Run("Identical snapshot", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("crudder_db_test"))
+		expectSnapshot(mock, "crudder_db_test", false)
+		saved := `{"schema":"crudder_db_test","tables":[
+			{"table_name":"users","engine":"InnoDB","columns":[
+				{"column_name":"user_id","data_type":"int","column_type":"int","is_nullable":false,"numeric_precision":10,"numeric_scale":0,
+				 "is_auto_increment":true,"extra":"auto_increment","column_comment":"","is_primary_key":false},
+				{"column_name":"username","data_type":"varchar","column_type":"varchar(100)","is_nullable":false,"character_maximum_length":100,
+				 "is_auto_increment":false,"extra":"","collation":"utf8mb4_general_ci","column_comment":"","is_primary_key":false},
+				{"column_name":"pwd","data_type":"varchar","column_type":"varchar(50)","is_nullable":false,"character_maximum_length":50,
+				 "is_auto_increment":false,"extra":"","collation":"utf8mb4_general_ci","column_comment":"","is_primary_key":false}],
+			 "indexes":[
+				{"index_name":"PRIMARY","is_unique":true,"is_primary":true,"index_type":"BTREE","comment":"","columns":[{"column_name":"user_id","descending":false}]},
+				{"index_name":"username","is_unique":true,"is_primary":false,"index_type":"BTREE","comment":"","columns":[{"column_name":"username","descending":false}]}],
+			 "foreign_keys":[]}]}`
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schema/diff?script=true", strings.NewReader(saved))
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+		w := httptest.NewRecorder()
+		app.snapshotDiffHandler(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"source":"crudder_db_test","target":"crudder_db_test","added_tables":[],"removed_tables":[],"changed_tables":[]}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid body", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schema/diff", strings.NewReader(`{"schema":"x"}`))
+		w := httptest.NewRecorder()
+		app.snapshotDiffHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAlterScript(t *testing.T) {
+	t.Run("Removed table and changed keys", func(t *testing.T) {
+		diff := SchemaDiff{
+			RemovedTables: []string{"legacy"},
+			ChangedTables: []TableDiff{{
+				TableName: "orders",
+				ChangedIndexes: []IndexChange{{IndexName: "PRIMARY", To: IndexInfo{IndexName: "PRIMARY", IsPrimary: true, IsUnique: true,
+					Columns: []IndexColumn{{ColumnName: "id"}, {ColumnName: "tenant_id"}}}}},
+				RemovedForeignKeys: []string{"orders_ibfk_1"},
+			}},
+		}
+
+		script, err := alterScript(diff)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"ALTER TABLE `orders` DROP FOREIGN KEY `orders_ibfk_1`",
+			"ALTER TABLE `orders` DROP PRIMARY KEY",
+			"ALTER TABLE `orders` ADD PRIMARY KEY (`id`, `tenant_id`)",
+			"DROP TABLE `legacy`",
+		}, script)
+	})
+
+	t.Run("Invalid referential action", func(t *testing.T) {
+		rule := "EXPLODE"
+		diff := SchemaDiff{ChangedTables: []TableDiff{{
+			TableName: "orders",
+			AddedForeignKeys: []ConstraintInfo{{ConstraintName: "fk", Columns: []string{"a"}, ReferencedTable: strPtr("t"),
+				ReferencedColumns: []string{"b"}, DeleteRule: &rule}},
+		}}}
+
+		_, err := alterScript(diff)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,156 @@
+package crudder
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	indexesQuery        = `SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, COLLATION, INDEX_TYPE, INDEX_COMMENT FROM information_schema\.statistics`
+	constraintsQuery    = `SELECT tc\.CONSTRAINT_NAME, tc\.CONSTRAINT_TYPE, rc\.UPDATE_RULE, rc\.DELETE_RULE, cc\.CHECK_CLAUSE FROM information_schema\.table_constraints`
+	constraintKeysQuery = `SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM information_schema\.key_column_usage`
+)
+
+var (
+	indexColumns         = []string{"INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME", "SUB_PART", "COLLATION", "INDEX_TYPE", "INDEX_COMMENT"}
+	constraintColumns    = []string{"CONSTRAINT_NAME", "CONSTRAINT_TYPE", "UPDATE_RULE", "DELETE_RULE", "CHECK_CLAUSE"}
+	constraintKeyColumns = []string{"CONSTRAINT_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}
+)
+
+func newTableRequest(path, table string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req = mux.SetURLVars(req, map[string]string{"table": table})
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	return req
+}
+
+func TestTableIndexesHandler(t *testing.T) {
+	app, mock := newMockApp(t)
+	mock.ExpectQuery(indexesQuery).
+		WithArgs("user_roles").
+		WillReturnRows(sqlmock.NewRows(indexColumns).
+			AddRow("PRIMARY", 0, "id", nil, "A", "BTREE", "").
+			AddRow("role_id", 1, "role_id", nil, "A", "BTREE", "").
+			AddRow("user_id", 0, "user_id", nil, "A", "BTREE", "").
+			AddRow("user_id", 0, "role_id", nil, "D", "BTREE", "").
+			AddRow("name_prefix", 1, "name", 10, "A", "BTREE", "prefix").
+			AddRow("functional", 1, nil, nil, "A", "BTREE", ""))
+
+	w := httptest.NewRecorder()
+	app.tableIndexesHandler(w, newTableRequest("/api/v1/table-structure/user_roles/indexes", "user_roles"))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[
+		{"index_name":"PRIMARY","is_unique":true,"is_primary":true,"index_type":"BTREE","comment":"","columns":[{"column_name":"id","descending":false}]},
+		{"index_name":"role_id","is_unique":false,"is_primary":false,"index_type":"BTREE","comment":"","columns":[{"column_name":"role_id","descending":false}]},
+		{"index_name":"user_id","is_unique":true,"is_primary":false,"index_type":"BTREE","comment":"","columns":[
+			{"column_name":"user_id","descending":false},{"column_name":"role_id","descending":true}]},
+		{"index_name":"name_prefix","is_unique":false,"is_primary":false,"index_type":"BTREE","comment":"prefix","columns":[{"column_name":"name","sub_part":10,"descending":false}]},
+		{"index_name":"functional","is_unique":false,"is_primary":false,"index_type":"BTREE","comment":"","columns":[]}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTableIndexesHandler_Errors(t *testing.T) {
+	t.Run("Invalid table name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.tableIndexesHandler(w, newTableRequest("/api/v1/table-structure/x/indexes", "bad$name"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.tableIndexesHandler(w, newTableRequest("/api/v1/table-structure/users/indexes", "users"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(indexesQuery).WithArgs("users").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.tableIndexesHandler(w, newTableRequest("/api/v1/table-structure/users/indexes", "users"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"message":"Error querying table indexes"}`, w.Body.String())
+	})
+}
+
+func TestTableConstraintsHandler(t *testing.T) {
+	app, mock := newMockApp(t)
+	mock.ExpectQuery(constraintsQuery).
+		WithArgs("user_roles").
+		WillReturnRows(sqlmock.NewRows(constraintColumns).
+			AddRow("PRIMARY", "PRIMARY KEY", nil, nil, nil).
+			AddRow("user_id", "UNIQUE", nil, nil, nil).
+			AddRow("user_roles_chk_1", "CHECK", nil, nil, "(`role_id` > 0)").
+			AddRow("user_roles_ibfk_1", "FOREIGN KEY", "NO ACTION", "CASCADE", nil))
+	mock.ExpectQuery(constraintKeysQuery).
+		WithArgs("user_roles").
+		WillReturnRows(sqlmock.NewRows(constraintKeyColumns).
+			AddRow("PRIMARY", "id", nil, nil).
+			AddRow("user_id", "user_id", nil, nil).
+			AddRow("user_id", "role_id", nil, nil).
+			AddRow("user_roles_ibfk_1", "user_id", "users", "user_id").
+			AddRow("unknown", "x", nil, nil))
+
+	w := httptest.NewRecorder()
+	app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/user_roles/constraints", "user_roles"))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[
+		{"constraint_name":"PRIMARY","constraint_type":"PRIMARY KEY","columns":["id"]},
+		{"constraint_name":"user_id","constraint_type":"UNIQUE","columns":["user_id","role_id"]},
+		{"constraint_name":"user_roles_chk_1","constraint_type":"CHECK","columns":[],"check_clause":"(`+"`role_id`"+` > 0)"},
+		{"constraint_name":"user_roles_ibfk_1","constraint_type":"FOREIGN KEY","columns":["user_id"],
+		 "referenced_table":"users","referenced_columns":["user_id"],"update_rule":"NO ACTION","delete_rule":"CASCADE"}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTableConstraintsHandler_Errors(t *testing.T) {
+	t.Run("Invalid table name", func(t *testing.T) {
+		app, _ := newMockApp(t)
+		w := httptest.NewRecorder()
+		app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/x/constraints", "bad$name"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app := &App{SessionStore: NewMemoryStore()}
+		w := httptest.NewRecorder()
+		app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/users/constraints", "users"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Constraints query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(constraintsQuery).WithArgs("users").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/users/constraints", "users"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"message":"Error querying table constraints"}`, w.Body.String())
+	})
+
+	t.Run("Key columns query error", func(t *testing.T) {
+		app, mock := newMockApp(t)
+		mock.ExpectQuery(constraintsQuery).WithArgs("users").WillReturnRows(sqlmock.NewRows(constraintColumns))
+		mock.ExpectQuery(constraintKeysQuery).WithArgs("users").WillReturnError(errors.New("boom"))
+
+		w := httptest.NewRecorder()
+		app.tableConstraintsHandler(w, newTableRequest("/api/v1/table-structure/users/constraints", "users"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
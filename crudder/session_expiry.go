@@ -0,0 +1,119 @@
+package crudder
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	defaultSessionIdleTimeout = 15 * time.Minute
+	defaultSessionMaxAge      = 8 * time.Hour
+	sessionReapInterval       = time.Minute
+)
+
+// durationFromEnv reads a Go duration such as "30s" from the environment, falling back
+// to def when it is unset or invalid
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s %q, using %s", name, value, def)
+		return def
+	}
+	return d
+}
+
+// seen returns the time of the last authenticated request of the session
+func (s *SessionData) seen() time.Time {
+	return time.Unix(0, s.lastSeen.Load())
+}
+
+// touch records activity at t, unless the session was already seen later
+func (s *SessionData) touch(t time.Time) {
+	for {
+		last := s.lastSeen.Load()
+		if t.UnixNano() <= last || s.lastSeen.CompareAndSwap(last, t.UnixNano()) {
+			return
+		}
+	}
+}
+
+// sessionExpiry returns when the session ends: the earliest of the idle and absolute
+// timeouts, or the zero time when neither is set. API keys end at their own expiry.
+func (app *App) sessionExpiry(s *SessionData) time.Time {
+	if s.apiKey != nil {
+		return s.apiKey.ExpiresAt
+	}
+	var expiry time.Time
+	if app.SessionIdleTimeout > 0 {
+		expiry = s.seen().Add(app.SessionIdleTimeout)
+	}
+	if app.SessionMaxAge > 0 {
+		if end := s.created.Add(app.SessionMaxAge); expiry.IsZero() || end.Before(expiry) {
+			expiry = end
+		}
+	}
+	return expiry
+}
+
+func (app *App) sessionExpired(s *SessionData, now time.Time) bool {
+	expiry := app.sessionExpiry(s)
+	return !expiry.IsZero() && !now.Before(expiry)
+}
+
+// touchSession looks a session up and records the activity, returning its new expiry.
+// An expired session is removed from the store and returned with expired set, for the
+// caller to close.
+func (app *App) touchSession(id string, now time.Time) (session *SessionData, expiry time.Time, expired bool) {
+	session, exists := app.SessionStore.Get(id)
+	if !exists {
+		return nil, time.Time{}, false
+	}
+	if app.sessionExpired(session, now) {
+		app.SessionStore.Delete(id)
+		return session, time.Time{}, true
+	}
+	session.touch(now)
+	if err := app.SessionStore.Save(id, session); err != nil {
+		log.Println("error saving session:", err)
+	}
+	return session, app.sessionExpiry(session), false
+}
+
+// reapSessions closes and removes the sessions that expired by now, returning how many
+func (app *App) reapSessions(now time.Time) int {
+	expired := app.SessionStore.Expire(func(session *SessionData) bool {
+		return app.sessionExpired(session, now)
+	})
+	for _, session := range expired {
+		session.close()
+	}
+	return len(expired)
+}
+
+// startSessionReaper reaps the expired sessions every interval until ctx is done. The
+// returned channel is closed once the goroutine has stopped.
+func (app *App) startSessionReaper(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if n := app.reapSessions(now); n > 0 {
+					log.Println("expired sessions closed:", n)
+				}
+			}
+		}
+	}()
+	return done
+}
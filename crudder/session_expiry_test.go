@@ -0,0 +1,139 @@
+package crudder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newExpiringSession returns a session on a mock connection that expects to be closed
+// when closes is set
+func newExpiringSession(t *testing.T, created, lastSeen time.Time, closes bool) (*SessionData, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	if closes {
+		mock.ExpectClose()
+	} else {
+		t.Cleanup(func() { db.Close() })
+	}
+	session := &SessionData{DB: db, created: created}
+	session.touch(lastSeen)
+	return session, mock
+}
+
+func TestSessionExpiry(t *testing.T) {
+	login := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	session := &SessionData{created: login}
+	session.touch(login.Add(time.Hour))
+
+	app := &App{}
+	assert.True(t, app.sessionExpiry(session).IsZero(), "no timeouts")
+	assert.False(t, app.sessionExpired(session, login.Add(1000*time.Hour)))
+
+	app = &App{SessionIdleTimeout: 15 * time.Minute}
+	assert.True(t, login.Add(time.Hour+15*time.Minute).Equal(app.sessionExpiry(session)))
+
+	app = &App{SessionIdleTimeout: 15 * time.Minute, SessionMaxAge: time.Hour}
+	assert.Equal(t, login.Add(time.Hour), app.sessionExpiry(session), "the absolute timeout comes first")
+	assert.True(t, app.sessionExpired(session, login.Add(time.Hour)))
+}
+
+func TestAuthMiddlewareExpiry(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	request := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+		return req
+	}
+
+	t.Run("Idle session is closed", func(t *testing.T) {
+		now := time.Now()
+		session, mock := newExpiringSession(t, now.Add(-time.Hour), now.Add(-20*time.Minute), true)
+		app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): session}), SessionIdleTimeout: 15 * time.Minute}
+
+		w := httptest.NewRecorder()
+		app.authMiddleware(next).ServeHTTP(w, request())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"Session expired"}`, w.Body.String())
+		assert.Zero(t, app.SessionStore.Len())
+		assert.NoError(t, mock.ExpectationsWereMet())
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.True(t, cookies[0].Expires.Before(now))
+	})
+
+	t.Run("Absolute timeout ignores activity", func(t *testing.T) {
+		now := time.Now()
+		session, mock := newExpiringSession(t, now.Add(-9*time.Hour), now.Add(-time.Minute), true)
+		app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): session}), SessionIdleTimeout: 15 * time.Minute, SessionMaxAge: 8 * time.Hour}
+
+		w := httptest.NewRecorder()
+		app.authMiddleware(next).ServeHTTP(w, request())
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Activity renews the session", func(t *testing.T) {
+		now := time.Now()
+		session, _ := newExpiringSession(t, now.Add(-time.Hour), now.Add(-10*time.Minute), false)
+		app := &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): session}), SessionIdleTimeout: 15 * time.Minute, SessionMaxAge: 8 * time.Hour}
+
+		w := httptest.NewRecorder()
+		app.authMiddleware(next).ServeHTTP(w, request())
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, session.seen().Before(now))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "mockSession", cookies[0].Value)
+		assert.WithinDuration(t, now.Add(15*time.Minute), cookies[0].Expires, 2*time.Second)
+	})
+}
+
+func TestReapSessions(t *testing.T) {
+	now := time.Now()
+	expired, expiredMock := newExpiringSession(t, now.Add(-time.Hour), now.Add(-time.Hour), true)
+	live, _ := newExpiringSession(t, now.Add(-time.Hour), now, false)
+	app := &App{
+		SessionStore:       newTestStore(map[string]*SessionData{"expired": expired, "live": live}),
+		SessionIdleTimeout: 15 * time.Minute,
+	}
+
+	assert.Equal(t, 1, app.reapSessions(now))
+	assert.NoError(t, expiredMock.ExpectationsWereMet())
+	assert.Equal(t, 1, app.SessionStore.Len())
+	_, exists := app.SessionStore.Get("live")
+	assert.True(t, exists)
+
+	t.Run("Reaper goroutine", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := app.startSessionReaper(ctx, time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the reaper did not stop")
+		}
+	})
+
+	t.Run("Shutdown closes every session", func(t *testing.T) {
+		session, mock := newExpiringSession(t, now, now, true)
+		app := &App{SessionStore: newTestStore(map[string]*SessionData{"a": session})}
+		app.SessionStore.Close()
+		assert.Zero(t, app.SessionStore.Len())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRealServerListenError(t *testing.T) {
+	assert.Error(t, RealServer{}.ListenAndServe("invalid:address:9091", http.NotFoundHandler()))
+}
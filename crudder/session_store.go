@@ -0,0 +1,181 @@
+package crudder
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// SessionStore keeps the sessions of the logged in users, keyed by the hash of their
+// session token (see hashSessionToken). Implementations are safe for concurrent use.
+type SessionStore interface {
+	// Get returns the session, opening its connection when this process has not used
+	// it yet
+	Get(id string) (*SessionData, bool)
+	// Save adds the session or replaces it, after a login, a schema switch or a request
+	Save(id string, session *SessionData) error
+	// Delete removes the session and returns it when it is open in this process, for
+	// the caller to close
+	Delete(id string) *SessionData
+	// Expire removes the sessions for which expired returns true, returning the ones
+	// open in this process for the caller to close
+	Expire(expired func(*SessionData) bool) []*SessionData
+	// Range calls fn for every session in the store. Sessions that are not open in
+	// this process are passed without a connection.
+	Range(fn func(id string, session *SessionData))
+	// Len returns the number of sessions in the store
+	Len() int
+	// Close closes the connections of the sessions open in this process. Sessions
+	// that outlive the process stay in the store.
+	Close()
+}
+
+// sessionLogin is what a persistent SessionStore needs to reopen a session after a
+// restart or on another replica
+type sessionLogin struct {
+	Engine   string `json:"engine"`
+	Profile  string `json:"profile,omitempty"`
+	Database string `json:"database"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// MemoryStore keeps the sessions in memory; they are lost when the server stops
+type MemoryStore struct {
+	mutex    sync.Mutex
+	sessions map[string]*SessionData
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*SessionData)}
+}
+
+func (s *MemoryStore) Get(id string) (*SessionData, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, exists := s.sessions[id]
+	return session, exists
+}
+
+func (s *MemoryStore) Save(id string, session *SessionData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) *SessionData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session := s.sessions[id]
+	delete(s.sessions, id)
+	return session
+}
+
+func (s *MemoryStore) Expire(expired func(*SessionData) bool) []*SessionData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var removed []*SessionData
+	for id, session := range s.sessions {
+		if expired(session) {
+			removed = append(removed, session)
+			delete(s.sessions, id)
+		}
+	}
+	return removed
+}
+
+func (s *MemoryStore) Range(fn func(id string, session *SessionData)) {
+	s.mutex.Lock()
+	sessions := make(map[string]*SessionData, len(s.sessions))
+	for id, session := range s.sessions {
+		sessions[id] = session
+	}
+	s.mutex.Unlock()
+
+	for id, session := range sessions {
+		fn(id, session)
+	}
+}
+
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.sessions)
+}
+
+// Close closes and forgets every session
+func (s *MemoryStore) Close() {
+	s.mutex.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*SessionData)
+	s.mutex.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+// loadSessionStore returns the store selected by SESSION_STORE_DIR: sessions kept in
+// memory by default, or in files encrypted with SESSION_KEY that survive restarts and
+// can be shared by replicas
+func (app *App) loadSessionStore() (SessionStore, error) {
+	dir := os.Getenv("SESSION_STORE_DIR")
+	if dir == "" {
+		return NewMemoryStore(), nil
+	}
+	key, err := parseSessionKey(os.Getenv("SESSION_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	store, err := newFileStore(dir, key, app.reopenSession)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("sessions are stored in", dir)
+	return store, nil
+}
+
+// reopenSession opens a new connection for a login, with the settings of the server
+// it was opened on: for a session saved by another process, or for an API key
+// issued from a session
+func (app *App) reopenSession(login *sessionLogin) (*SessionData, error) {
+	conn := app.connConfig()
+	var profile *Profile
+	if login.Profile != "" {
+		if profile = app.Profiles[login.Profile]; profile == nil {
+			return nil, fmt.Errorf("server profile %s no longer exists", login.Profile)
+		}
+		conn = profile.conn
+	}
+	dialect, ok := dialectFor(login.Engine)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database engine %s", login.Engine)
+	}
+
+	session, err := app.newSession(login, conn, dialect, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.DB.Ping(); err != nil {
+		session.close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// newSession opens the connection pool of a login, without checking the credentials
+func (app *App) newSession(login *sessionLogin, conn *ConnConfig, dialect Dialect, profile *Profile) (*SessionData, error) {
+	db, err := sqlOpen(dialect.DriverName(), dialect.DSN(conn, login.Username, login.Password, login.Database))
+	if err != nil {
+		return nil, err
+	}
+
+	session := &SessionData{DB: db, dialect: dialect, login: login}
+	session.schema.ttl = app.SchemaTTL
+	if dialect.Name() == "mysql" {
+		newSessionDatabases(session, conn.mysqlConfig(login.Username, login.Password, login.Database), profile)
+	}
+	return session, nil
+}
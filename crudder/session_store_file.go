@@ -0,0 +1,314 @@
+package crudder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionWriteInterval is how often a request rewrites the file of its session just
+// to record the activity; the idle timeout is enforced with this granularity
+const sessionWriteInterval = time.Minute
+
+// sessionRecord is the file of a session in a FileStore. The credentials are sealed
+// with AES-GCM, using the session id as additional data so a record cannot be moved
+// to another session.
+type sessionRecord struct {
+	Credentials []byte    `json:"credentials"`
+	Created     time.Time `json:"created"`
+	LastSeen    time.Time `json:"last_seen"`
+	APIKey      *apiKey   `json:"api_key,omitempty"`
+}
+
+// FileStore keeps each session in its own file of a directory, so sessions survive
+// restarts and replicas sharing the directory see each other's logins. Connections
+// are reopened on the first request a process gets for a session.
+type FileStore struct {
+	dir  string
+	aead cipher.AEAD
+	open func(*sessionLogin) (*SessionData, error)
+
+	mutex    sync.Mutex
+	sessions map[string]*SessionData // sessions open in this process
+	written  map[string]time.Time    // activity last written to the file of each open session
+}
+
+// parseSessionKey decodes SESSION_KEY, 32 random bytes in base64 (openssl rand -base64 32)
+func parseSessionKey(value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("SESSION_KEY is required with SESSION_STORE_DIR")
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("SESSION_KEY must be 32 bytes in base64")
+	}
+	return key, nil
+}
+
+func newFileStore(dir string, key []byte, open func(*sessionLogin) (*SessionData, error)) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating SESSION_STORE_DIR: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, aead: aead, open: open, sessions: make(map[string]*SessionData), written: make(map[string]time.Time)}, nil
+}
+
+// path returns the file of a session; ids are hex digests, anything else is rejected
+func (s *FileStore) path(id string) (string, bool) {
+	if len(id) != 64 || strings.Trim(id, "0123456789abcdef") != "" {
+		return "", false
+	}
+	return filepath.Join(s.dir, id+".json"), true
+}
+
+func (s *FileStore) read(id string) (*sessionRecord, error) {
+	path, ok := s.path(id)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record sessionRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %v", path, err)
+	}
+	return &record, nil
+}
+
+// write replaces the file of a session atomically, so a reader never sees half of it
+func (s *FileStore) write(id string, record *sessionRecord) error {
+	path, ok := s.path(id)
+	if !ok {
+		return fmt.Errorf("invalid session id")
+	}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) seal(id string, login *sessionLogin) ([]byte, error) {
+	plaintext, err := json.Marshal(login)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, []byte(id)), nil
+}
+
+func (s *FileStore) unseal(id string, sealed []byte) (*sessionLogin, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("sealed credentials too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	var login sessionLogin
+	if err := json.Unmarshal(plaintext, &login); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// Get reads the file of the session on every call, so a logout, an expiry or a schema
+// switch on another replica is seen here too
+func (s *FileStore) Get(id string) (*SessionData, bool) {
+	record, err := s.read(id)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println("error reading session:", err)
+		}
+		if session := s.forget(id); session != nil {
+			session.close()
+		}
+		return nil, false
+	}
+	login, err := s.unseal(id, record.Credentials)
+	if err != nil {
+		log.Println("error decrypting session:", err)
+		return nil, false
+	}
+
+	s.mutex.Lock()
+	session := s.sessions[id]
+	s.mutex.Unlock()
+	if session != nil && session.login != nil && *session.login == *login {
+		session.touch(record.LastSeen)
+		return session, true
+	}
+
+	// First request of this process for the session, or the login changed elsewhere
+	reopened, err := s.open(login)
+	if err != nil {
+		log.Println("error reopening session:", err)
+		return nil, false
+	}
+	reopened.created, reopened.apiKey = record.Created, record.APIKey
+	reopened.touch(record.LastSeen)
+
+	// A concurrent request may have reopened it meanwhile; its connection is in use
+	s.mutex.Lock()
+	previous := s.sessions[id]
+	if previous != nil && previous.login != nil && *previous.login == *login {
+		s.mutex.Unlock()
+		reopened.close()
+		return previous, true
+	}
+	s.sessions[id], s.written[id] = reopened, record.LastSeen
+	s.mutex.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+	return reopened, true
+}
+
+// Save writes the session to its file, except when only the activity changed and it
+// was written less than sessionWriteInterval ago
+func (s *FileStore) Save(id string, session *SessionData) error {
+	if session.login == nil {
+		return fmt.Errorf("session cannot be stored: no login")
+	}
+	lastSeen := session.seen()
+
+	s.mutex.Lock()
+	unchanged := s.sessions[id] == session && lastSeen.Sub(s.written[id]) < sessionWriteInterval
+	s.mutex.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	credentials, err := s.seal(id, session.login)
+	if err != nil {
+		return err
+	}
+	if err := s.write(id, &sessionRecord{Credentials: credentials, Created: session.created, LastSeen: lastSeen, APIKey: session.apiKey}); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.sessions[id], s.written[id] = session, lastSeen
+	s.mutex.Unlock()
+	return nil
+}
+
+// forget drops a session from the open ones, returning it
+func (s *FileStore) forget(id string) *SessionData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session := s.sessions[id]
+	delete(s.sessions, id)
+	delete(s.written, id)
+	return session
+}
+
+func (s *FileStore) Delete(id string) *SessionData {
+	if path, ok := s.path(id); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Println("error removing session:", err)
+		}
+	}
+	return s.forget(id)
+}
+
+// Range passes every file of the directory, including the sessions of other
+// processes, as a session without connection
+func (s *FileStore) Range(fn func(id string, session *SessionData)) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Println("error listing sessions:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		record, err := s.read(id)
+		if err != nil {
+			continue
+		}
+		login, err := s.unseal(id, record.Credentials)
+		if err != nil {
+			continue
+		}
+		probe := &SessionData{login: login, created: record.Created, apiKey: record.APIKey}
+		probe.touch(record.LastSeen)
+		s.mutex.Lock()
+		if open := s.sessions[id]; open != nil {
+			probe.touch(open.seen())
+		}
+		s.mutex.Unlock()
+		fn(id, probe)
+	}
+}
+
+// Expire checks every session, including the ones of other processes, against the
+// activity recorded in its file
+func (s *FileStore) Expire(expired func(*SessionData) bool) []*SessionData {
+	var removed []*SessionData
+	s.Range(func(id string, probe *SessionData) {
+		if expired(probe) {
+			if session := s.Delete(id); session != nil {
+				removed = append(removed, session)
+			}
+		}
+	})
+	return removed
+}
+
+func (s *FileStore) Len() int {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	return len(matches)
+}
+
+// Close closes the connections opened by this process and keeps the files, so the
+// sessions are reopened after a restart
+func (s *FileStore) Close() {
+	s.mutex.Lock()
+	sessions := s.sessions
+	s.sessions, s.written = make(map[string]*SessionData), make(map[string]time.Time)
+	s.mutex.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
@@ -0,0 +1,285 @@
+package crudder
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore returns a MemoryStore holding the sessions, keyed by the hash of their token
+func newTestStore(sessions map[string]*SessionData) *MemoryStore {
+	return &MemoryStore{sessions: sessions}
+}
+
+// storedSession returns the session of a token, nil when there is none
+func storedSession(app *App, token string) *SessionData {
+	session, _ := app.SessionStore.Get(hashSessionToken(token))
+	return session
+}
+
+// loginSession returns the session opened by a successful login response
+func loginSession(t *testing.T, app *App, w *httptest.ResponseRecorder) *SessionData {
+	t.Helper()
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	session, exists := app.SessionStore.Get(hashSessionToken(cookies[0].Value))
+	require.True(t, exists)
+	return session
+}
+
+var testSessionKey = []byte("0123456789abcdef0123456789abcdef")
+
+// newTestFileStore returns a FileStore on dir whose sessions reopen on sqlmock
+// connections, counting the reopens
+func newTestFileStore(t *testing.T, dir string, key []byte) (*FileStore, *[]*sessionLogin) {
+	t.Helper()
+	var reopened []*sessionLogin
+	store, err := newFileStore(dir, key, func(login *sessionLogin) (*SessionData, error) {
+		reopened = append(reopened, login)
+		db, _, err := sqlmock.New()
+		return &SessionData{DB: db, login: login}, err
+	})
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+	return store, &reopened
+}
+
+func newStoredSession(t *testing.T, created time.Time) *SessionData {
+	t.Helper()
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	session := &SessionData{DB: db, created: created, login: &sessionLogin{Engine: "mysql", Database: "crudder_db_test", Username: "crudder_user", Password: "s3cr3t"}}
+	session.touch(created)
+	return session
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	session := &SessionData{}
+	require.NoError(t, store.Save("a", session))
+
+	got, exists := store.Get("a")
+	assert.True(t, exists)
+	assert.Same(t, session, got)
+	assert.Equal(t, 1, store.Len())
+
+	assert.Same(t, session, store.Delete("a"))
+	assert.Nil(t, store.Delete("a"))
+	_, exists = store.Get("a")
+	assert.False(t, exists)
+}
+
+func TestParseSessionKey(t *testing.T) {
+	key, err := parseSessionKey(base64.StdEncoding.EncodeToString(testSessionKey))
+	require.NoError(t, err)
+	assert.Equal(t, testSessionKey, key)
+
+	for _, value := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err := parseSessionKey(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	id := hashSessionToken("token")
+	created := time.Now().Add(-time.Hour).Round(0)
+
+	t.Run("Survives a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		session := newStoredSession(t, created)
+		require.NoError(t, store.Save(id, session))
+
+		got, exists := store.Get(id)
+		require.True(t, exists)
+		assert.Same(t, session, got, "the open session is reused")
+
+		content, err := os.ReadFile(filepath.Join(dir, id+".json"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "s3cr3t")
+		assert.NotContains(t, string(content), "crudder_user")
+
+		restarted, reopened := newTestFileStore(t, dir, testSessionKey)
+		got, exists = restarted.Get(id)
+		require.True(t, exists)
+		require.Len(t, *reopened, 1)
+		assert.Equal(t, *session.login, *(*reopened)[0])
+		assert.True(t, created.Equal(got.created))
+		assert.True(t, created.Equal(got.seen()))
+
+		_, exists = restarted.Get(id)
+		assert.True(t, exists)
+		assert.Len(t, *reopened, 1, "the connection is reopened once")
+	})
+
+	t.Run("Concurrent reopen", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		require.NoError(t, store.Save(id, newStoredSession(t, created)))
+
+		restarted, _ := newTestFileStore(t, dir, testSessionKey)
+		winner := newStoredSession(t, created)
+		var duplicate *SessionData
+		open := restarted.open
+		restarted.open = func(login *sessionLogin) (*SessionData, error) {
+			// another request reopens the session while this one does
+			restarted.mutex.Lock()
+			restarted.sessions[id] = winner
+			restarted.mutex.Unlock()
+			var err error
+			duplicate, err = open(login)
+			return duplicate, err
+		}
+
+		got, exists := restarted.Get(id)
+		require.True(t, exists)
+		assert.Same(t, winner, got, "the connection already in use is kept")
+		assert.NoError(t, winner.DB.Ping())
+		assert.Error(t, duplicate.DB.Ping(), "the duplicate connection is closed")
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		require.NoError(t, store.Save(id, newStoredSession(t, created)))
+
+		other, reopened := newTestFileStore(t, dir, []byte("another key of thirty-two bytes!"))
+		_, exists := other.Get(id)
+		assert.False(t, exists)
+		assert.Empty(t, *reopened)
+	})
+
+	t.Run("Record bound to its session", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		require.NoError(t, store.Save(id, newStoredSession(t, created)))
+
+		stolen := hashSessionToken("stolen")
+		content, err := os.ReadFile(filepath.Join(dir, id+".json"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, stolen+".json"), content, 0o600))
+		_, exists := store.Get(stolen)
+		assert.False(t, exists)
+	})
+
+	t.Run("Logout on another replica", func(t *testing.T) {
+		dir := t.TempDir()
+		first, _ := newTestFileStore(t, dir, testSessionKey)
+		second, _ := newTestFileStore(t, dir, testSessionKey)
+		require.NoError(t, first.Save(id, newStoredSession(t, created)))
+
+		_, exists := second.Get(id)
+		require.True(t, exists)
+		assert.NotNil(t, first.Delete(id))
+		_, exists = second.Get(id)
+		assert.False(t, exists)
+		assert.Zero(t, second.Len())
+	})
+
+	t.Run("Activity writes are throttled", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		session := newStoredSession(t, created)
+		require.NoError(t, store.Save(id, session))
+
+		session.touch(created.Add(10 * time.Second))
+		require.NoError(t, store.Save(id, session))
+		record, err := store.read(id)
+		require.NoError(t, err)
+		assert.True(t, created.Equal(record.LastSeen))
+
+		session.touch(created.Add(2 * time.Minute))
+		require.NoError(t, store.Save(id, session))
+		record, err = store.read(id)
+		require.NoError(t, err)
+		assert.True(t, created.Add(2*time.Minute).Equal(record.LastSeen))
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := newTestFileStore(t, dir, testSessionKey)
+		old := newStoredSession(t, created)
+		require.NoError(t, store.Save(id, old))
+		require.NoError(t, store.Save(hashSessionToken("live"), newStoredSession(t, time.Now())))
+
+		removed := store.Expire(func(s *SessionData) bool { return s.seen().Before(time.Now().Add(-time.Minute)) })
+		assert.Equal(t, []*SessionData{old}, removed)
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("Invalid sessions", func(t *testing.T) {
+		store, _ := newTestFileStore(t, t.TempDir(), testSessionKey)
+		_, exists := store.Get("../../etc/passwd")
+		assert.False(t, exists)
+		assert.Error(t, store.Save(id, &SessionData{}), "a session without login cannot be stored")
+	})
+}
+
+func TestFileStoreLogin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "demo.db"))
+	t.Setenv("SESSION_STORE_DIR", dir)
+	t.Setenv("SESSION_KEY", base64.StdEncoding.EncodeToString(testSessionKey))
+
+	newApp := func() *App {
+		app := &App{}
+		store, err := app.loadSessionStore()
+		require.NoError(t, err)
+		app.SessionStore = store
+		t.Cleanup(store.Close)
+		return app
+	}
+
+	app := newApp()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(url.Values{"engine": {"sqlite"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	cookie := w.Result().Cookies()[0]
+
+	// A new process, e.g. after a deploy, reopens the session from its file
+	app.SessionStore.Close()
+	restarted := newApp()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	SetupRouter(restarted).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("Missing key", func(t *testing.T) {
+		t.Setenv("SESSION_KEY", "")
+		_, err := (&App{}).loadSessionStore()
+		assert.Error(t, err)
+	})
+
+	t.Run("Profile removed since the login", func(t *testing.T) {
+		_, err := (&App{}).reopenSession(&sessionLogin{Engine: "mysql", Profile: "dev"})
+		assert.Error(t, err)
+	})
+}
+
+func TestReopenSessionPingError(t *testing.T) {
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+	sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		mock.ExpectPing().WillReturnError(errors.New("access denied"))
+		return db, err
+	}
+
+	_, err := (&App{Conn: &ConnConfig{Host: "db"}}).reopenSession(&sessionLogin{Engine: "mysql", Database: "crudder_db_test", Username: "u", Password: "changed"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package crudder
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionCookieName is the name of the session cookie, after the COOKIE_PREFIX
+const sessionCookieName = "session_token"
+
+// CookieConfig holds the attributes of the session cookie. The zero value is usable:
+// HttpOnly, Path=/, SameSite=Lax and Secure on HTTPS requests.
+type CookieConfig struct {
+	Prefix   string        // COOKIE_PREFIX: prepended to the cookie name, e.g. "__Host-"
+	Path     string        // COOKIE_PATH, default "/"
+	Domain   string        // COOKIE_DOMAIN, default none (host-only cookie)
+	Secure   string        // COOKIE_SECURE: true, false or auto (default), which sets Secure on HTTPS requests
+	SameSite http.SameSite // COOKIE_SAMESITE: lax (default), strict or none
+}
+
+var sameSiteModes = map[string]http.SameSite{"": http.SameSiteLaxMode, "lax": http.SameSiteLaxMode, "strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode}
+
+// LoadCookieConfig reads the session cookie attributes from the environment
+func LoadCookieConfig() (*CookieConfig, error) {
+	c := &CookieConfig{
+		Prefix: os.Getenv("COOKIE_PREFIX"),
+		Path:   os.Getenv("COOKIE_PATH"),
+		Domain: os.Getenv("COOKIE_DOMAIN"),
+		Secure: strings.ToLower(os.Getenv("COOKIE_SECURE")),
+	}
+
+	sameSite, ok := sameSiteModes[strings.ToLower(os.Getenv("COOKIE_SAMESITE"))]
+	if !ok {
+		return nil, fmt.Errorf("invalid COOKIE_SAMESITE %q", os.Getenv("COOKIE_SAMESITE"))
+	}
+	c.SameSite = sameSite
+
+	if c.Secure != "" && c.Secure != "auto" {
+		if _, err := strconv.ParseBool(c.Secure); err != nil {
+			return nil, fmt.Errorf("invalid COOKIE_SECURE %q", c.Secure)
+		}
+	}
+	if err := (&http.Cookie{Name: c.name(), Value: "x", Path: c.path(), Domain: c.Domain}).Valid(); err != nil {
+		return nil, fmt.Errorf("invalid session cookie settings: %v", err)
+	}
+
+	// Browsers drop cookies that break the rules of their prefix or of SameSite=None
+	secure, _ := strconv.ParseBool(c.Secure)
+	switch {
+	case strings.HasPrefix(c.Prefix, "__Host-") && (!secure || c.path() != "/" || c.Domain != ""):
+		return nil, fmt.Errorf("COOKIE_PREFIX __Host- needs COOKIE_SECURE=true, COOKIE_PATH=/ and no COOKIE_DOMAIN")
+	case strings.HasPrefix(c.Prefix, "__Secure-") && !secure:
+		return nil, fmt.Errorf("COOKIE_PREFIX __Secure- needs COOKIE_SECURE=true")
+	case c.SameSite == http.SameSiteNoneMode && !secure:
+		return nil, fmt.Errorf("COOKIE_SAMESITE=none needs COOKIE_SECURE=true")
+	}
+	return c, nil
+}
+
+func (c *CookieConfig) name() string {
+	return c.Prefix + sessionCookieName
+}
+
+func (c *CookieConfig) path() string {
+	if c.Path == "" {
+		return "/"
+	}
+	return c.Path
+}
+
+// secure tells whether the cookie sent in reply to r gets the Secure attribute
+func (c *CookieConfig) secure(r *http.Request) bool {
+	if secure, err := strconv.ParseBool(c.Secure); err == nil {
+		return secure
+	}
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// sessionCookie returns the cookie carrying token, expiring at expires or, when it is
+// zero, with the browser session
+func (c *CookieConfig) sessionCookie(r *http.Request, token string, expires time.Time) *http.Cookie {
+	sameSite := c.SameSite
+	if sameSite == http.SameSiteDefaultMode {
+		sameSite = http.SameSiteLaxMode
+	}
+	return &http.Cookie{
+		Name:     c.name(),
+		Value:    token,
+		Path:     c.path(),
+		Domain:   c.Domain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.secure(r),
+		SameSite: sameSite,
+	}
+}
+
+// cookieConfig returns the cookie settings loaded at startup, reading them from the
+// environment when the app was built without them
+func (app *App) cookieConfig() *CookieConfig {
+	if app.Cookie != nil {
+		return app.Cookie
+	}
+	c, err := LoadCookieConfig()
+	if err != nil {
+		log.Println("invalid session cookie settings:", err)
+		return &CookieConfig{}
+	}
+	return c
+}
+
+// newSessionToken returns 32 random bytes, base64url encoded
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSessionToken returns the SessionStore key of a token, so a leaked store (or a
+// log line) does not give away usable tokens
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionID returns the SessionStore key of the session token of r
+func (app *App) sessionID(r *http.Request) (string, bool) {
+	token, _ := app.requestToken(r)
+	if token == "" {
+		return "", false
+	}
+	return hashSessionToken(token), true
+}
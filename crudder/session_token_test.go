@@ -0,0 +1,149 @@
+package crudder
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionToken(t *testing.T) {
+	first, err := newSessionToken()
+	require.NoError(t, err)
+	second, err := newSessionToken()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 43, "32 bytes, base64url without padding")
+	assert.NotEqual(t, first, second)
+	assert.Len(t, hashSessionToken(first), 64)
+	assert.Equal(t, hashSessionToken(first), hashSessionToken(first))
+	assert.NotEqual(t, first, hashSessionToken(first))
+}
+
+func TestLoadCookieConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		c, err := LoadCookieConfig()
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", nil)
+		cookie := c.sessionCookie(req, "token", time.Time{})
+		assert.Equal(t, "session_token", cookie.Name)
+		assert.Equal(t, "/", cookie.Path)
+		assert.True(t, cookie.HttpOnly)
+		assert.False(t, cookie.Secure, "plain HTTP request")
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+		req.TLS = &tls.ConnectionState{}
+		assert.True(t, c.sessionCookie(req, "token", time.Time{}).Secure, "HTTPS request")
+
+		proxied := httptest.NewRequest(http.MethodPost, "/api/v1/login", nil)
+		proxied.Header.Set("X-Forwarded-Proto", "https")
+		assert.True(t, c.sessionCookie(proxied, "token", time.Time{}).Secure, "behind a TLS terminating proxy")
+	})
+
+	t.Run("All settings", func(t *testing.T) {
+		t.Setenv("COOKIE_PREFIX", "__Host-")
+		t.Setenv("COOKIE_SECURE", "true")
+		t.Setenv("COOKIE_SAMESITE", "Strict")
+
+		c, err := LoadCookieConfig()
+		require.NoError(t, err)
+		cookie := c.sessionCookie(httptest.NewRequest(http.MethodPost, "/api/v1/login", nil), "token", time.Time{})
+		assert.Equal(t, "__Host-session_token", cookie.Name)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		for name, env := range map[string]map[string]string{
+			"Invalid SameSite":          {"COOKIE_SAMESITE": "always"},
+			"Invalid Secure":            {"COOKIE_SECURE": "maybe"},
+			"Invalid prefix":            {"COOKIE_PREFIX": "my cookie"},
+			"__Host- without Secure":    {"COOKIE_PREFIX": "__Host-"},
+			"__Host- with a domain":     {"COOKIE_PREFIX": "__Host-", "COOKIE_SECURE": "true", "COOKIE_DOMAIN": "example.com"},
+			"__Secure- without Secure":  {"COOKIE_PREFIX": "__Secure-", "COOKIE_SECURE": "auto"},
+			"SameSite=None without TLS": {"COOKIE_SAMESITE": "none"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				for key, value := range env {
+					t.Setenv(key, value)
+				}
+				_, err := LoadCookieConfig()
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestLoginSessionCookie(t *testing.T) {
+	originalSqlOpen := sqlOpen
+	t.Cleanup(func() { sqlOpen = originalSqlOpen })
+	sqlOpen = func(driverName, dataSourceName string) (*sql.DB, error) {
+		db, mock, err := sqlmock.New()
+		mock.ExpectClose()
+		return db, err
+	}
+
+	app := &App{SessionStore: NewMemoryStore(), Cookie: &CookieConfig{Prefix: "__Secure-", Secure: "true", SameSite: http.SameSiteStrictMode}}
+	handler := SetupRouter(app)
+
+	form := url.Values{"username": {"u"}, "password": {"p"}, "dbname": {"crudder_db_test"}}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	cookie := cookies[0]
+	assert.Equal(t, "__Secure-session_token", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, "/", cookie.Path)
+
+	// only the hash of the token is stored
+	require.Equal(t, 1, app.SessionStore.Len())
+	_, stored := app.SessionStore.Get(cookie.Value)
+	assert.False(t, stored)
+	_, stored = app.SessionStore.Get(hashSessionToken(cookie.Value))
+	assert.True(t, stored)
+
+	// the old cookie name is not accepted
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: cookie.Value})
+	req.Header.Set(csrfHeader, csrfToken(cookie.Value))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// logout changes state, so it needs the CSRF token like the other POSTs
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 1, app.SessionStore.Len())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	req.Header.Set(csrfHeader, csrfToken(cookie.Value))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Zero(t, app.SessionStore.Len())
+	expired := w.Result().Cookies()
+	require.Len(t, expired, 1)
+	assert.Equal(t, "__Secure-session_token", expired[0].Name)
+	assert.Empty(t, expired[0].Value)
+	assert.True(t, expired[0].Secure)
+}
@@ -0,0 +1,168 @@
+package crudder
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sqliteTestSchema = `
+CREATE TABLE roles (
+    role_id INTEGER PRIMARY KEY,
+    name VARCHAR(30) NOT NULL UNIQUE
+);
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT 1,
+    status TEXT DEFAULT 'new',
+    role_id INTEGER REFERENCES roles,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    name_length INTEGER GENERATED ALWAYS AS (length(username))
+);
+CREATE VIEW active_users AS SELECT id, username FROM users WHERE active = 1;
+INSERT INTO roles (name) VALUES ('admin');
+`
+
+// newSQLiteTestApp returns an app whose "mockSession" is logged in to a new SQLite
+// file with the roles and users tables
+func newSQLiteTestApp(t *testing.T) (*App, *sql.DB) {
+	t.Helper()
+
+	d := sqliteDialect{}
+	db, err := sql.Open(d.DriverName(), d.DSN(&ConnConfig{SQLitePath: filepath.Join(t.TempDir(), "crudder.db")}, "", "", ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(sqliteTestSchema)
+	require.NoError(t, err)
+
+	return &App{SessionStore: newTestStore(map[string]*SessionData{hashSessionToken("mockSession"): {DB: db, dialect: d}})}, db
+}
+
+func serveSQLite(app *App, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+	req.Header.Set(csrfHeader, csrfToken("mockSession"))
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, req)
+	return w
+}
+
+func TestSQLiteIntrospection(t *testing.T) {
+	app, _ := newSQLiteTestApp(t)
+
+	t.Run("Tables", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodGet, "/api/v1/tables", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[
+			{"table_name":"active_users","table_type":"VIEW","table_comment":""},
+			{"table_name":"roles","table_type":"BASE TABLE","table_comment":""},
+			{"table_name":"users","table_type":"BASE TABLE","table_comment":""}
+		]`, w.Body.String())
+	})
+
+	t.Run("Columns", func(t *testing.T) {
+		columns, err := fetchTableColumns(storedSession(app, "mockSession").DB, sqliteDialect{}, "users")
+		require.NoError(t, err)
+		require.Len(t, columns, 7)
+		byName := map[string]ColumnInfo{}
+		for _, col := range columns {
+			byName[col.ColumnName] = col
+		}
+
+		id := byName["id"]
+		assert.Equal(t, "bigint", id.DataType)
+		assert.True(t, id.IsPrimaryKey)
+		assert.True(t, id.IsAutoIncrement)
+		assert.False(t, id.IsNullable)
+
+		username := byName["username"]
+		assert.Equal(t, "varchar", username.DataType)
+		assert.Equal(t, int64Ptr(50), username.CharacterMaxLength)
+		assert.False(t, username.IsNullable)
+
+		assert.Equal(t, "boolean", byName["active"].DataType)
+		assert.Equal(t, strPtr("new"), byName["status"].ColumnDefault)
+		assert.True(t, byName["status"].IsNullable)
+		assert.Equal(t, strPtr("CURRENT_TIMESTAMP"), byName["created_at"].ColumnDefault)
+
+		role := byName["role_id"]
+		assert.Equal(t, strPtr("roles"), role.ReferencedTable)
+		assert.Equal(t, strPtr("role_id"), role.ReferencedColumn)
+
+		assert.NotNil(t, byName["name_length"].GenerationExpression)
+	})
+
+	t.Run("JSON Schema", func(t *testing.T) {
+		w := serveSQLite(app, http.MethodGet, "/api/v1/schema/users.json", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"required":["username"]`)
+	})
+}
+
+func TestSQLiteCrud(t *testing.T) {
+	app, db := newSQLiteTestApp(t)
+
+	w := serveSQLite(app, http.MethodPost, "/api/v1/crud/users", `{"username":"ana","role_id":1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"id":1,"username":"ana","role_id":1}`, w.Body.String())
+
+	w = serveSQLite(app, http.MethodPut, "/api/v1/crud/users/1", `{"status":"active"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users?upsert=true", `{"id":1,"username":"ana.lima"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveSQLite(app, http.MethodGet, "/api/v1/crud/users/1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"username":"ana.lima"`)
+	assert.Contains(t, w.Body.String(), `"status":"active"`)
+
+	// foreign keys are enforced
+	w = serveSQLite(app, http.MethodPost, "/api/v1/crud/users", `{"username":"bob","role_id":9}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = serveSQLite(app, http.MethodDelete, "/api/v1/crud/users/1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM users").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestSQLiteLogin(t *testing.T) {
+	login := func(app *App) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(url.Values{"engine": {"sqlite"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		app.loginHandler(w, req)
+		return w
+	}
+
+	t.Run("Opens the configured file", func(t *testing.T) {
+		t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "demo.db"))
+		app := &App{SessionStore: NewMemoryStore()}
+
+		w := login(app)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		session := loginSession(t, app, w)
+		assert.Equal(t, "sqlite", session.Dialect().Name())
+		session.close()
+	})
+
+	t.Run("Not configured", func(t *testing.T) {
+		t.Setenv("SQLITE_PATH", "")
+		app := &App{SessionStore: NewMemoryStore()}
+
+		w := login(app)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"SQLite database not configured"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,229 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiKeyPrefix     = "crd_" // makes leaked keys easy to spot for secret scanners
+	defaultAPIKeyTTL = 30 * 24 * time.Hour
+	maxAPIKeyTTL     = 365 * 24 * time.Hour
+	apiKeyIDLength   = 16 // hex digits of the key hash shown as its id
+)
+
+// apiKey marks a session opened for a machine client. It is used with an
+// Authorization: Bearer header, expires at a fixed time instead of after
+// SESSION_IDLE_TIMEOUT and is listed and revoked through /tokens.
+type apiKey struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// struct represents an API key as listed by /tokens; the token itself is only
+// returned when the key is issued
+type APIKeyInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// struct represents the body of POST /tokens
+type APIKeyRequest struct {
+	Name      string `json:"name"`
+	ExpiresIn string `json:"expires_in"` // Go duration, e.g. "720h"; 30 days when empty
+}
+
+// owner identifies the database user of a login, whose API keys /tokens shows
+func (l *sessionLogin) owner() string {
+	return l.Engine + "\x00" + l.Profile + "\x00" + l.Username
+}
+
+// parseAPIKeyTTL reads the lifetime of a new key
+func parseAPIKeyTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultAPIKeyTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 || ttl > maxAPIKeyTTL {
+		return 0, fmt.Errorf("expires_in must be a duration between 1s and %s", maxAPIKeyTTL)
+	}
+	return ttl, nil
+}
+
+// requestToken returns the session token of r, from the Authorization: Bearer header
+// of machine clients or from the session cookie of browsers
+func (app *App) requestToken(r *http.Request) (token string, bearer bool) {
+	if value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(value), true
+	}
+	cookie, err := r.Cookie(app.cookieConfig().name())
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, false
+}
+
+// issueAPIKey stores session as a new API key and returns the key with its token
+func (app *App) issueAPIKey(session *SessionData, name string, ttl time.Duration) (*APIKeyInfo, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	token = apiKeyPrefix + token
+	id := hashSessionToken(token)
+
+	now := time.Now()
+	session.created = now
+	session.touch(now)
+	session.apiKey = &apiKey{Name: name, ExpiresAt: now.Add(ttl)}
+	if err := app.SessionStore.Save(id, session); err != nil {
+		return nil, err
+	}
+
+	info := apiKeyInfo(id, session)
+	info.Token = token
+	return &info, nil
+}
+
+func apiKeyInfo(id string, session *SessionData) APIKeyInfo {
+	return APIKeyInfo{
+		ID:        id[:apiKeyIDLength],
+		Name:      session.apiKey.Name,
+		CreatedAt: session.created,
+		ExpiresAt: session.apiKey.ExpiresAt,
+		LastUsed:  session.seen(),
+	}
+}
+
+// ownAPIKeys returns the store ids of the API keys of the owner of session, with the
+// keys
+func (app *App) ownAPIKeys(session *SessionData) map[string]*SessionData {
+	keys := make(map[string]*SessionData)
+	owner := session.login.owner()
+	app.SessionStore.Range(func(id string, s *SessionData) {
+		if s.apiKey != nil && s.login != nil && s.login.owner() == owner {
+			keys[id] = s
+		}
+	})
+	return keys
+}
+
+// @Summary List API Keys
+// @Description Lists the API keys of the logged in database user, with their expiry and last use. The tokens themselves are not shown.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {array} APIKeyInfo "API keys of the user"
+// @Failure 400 {object} map[string]string "Session cannot issue API keys"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /tokens [get]
+func (app *App) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	if session == nil || session.login == nil {
+		WriteErrorResponse(w, http.StatusBadRequest, errNoAPIKeys)
+		return
+	}
+
+	keys := []APIKeyInfo{}
+	for id, key := range app.ownAPIKeys(session) {
+		keys = append(keys, apiKeyInfo(id, key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	writeJSONResponseWithStatus(w, http.StatusOK, keys)
+}
+
+// @Summary Issue API Key
+// @Description Issues an API key with the credentials of the current session, for scripts and CI jobs. Send it as "Authorization: Bearer <token>"; it is shown only in this response. Keys issued with an API key expire no later than it.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param key body APIKeyRequest false "Name and lifetime of the key"
+// @Success 201 {object} APIKeyInfo "The new key with its token"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Error connecting to database"
+// @Router /tokens [post]
+func (app *App) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	if session == nil || session.login == nil {
+		WriteErrorResponse(w, http.StatusBadRequest, errNoAPIKeys)
+		return
+	}
+
+	var req APIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, errInvalidInput)
+			return
+		}
+	}
+	ttl, err := parseAPIKeyTTL(req.ExpiresIn)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// A key issued with another key expires with it, so a leaked key cannot renew itself
+	if session.apiKey != nil {
+		ttl = min(ttl, time.Until(session.apiKey.ExpiresAt))
+	}
+
+	// The key gets its own connection, so it outlives the session that issued it
+	login := *session.login
+	keySession, err := app.reopenSession(&login)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, errConnDB)
+		log.Println("error opening API key connection:", err)
+		return
+	}
+	info, err := app.issueAPIKey(keySession, req.Name, ttl)
+	if err != nil {
+		keySession.close()
+		WriteErrorResponse(w, http.StatusInternalServerError, "Error issuing API key")
+		log.Println("error issuing API key:", err)
+		return
+	}
+
+	writeJSONResponseWithStatus(w, http.StatusCreated, info)
+}
+
+// @Summary Revoke API Key
+// @Description Revokes one of the API keys of the logged in database user and closes its connection.
+// @Tags Authentication
+// @Produce json
+// @Param id path string true "Key id from GET /tokens"
+// @Success 200 {object} map[string]string "API key revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Router /tokens/{id} [delete]
+func (app *App) revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	if session == nil || session.login == nil {
+		WriteErrorResponse(w, http.StatusBadRequest, errNoAPIKeys)
+		return
+	}
+
+	keyID := mux.Vars(r)["id"]
+	for id := range app.ownAPIKeys(session) {
+		if id[:apiKeyIDLength] != keyID {
+			continue
+		}
+		if key := app.SessionStore.Delete(id); key != nil {
+			key.close()
+		}
+		writeJSONResponseWithStatus(w, http.StatusOK, map[string]string{errMessage: "API key revoked", "id": keyID})
+		return
+	}
+
+	WriteErrorResponse(w, http.StatusNotFound, errKeyNotFound)
+}
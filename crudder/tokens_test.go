@@ -0,0 +1,143 @@
+package crudder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestToken(t *testing.T) {
+	app := &App{}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
+	req.Header.Set("Authorization", "Bearer crd_abc")
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "cookie"})
+	token, bearer := app.requestToken(req)
+	assert.Equal(t, "crd_abc", token)
+	assert.True(t, bearer)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "cookie"})
+	token, bearer = app.requestToken(req)
+	assert.Equal(t, "cookie", token)
+	assert.False(t, bearer)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil)
+	req.Header.Set("Authorization", "Basic dTpw")
+	token, _ = app.requestToken(req)
+	assert.Empty(t, token)
+}
+
+// serveBearer runs a request authenticated with an API key
+func serveBearer(app *App, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "demo.db"))
+	app := &App{SessionStore: NewMemoryStore(), SessionIdleTimeout: time.Minute}
+	t.Cleanup(app.SessionStore.Close)
+
+	// api_key=true at login returns a key instead of the cookie
+	form := url.Values{"engine": {"sqlite"}, "api_key": {"true"}, "key_name": {"ci"}, "expires_in": {"24h"}}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	SetupRouter(app).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, w.Result().Cookies())
+
+	var ci APIKeyInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ci))
+	assert.True(t, strings.HasPrefix(ci.Token, "crd_"))
+	assert.Equal(t, "ci", ci.Name)
+	assert.Len(t, ci.ID, 16)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), ci.ExpiresAt, time.Minute)
+
+	w = serveBearer(app, http.MethodGet, "/api/v1/tables", ci.Token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, w.Result().Cookies(), "bearer requests get no cookie")
+
+	t.Run("Not subject to the idle timeout", func(t *testing.T) {
+		key := storedSession(app, ci.Token)
+		key.lastSeen.Store(time.Now().Add(-time.Hour).UnixNano())
+		w := serveBearer(app, http.MethodGet, "/api/v1/tables", ci.Token, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// a second key, issued from the first one
+	w = serveBearer(app, http.MethodPost, "/api/v1/tokens", ci.Token, `{"name":"deploy"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var deploy APIKeyInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deploy))
+	assert.NotEqual(t, ci.Token, deploy.Token)
+	assert.WithinDuration(t, ci.ExpiresAt, deploy.ExpiresAt, time.Second, "keys issued with a key expire with it")
+
+	w = serveBearer(app, http.MethodGet, "/api/v1/tokens", deploy.Token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var keys []APIKeyInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	require.Len(t, keys, 2)
+	assert.Equal(t, []string{"ci", "deploy"}, []string{keys[0].Name, keys[1].Name})
+	assert.Empty(t, keys[0].Token, "tokens are not listed")
+
+	w = serveBearer(app, http.MethodDelete, "/api/v1/tokens/"+ci.ID, deploy.Token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serveBearer(app, http.MethodGet, "/api/v1/tables", ci.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "revoked keys are refused")
+
+	w = serveBearer(app, http.MethodDelete, "/api/v1/tokens/"+ci.ID, deploy.Token, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	t.Run("Expired key", func(t *testing.T) {
+		storedSession(app, deploy.Token).apiKey.ExpiresAt = time.Now().Add(-time.Second)
+		w := serveBearer(app, http.MethodGet, "/api/v1/tables", deploy.Token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"Session expired"}`, w.Body.String())
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("Invalid lifetime", func(t *testing.T) {
+		form := url.Values{"engine": {"sqlite"}, "api_key": {"true"}, "expires_in": {"9999h"}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		app.loginHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAPIKeysOfOtherUsers(t *testing.T) {
+	app := &App{SessionStore: NewMemoryStore()}
+	ana := &SessionData{login: &sessionLogin{Engine: "mysql", Username: "ana"}}
+	bob := &SessionData{login: &sessionLogin{Engine: "mysql", Username: "bob"}, apiKey: &apiKey{Name: "bob's", ExpiresAt: time.Now().Add(time.Hour)}}
+	require.NoError(t, app.SessionStore.Save(hashSessionToken("ana"), ana))
+	bobID := hashSessionToken("bob")
+	require.NoError(t, app.SessionStore.Save(bobID, bob))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil)
+	req.Header.Set("Authorization", "Bearer ana")
+	w := httptest.NewRecorder()
+	app.listTokensHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	req = newDDLRequest(http.MethodDelete, "/api/v1/tokens/"+bobID[:16], "", map[string]string{"id": bobID[:16]})
+	req.Header.Set("Authorization", "Bearer ana")
+	w = httptest.NewRecorder()
+	app.revokeTokenHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 2, app.SessionStore.Len())
+}
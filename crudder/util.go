@@ -3,6 +3,7 @@ package crudder
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -29,6 +30,25 @@ const (
 	errLogoutOK       = "Logout successful"
 	errUnauthorized   = "Unauthorized"
 	errNoSessionFound = "No session found"
+	errViewNotFound   = "View not found"
+	errMissingParam   = "Missing parameter '%s'"
+	errProcNotFound   = "Procedure not found"
+	errSchemaNotFound = "Schema not found"
+	errTableNotFound  = "Table not found"
+	errIndexNotFound  = "Index not found"
+	errDBNotFound     = "Database not found or not accessible"
+	errNotSupported   = "Not supported for %s databases"
+	errDBNotAllowed   = "Database not allowed on this server"
+	errSessionExpired = "Session expired"
+	errNoAPIKeys      = "Session cannot issue API keys"
+	errKeyNotFound    = "API key not found"
+	errInvalidCSRF    = "Invalid or missing CSRF token"
+	errTooManyLogins  = "Too many failed logins, try again later"
+	errOpNotAllowed   = "Operation '%s' not allowed on this table"
+	errColumnDenied   = "Columns not allowed: %s"
+	errSessionValue   = "Row filter needs session value '%s'"
+	errRowDenied      = "Row not allowed by the row filter of this table"
+	errMigrateDenied  = "Migrations need the 'alter' operation on every table"
 )
 
 // Function to validate if the table name is alphanumeric
@@ -44,6 +64,17 @@ func isAlphaNumeric(str string) bool {
 	return true
 }
 
+// quoteIdentifier quotes a table, column or routine name for use in a MySQL statement
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteLiteral quotes a string as a MySQL string literal
+func quoteLiteral(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
 // Helper function to write error responses
 func WriteErrorResponse(w http.ResponseWriter, status int, message string) {
 	response := map[string]string{errMessage: message}
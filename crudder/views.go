@@ -0,0 +1,216 @@
+package crudder
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// SavedQuery is a parameterized SELECT registered by the operator and exposed as a read-only view
+type SavedQuery struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	SQL         string   `json:"sql"`
+	Params      []string `json:"params"`
+
+	query string // SQL with the named parameters replaced by '?'
+	args  []string
+}
+
+// LoadSavedQueries reads the saved queries config file. The file is a JSON object
+// mapping each view name to its definition, e.g.:
+//
+//	{"active_users_by_role": {"description": "...", "sql": "SELECT ... WHERE r.role = :role"}}
+func LoadSavedQueries(path string) (map[string]*SavedQuery, error) {
+	queries := make(map[string]*SavedQuery)
+	if path == "" {
+		return queries, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs map[string]*SavedQuery
+	if err := json.Unmarshal(content, &defs); err != nil {
+		return nil, fmt.Errorf("invalid saved queries file %s: %v", path, err)
+	}
+
+	for name, q := range defs {
+		if !isAlphaNumeric(name) {
+			return nil, fmt.Errorf("invalid view name %q", name)
+		}
+		if q == nil || !isReadOnlyStatement(q.SQL) {
+			return nil, fmt.Errorf("view %q must be a SELECT statement", name)
+		}
+		q.Name = name
+		q.query, q.args = compileNamedQuery(q.SQL)
+		q.Params = uniqueStrings(q.args)
+		queries[name] = q
+	}
+	return queries, nil
+}
+
+// isReadOnlyStatement reports whether the statement is a single SELECT (or WITH ... SELECT)
+func isReadOnlyStatement(query string) bool {
+	trimmed := strings.TrimSpace(query)
+	trimmed = strings.TrimSuffix(trimmed, ";")
+	if strings.Contains(trimmed, ";") {
+		return false
+	}
+	upper := strings.ToUpper(trimmed)
+	return strings.HasPrefix(upper, "SELECT") || strings.HasPrefix(upper, "WITH")
+}
+
+// compileNamedQuery replaces ':name' parameters with '?' placeholders and returns
+// the parameter names in the order they must be bound. Quoted strings and
+// identifiers, '::' casts and ':=' assignments are left untouched.
+func compileNamedQuery(query string) (string, []string) {
+	return bindNamedQuery(query, mysqlDialect{})
+}
+
+// bindNamedQuery is compileNamedQuery with the placeholders of the given dialect
+func bindNamedQuery(query string, d Dialect) (string, []string) {
+	var out strings.Builder
+	var names []string
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				if query[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(query) {
+				end = len(query) - 1
+			}
+			out.WriteString(query[i : end+1])
+			i = end
+		case c == ':' && i+1 < len(query) && (query[i+1] == ':' || query[i+1] == '='):
+			out.WriteString(query[i : i+2])
+			i++
+		case c == ':' && i+1 < len(query) && isParamStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && isParamChar(query[end]) {
+				end++
+			}
+			names = append(names, query[i+1:end])
+			out.WriteString(d.Placeholder(len(names)))
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), names
+}
+
+func isParamStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+func isParamChar(c byte) bool {
+	return isParamStart(c) || (c >= '0' && c <= '9')
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// @Summary List Views
+// @Description Lists the saved queries exposed as read-only views, with the parameters each one expects.
+// @Tags Views
+// @Produce json
+// @Success 200 {array} SavedQuery "List of views"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /views [get]
+func (app *App) listViewsHandler(w http.ResponseWriter, r *http.Request) {
+	session := app.getSession(r)
+	views := make([]*SavedQuery, 0, len(app.SavedQueries))
+	for _, q := range app.SavedQueries {
+		if app.allowsView(session, q.Name) {
+			views = append(views, q)
+		}
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+
+	writeJSONResponseWithStatus(w, http.StatusOK, views)
+}
+
+// @Summary Read View
+// @Description Runs a saved query in a read-only transaction, binding its named parameters from the query string.
+// @Tags Views
+// @Produce json
+// @Param name path string true "Name of the view" default(active_users_by_role)
+// @Success 200 {array} map[string]interface{} "List of all records"
+// @Failure 400 {object} map[string]string "Missing view parameter"
+// @Failure 401 {object} map[string]string "Unauthorized or session not found"
+// @Failure 404 {object} map[string]string "View or records not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /views/{name} [get]
+func (app *App) viewHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	view, exists := app.SavedQueries[name]
+	// views not granted by POLICY_FILE are reported as not found
+	if !exists || !app.allowsView(app.getSession(r), name) {
+		WriteErrorResponse(w, http.StatusNotFound, errViewNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	args := make([]interface{}, 0, len(view.args))
+	for _, param := range view.args {
+		if _, ok := params[param]; !ok {
+			WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf(errMissingParam, param))
+			return
+		}
+		args = append(args, params.Get(param))
+	}
+
+	db := app.getDBFromSession(r)
+	if db == nil {
+		WriteErrorResponse(w, http.StatusUnauthorized, errSessionNotFound)
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		return
+	}
+	defer tx.Rollback()
+
+	query := view.query
+	if d := app.getDialect(r); d.Name() != "mysql" {
+		query, _ = bindNamedQuery(view.SQL, d)
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf(errQryDatabase, err))
+		log.Println("error running view", name, err)
+		return
+	}
+	defer rows.Close()
+
+	writeRecords(w, rows, nil)
+}
@@ -0,0 +1,223 @@
+package crudder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileNamedQuery(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedQuery string
+		expectedArgs  []string
+	}{
+		{"SELECT * FROM users WHERE role = :role", "SELECT * FROM users WHERE role = ?", []string{"role"}},
+		{"SELECT * FROM t WHERE a = :a AND b = :b OR a2 = :a", "SELECT * FROM t WHERE a = ? AND b = ? OR a2 = ?", []string{"a", "b", "a"}},
+		{"SELECT ':notparam' FROM t WHERE x = :x", "SELECT ':notparam' FROM t WHERE x = ?", []string{"x"}},
+		{"SELECT `a:b` FROM t", "SELECT `a:b` FROM t", nil},
+		{"SELECT @v := 1, x::int FROM t", "SELECT @v := 1, x::int FROM t", nil},
+		{"SELECT 'it\\'s :x' FROM t WHERE y = :y_1", "SELECT 'it\\'s :x' FROM t WHERE y = ?", []string{"y_1"}},
+	}
+
+	for _, tt := range tests {
+		query, args := compileNamedQuery(tt.input)
+		assert.Equal(t, tt.expectedQuery, query, tt.input)
+		assert.Equal(t, tt.expectedArgs, args, tt.input)
+	}
+}
+
+func TestLoadSavedQueries(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Empty path", func(t *testing.T) {
+		queries, err := LoadSavedQueries("")
+		require.NoError(t, err)
+		assert.Empty(t, queries)
+	})
+
+	t.Run("Valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "views.json")
+		writeFile(t, path, `{"active_users_by_role": {"description": "Users by role", "sql": "SELECT u.* FROM users u JOIN roles r ON r.role_id = u.role_id WHERE r.role = :role OR r.role = :role"}}`)
+
+		queries, err := LoadSavedQueries(path)
+		require.NoError(t, err)
+		require.Contains(t, queries, "active_users_by_role")
+
+		q := queries["active_users_by_role"]
+		assert.Equal(t, "active_users_by_role", q.Name)
+		assert.Equal(t, []string{"role"}, q.Params)
+		assert.Equal(t, []string{"role", "role"}, q.args)
+	})
+
+	t.Run("Rejects write statements", func(t *testing.T) {
+		path := filepath.Join(dir, "write.json")
+		writeFile(t, path, `{"purge": {"sql": "DELETE FROM users"}}`)
+
+		_, err := LoadSavedQueries(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects multiple statements", func(t *testing.T) {
+		path := filepath.Join(dir, "multi.json")
+		writeFile(t, path, `{"sneaky": {"sql": "SELECT 1; DROP TABLE users"}}`)
+
+		_, err := LoadSavedQueries(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects invalid names", func(t *testing.T) {
+		path := filepath.Join(dir, "name.json")
+		writeFile(t, path, `{"bad name": {"sql": "SELECT 1"}}`)
+
+		_, err := LoadSavedQueries(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := LoadSavedQueries(filepath.Join(dir, "missing.json"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.json")
+		writeFile(t, path, `{`)
+
+		_, err := LoadSavedQueries(path)
+		assert.Error(t, err)
+	})
+}
+
+func newViewTestApp(t *testing.T) (*App, sqlmock.Sqlmock) {
+	t.Helper()
+
+	app, mock := newMockApp(t)
+	query, args := compileNamedQuery("SELECT username FROM users WHERE role = :role")
+	app.SavedQueries = map[string]*SavedQuery{
+		"users_by_role": {Name: "users_by_role", Params: []string{"role"}, query: query, args: args},
+	}
+	return app, mock
+}
+
+func TestViewHandler(t *testing.T) {
+	newRequest := func(name, rawQuery string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/views/"+name+"?"+rawQuery, nil)
+		req = mux.SetURLVars(req, map[string]string{"name": name})
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: "mockSession"})
+		return req
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		app, mock := newViewTestApp(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`^SELECT username FROM users WHERE role = \?$`).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow([]byte("user1")).AddRow("user2"))
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("users_by_role", "role=admin"))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var out []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		assert.Equal(t, []map[string]interface{}{{"username": "user1"}, {"username": "user2"}}, out)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No records", func(t *testing.T) {
+		app, mock := newViewTestApp(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`^SELECT username FROM users WHERE role = \?$`).
+			WithArgs("nobody").
+			WillReturnRows(sqlmock.NewRows([]string{"username"}))
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("users_by_role", "role=nobody"))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown view", func(t *testing.T) {
+		app, _ := newViewTestApp(t)
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("unknown", ""))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"message":"View not found"}`, w.Body.String())
+	})
+
+	t.Run("Missing parameter", func(t *testing.T) {
+		app, _ := newViewTestApp(t)
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("users_by_role", ""))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"Missing parameter 'role'"}`, w.Body.String())
+	})
+
+	t.Run("Session not found", func(t *testing.T) {
+		app, _ := newViewTestApp(t)
+		req := httptest.NewRequest(http.MethodGet, "/views/users_by_role?role=admin", nil)
+		req = mux.SetURLVars(req, map[string]string{"name": "users_by_role"})
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Query error", func(t *testing.T) {
+		app, mock := newViewTestApp(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`^SELECT username FROM users WHERE role = \?$`).
+			WithArgs("admin").
+			WillReturnError(fmt.Errorf("mock error"))
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("users_by_role", "role=admin"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Begin error", func(t *testing.T) {
+		app, mock := newViewTestApp(t)
+		mock.ExpectBegin().WillReturnError(fmt.Errorf("mock error"))
+
+		w := httptest.NewRecorder()
+		app.viewHandler(w, newRequest("users_by_role", "role=admin"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListViewsHandler(t *testing.T) {
+	app := &App{SavedQueries: map[string]*SavedQuery{
+		"b_view": {Name: "b_view", Params: []string{}},
+		"a_view": {Name: "a_view", Description: "first", Params: []string{"role"}},
+	}}
+
+	w := httptest.NewRecorder()
+	app.listViewsHandler(w, httptest.NewRequest(http.MethodGet, "/views", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Index(w.Body.String(), "a_view") < strings.Index(w.Body.String(), "b_view"))
+	assert.NotContains(t, w.Body.String(), "query")
+}
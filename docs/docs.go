@@ -15,6 +15,83 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/call/{procedure}": {
+            "post": {
+                "description": "Runs CALL on a stored procedure of the current schema, binding the named IN/INOUT parameters from the JSON body. Returns every result set produced plus the values of the OUT/INOUT parameters.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "Call Stored Procedure",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of the procedure",
+                        "name": "procedure",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "JSON object with the procedure parameters by name",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Result sets and OUT parameters",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.CallResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or missing parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Procedure not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/crud/{tableName}": {
             "get": {
                 "description": "Retrieves all records from the specified table. This endpoint requires a valid session token.",
@@ -108,9 +185,9 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid input or JSON decoding error",
+                        "description": "Invalid input, JSON decoding error or body not matching the table schema",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/crudder.ValidationErrorResponse"
                         }
                     },
                     "401": {
@@ -119,6 +196,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "404": {
+                        "description": "Table not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -250,9 +333,9 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid input or JSON decoding error",
+                        "description": "Invalid input, JSON decoding error or body not matching the table schema",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/crudder.ValidationErrorResponse"
                         }
                     },
                     "401": {
@@ -262,7 +345,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Record not found",
+                        "description": "Table or record not found",
                         "schema": {
                             "type": "string"
                         }
@@ -348,6 +431,107 @@ const docTemplate = `{
                 }
             }
         },
+        "/databases": {
+            "get": {
+                "description": "Lists the schemas the logged in MySQL user can see, limited to the databases of the server profile of the login, marking the one the session is using. Any of them can be browsed through the /databases/{schema}/... routes or made the active one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "List Databases",
+                "responses": {
+                    "200": {
+                        "description": "List of databases",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.DatabaseInfo"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/databases/{schema}/use": {
+            "post": {
+                "description": "Makes another schema the active database of the session, so the routes without a {schema} segment work on it. The connection is opened with the login credentials.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "Switch Database",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "crudder_db_test",
+                        "description": "Database name",
+                        "name": "schema",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Active database changed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid database name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Database not found or not accessible",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/login": {
             "post": {
                 "description": "Handler for logging into the database. Creates a session for the user after authenticating with the provided credentials.",
@@ -365,18 +549,16 @@ const docTemplate = `{
                     {
                         "type": "string",
                         "default": "crudder_user",
-                        "description": "Database username",
+                        "description": "Database username, not used by sqlite",
                         "name": "username",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "string",
                         "default": "crudder_p455w0rd",
-                        "description": "Database password",
+                        "description": "Database password, not used by sqlite",
                         "name": "password",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "string",
@@ -385,16 +567,59 @@ const docTemplate = `{
                         "name": "dbname",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "mysql",
+                            "postgres",
+                            "sqlite"
+                        ],
+                        "type": "string",
+                        "default": "mysql",
+                        "description": "Database engine",
+                        "name": "engine",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Server profile from /profiles; replaces DB_HOST and engine",
+                        "name": "profile",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return an API key for the Authorization: Bearer header instead of setting the session cookie",
+                        "name": "api_key",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the API key",
+                        "name": "key_name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": "720h",
+                        "description": "Lifetime of the API key as a Go duration",
+                        "name": "expires_in",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Login successful",
+                        "description": "Login successful, or the APIKeyInfo of the new key with api_key=true",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
+                        },
+                        "headers": {
+                            "X-CSRF-Token": {
+                                "type": "string",
+                                "description": "Token to send in the X-CSRF-Token header of POST, PUT and DELETE requests made with the session cookie"
+                            }
                         }
                     },
                     "400": {
@@ -409,6 +634,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Database not allowed on this server",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed logins, try again after the seconds in Retry-After",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Error connecting to the database",
                         "schema": {
@@ -419,8 +656,8 @@ const docTemplate = `{
             }
         },
         "/logout": {
-            "get": {
-                "description": "Handler for logging out and closing the database connection associated with the session.",
+            "post": {
+                "description": "Handler for logging out and closing the database connection associated with the session. Cookie sessions must send the X-CSRF-Token header.",
                 "produces": [
                     "application/json"
                 ],
@@ -446,42 +683,41 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "403": {
+                        "description": "Invalid or missing CSRF token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
-        "/table-structure": {
+        "/migrations": {
             "get": {
-                "description": "Handler for retrieving the structure of a specific table, including primary and foreign keys.",
+                "description": "Lists the migrations found in the migrations directory and whether each one is applied to the current database.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Database"
-                ],
-                "summary": "Get Table Structure",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "default": "users",
-                        "description": "Table name",
-                        "name": "table",
-                        "in": "query",
-                        "required": true
-                    }
+                    "Migrations"
                 ],
+                "summary": "Migration Status",
                 "responses": {
                     "200": {
-                        "description": "Table structure retrieved successfully",
+                        "description": "Status of every migration",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/crudder.ColumnInfo"
+                                "$ref": "#/definitions/crudder.MigrationStatus"
                             }
                         }
                     },
-                    "400": {
-                        "description": "The parameter 'table' is mandatory",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -489,8 +725,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -499,7 +735,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Error querying table structure or processing result",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -510,22 +746,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/tables": {
-            "get": {
-                "description": "Retrieves the names of all tables in the current database schema. Requires a valid database connection from the context.",
+        "/migrations/down": {
+            "post": {
+                "description": "Rolls back applied migrations in reverse version order, each one in its own transaction, holding the crudder_migrations lock of the database.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Database"
+                    "Migrations"
+                ],
+                "summary": "Migrate Down",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Number of migrations to roll back, 0 rolls back all",
+                        "name": "steps",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List Tables",
                 "responses": {
                     "200": {
-                        "description": "List of table names",
+                        "description": "Rolled back migrations",
                         "schema": {
-                            "type": "array",
-                            "items": {
+                            "$ref": "#/definitions/crudder.MigrationResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid steps",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
                                 "type": "string"
                             }
                         }
@@ -539,45 +790,2560 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "crudder.ColumnInfo": {
-            "type": "object",
-            "properties": {
-                "column_default": {
-                    "type": "string"
-                },
+                    },
+                    "409": {
+                        "description": "Applied migration has no down file, or another migration run is in progress",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Migration failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/migrations/up": {
+            "post": {
+                "description": "Applies pending migrations in version order, each one in its own transaction, holding the crudder_migrations lock of the database. Stops at the first failure; migrations applied before it stay applied.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Migrations"
+                ],
+                "summary": "Migrate Up",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Maximum number of migrations to apply, 0 applies all pending",
+                        "name": "steps",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Applied migrations",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.MigrationResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid steps",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Another migration run is in progress",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Migration failed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/openapi.json": {
+            "get": {
+                "description": "Returns an OpenAPI 3 document generated from the structure of the current database, with typed request and response schemas for login, logout, the crud routes of every visible table limited to the operations POLICY_FILE grants, and the saved query views. The other routes are described by this static documentation. Use it with Swagger UI (select \"Live schema\" at /swagger/) or an OpenAPI client generator.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Live OpenAPI Spec",
+                "responses": {
+                    "200": {
+                        "description": "OpenAPI document",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.OpenAPIDocument"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles": {
+            "get": {
+                "description": "Lists the database servers configured in PROFILES_FILE, to be passed as profile at login, with the databases each one allows. Available without a session.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "List Server Profiles",
+                "responses": {
+                    "200": {
+                        "description": "List of server profiles",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.ProfileInfo"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/routines": {
+            "get": {
+                "description": "Retrieves the stored procedures and functions of the current database schema, with their parameter signatures.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "List Routines",
+                "responses": {
+                    "200": {
+                        "description": "List of routines",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.RoutineInfo"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/diagram": {
+            "get": {
+                "description": "Renders an entity-relationship diagram of the current database: every table with its columns, primary and foreign key markers, and one edge per foreign key. Mermaid and DOT are returned as text for external renderers, svg is drawn by crudder itself.",
+                "produces": [
+                    "text/plain",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Schema Diagram",
+                "parameters": [
+                    {
+                        "enum": [
+                            "mermaid",
+                            "dot",
+                            "svg"
+                        ],
+                        "type": "string",
+                        "default": "mermaid",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Diagram source or SVG image",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Unsupported format",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/diff": {
+            "get": {
+                "description": "Compares the current database with another schema on the same server and reports added, removed and changed tables, columns, indexes and foreign keys. Added items exist only in the target. With script=true the response includes the ALTER statements that turn the current database into the target.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Diff Against Schema",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "crudder_db_staging",
+                        "description": "Schema to compare against",
+                        "name": "target",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include the ALTER script",
+                        "name": "script",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Differences between the schemas",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.SchemaDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid target",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Schema not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Script cannot be generated",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Compares the current database with a snapshot previously saved from GET /schema/snapshot. Added items exist only in the snapshot. With script=true the response includes the ALTER statements that turn the current database into the snapshot.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Diff Against Snapshot",
+                "parameters": [
+                    {
+                        "description": "Saved schema snapshot",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/crudder.SchemaSnapshot"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include the ALTER script",
+                        "name": "script",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Differences between the schemas",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.SchemaDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid snapshot",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Script cannot be generated",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/refresh": {
+            "post": {
+                "description": "Drops the table metadata cached by the session, so the next requests read the table list, columns and keys from information_schema again. Needed after the schema is changed outside crudder; the DDL and migration endpoints refresh the cache themselves. Entries also expire after SCHEMA_CACHE_TTL.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Refresh Schema Cache",
+                "responses": {
+                    "200": {
+                        "description": "Schema cache cleared",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/snapshot": {
+            "get": {
+                "description": "Returns the structure of every base table of the current database: columns, indexes and foreign keys. The result can be saved and later compared with POST /schema/diff.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Schema Snapshot",
+                "responses": {
+                    "200": {
+                        "description": "Structure of the current database",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.SchemaSnapshot"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables": {
+            "post": {
+                "description": "Creates a table from a JSON description whose columns and indexes mirror the table-structure responses. With dry_run=true the generated SQL is returned without being executed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Create Table",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Table definition",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/crudder.TableDefinition"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table definition",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables/{table}": {
+            "delete": {
+                "description": "Drops a table. With dry_run=true the generated SQL is returned without being executed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Drop Table",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables/{table}/columns": {
+            "post": {
+                "description": "Adds a column, described like a table-structure entry, to a table. With dry_run=true the generated SQL is returned without being executed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Add Column",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Column definition",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/crudder.ColumnInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid column definition",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables/{table}/columns/{column}": {
+            "put": {
+                "description": "Redefines a column; a different column_name in the body renames it. With dry_run=true the generated SQL is returned without being executed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Modify Column",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Column name",
+                        "name": "column",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Column definition",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/crudder.ColumnInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid column definition",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Drops a column from a table. With dry_run=true the generated SQL is returned without being executed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Drop Column",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Column name",
+                        "name": "column",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table or column name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables/{table}/indexes": {
+            "post": {
+                "description": "Creates an index, described like a table-structure/{table}/indexes entry. With dry_run=true the generated SQL is returned without being executed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Create Index",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Index definition",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/crudder.IndexInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid index definition",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/tables/{table}/indexes/{index}": {
+            "delete": {
+                "description": "Drops an index; the PRIMARY index drops the primary key. With dry_run=true the generated SQL is returned without being executed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Drop Index",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Index name",
+                        "name": "index",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the generated SQL",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Generated SQL and outcome",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.DDLResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table or index name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Index over columns hidden by POLICY_FILE",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error executing DDL",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/{table}.json": {
+            "get": {
+                "description": "Returns a JSON Schema (draft 2020-12) of a table, built from column types, nullability, defaults, enum members and maximum lengths. The create and update endpoints validate request bodies against this schema.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schema"
+                ],
+                "summary": "Table JSON Schema",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "users",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JSON Schema of the table",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.JSONSchema"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Table not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/table-structure": {
+            "get": {
+                "description": "Handler for retrieving the structure of a specific table, including primary and foreign keys, full column types (with enum/set members), length and numeric limits, auto_increment and generated columns, collation and comments.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "Get Table Structure",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "users",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Table structure retrieved successfully",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.ColumnInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "The parameter 'table' is mandatory",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Table not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error querying table structure or processing result",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/table-structure/{table}/constraints": {
+            "get": {
+                "description": "Retrieves the PRIMARY KEY, UNIQUE, FOREIGN KEY and CHECK constraints of a table, including referenced columns, referential actions and check clauses.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "List Table Constraints",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "user_roles",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Constraints of the table",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.ConstraintInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Table not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/table-structure/{table}/indexes": {
+            "get": {
+                "description": "Retrieves the indexes of a table, one entry per index with its columns in index order.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "List Table Indexes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "users",
+                        "description": "Table name",
+                        "name": "table",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Indexes of the table",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.IndexInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid table name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Table not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tables": {
+            "get": {
+                "description": "Retrieves all tables and views in the current database schema, with their type (BASE TABLE or VIEW), engine, estimated row count and comment. Requires a valid database connection from the context.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
+                ],
+                "summary": "List Tables",
+                "responses": {
+                    "200": {
+                        "description": "List of tables",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.TableInfo"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens": {
+            "get": {
+                "description": "Lists the API keys of the logged in database user, with their expiry and last use. The tokens themselves are not shown.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "List API Keys",
+                "responses": {
+                    "200": {
+                        "description": "API keys of the user",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.APIKeyInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Session cannot issue API keys",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Issues an API key with the credentials of the current session, for scripts and CI jobs. Send it as \"Authorization: Bearer \u003ctoken\u003e\"; it is shown only in this response. Keys issued with an API key expire no later than it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Issue API Key",
+                "parameters": [
+                    {
+                        "description": "Name and lifetime of the key",
+                        "name": "key",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.APIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The new key with its token",
+                        "schema": {
+                            "$ref": "#/definitions/crudder.APIKeyInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Error connecting to database",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens/{id}": {
+            "delete": {
+                "description": "Revokes one of the API keys of the logged in database user and closes its connection.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Revoke API Key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key id from GET /tokens",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "API key revoked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/views": {
+            "get": {
+                "description": "Lists the saved queries exposed as read-only views, with the parameters each one expects.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Views"
+                ],
+                "summary": "List Views",
+                "responses": {
+                    "200": {
+                        "description": "List of views",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/crudder.SavedQuery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/views/{name}": {
+            "get": {
+                "description": "Runs a saved query in a read-only transaction, binding its named parameters from the query string.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Views"
+                ],
+                "summary": "Read View",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "active_users_by_role",
+                        "description": "Name of the view",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of all records",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Missing view parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized or session not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "View or records not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "crudder.APIKeyInfo": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.APIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "description": "Go duration, e.g. \"720h\"; 30 days when empty",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.CallResult": {
+            "type": "object",
+            "properties": {
+                "out_params": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "result_sets": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "crudder.ColumnChange": {
+            "type": "object",
+            "properties": {
+                "column_name": {
+                    "type": "string"
+                },
+                "from": {
+                    "$ref": "#/definitions/crudder.ColumnInfo"
+                },
+                "to": {
+                    "$ref": "#/definitions/crudder.ColumnInfo"
+                }
+            }
+        },
+        "crudder.ColumnInfo": {
+            "type": "object",
+            "properties": {
+                "character_maximum_length": {
+                    "type": "integer"
+                },
+                "collation": {
+                    "type": "string"
+                },
+                "column_comment": {
+                    "type": "string"
+                },
+                "column_default": {
+                    "type": "string"
+                },
+                "column_name": {
+                    "type": "string"
+                },
+                "column_type": {
+                    "type": "string"
+                },
+                "data_type": {
+                    "type": "string"
+                },
+                "enum_values": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "extra": {
+                    "type": "string"
+                },
+                "foreign_key": {
+                    "type": "string"
+                },
+                "generation_expression": {
+                    "type": "string"
+                },
+                "is_auto_increment": {
+                    "type": "boolean"
+                },
+                "is_nullable": {
+                    "type": "boolean"
+                },
+                "is_primary_key": {
+                    "type": "boolean"
+                },
+                "numeric_precision": {
+                    "type": "integer"
+                },
+                "numeric_scale": {
+                    "type": "integer"
+                },
+                "referenced_column": {
+                    "type": "string"
+                },
+                "referenced_table": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.ConstraintInfo": {
+            "type": "object",
+            "properties": {
+                "check_clause": {
+                    "type": "string"
+                },
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "constraint_name": {
+                    "type": "string"
+                },
+                "constraint_type": {
+                    "type": "string"
+                },
+                "delete_rule": {
+                    "type": "string"
+                },
+                "referenced_columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "referenced_table": {
+                    "type": "string"
+                },
+                "update_rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.DDLResult": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "sql": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.DatabaseInfo": {
+            "type": "object",
+            "properties": {
+                "character_set": {
+                    "type": "string"
+                },
+                "collation": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.ForeignKeyChange": {
+            "type": "object",
+            "properties": {
+                "constraint_name": {
+                    "type": "string"
+                },
+                "from": {
+                    "$ref": "#/definitions/crudder.ConstraintInfo"
+                },
+                "to": {
+                    "$ref": "#/definitions/crudder.ConstraintInfo"
+                }
+            }
+        },
+        "crudder.IndexChange": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "$ref": "#/definitions/crudder.IndexInfo"
+                },
+                "index_name": {
+                    "type": "string"
+                },
+                "to": {
+                    "$ref": "#/definitions/crudder.IndexInfo"
+                }
+            }
+        },
+        "crudder.IndexColumn": {
+            "type": "object",
+            "properties": {
                 "column_name": {
                     "type": "string"
                 },
-                "data_type": {
+                "descending": {
+                    "type": "boolean"
+                },
+                "sub_part": {
+                    "type": "integer"
+                }
+            }
+        },
+        "crudder.IndexInfo": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.IndexColumn"
+                    }
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "index_name": {
+                    "type": "string"
+                },
+                "index_type": {
+                    "type": "string"
+                },
+                "is_primary": {
+                    "type": "boolean"
+                },
+                "is_unique": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "crudder.JSONSchema": {
+            "type": "object",
+            "properties": {
+                "$id": {
+                    "type": "string"
+                },
+                "$schema": {
+                    "type": "string"
+                },
+                "additionalProperties": {
+                    "type": "boolean"
+                },
+                "default": {},
+                "description": {
+                    "type": "string"
+                },
+                "enum": {
+                    "type": "array",
+                    "items": {}
+                },
+                "format": {
+                    "type": "string"
+                },
+                "maxLength": {
+                    "type": "integer"
+                },
+                "maximum": {
+                    "type": "number"
+                },
+                "minimum": {
+                    "type": "number"
+                },
+                "pattern": {
+                    "type": "string"
+                },
+                "properties": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.JSONSchema"
+                    }
+                },
+                "readOnly": {
+                    "type": "boolean"
+                },
+                "required": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "a type name, or a list when the column is nullable"
+                }
+            }
+        },
+        "crudder.MigrationResult": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "migrations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.MigrationStatus"
+                    }
+                }
+            }
+        },
+        "crudder.MigrationStatus": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "boolean"
+                },
+                "applied_at": {
+                    "type": "string"
+                },
+                "has_down": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "crudder.OpenAPIComponents": {
+            "type": "object",
+            "properties": {
+                "schemas": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPISchema"
+                    }
+                },
+                "securitySchemes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPISecurityScheme"
+                    }
+                }
+            }
+        },
+        "crudder.OpenAPIDocument": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "$ref": "#/definitions/crudder.OpenAPIComponents"
+                },
+                "info": {
+                    "$ref": "#/definitions/crudder.OpenAPIInfo"
+                },
+                "openapi": {
+                    "type": "string"
+                },
+                "paths": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "$ref": "#/definitions/crudder.OpenAPIOperation"
+                        }
+                    }
+                },
+                "security": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "servers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.OpenAPIServer"
+                    }
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.OpenAPITag"
+                    }
+                }
+            }
+        },
+        "crudder.OpenAPIInfo": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.OpenAPIMediaType": {
+            "type": "object",
+            "properties": {
+                "schema": {
+                    "$ref": "#/definitions/crudder.OpenAPISchema"
+                }
+            }
+        },
+        "crudder.OpenAPIOperation": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "operationId": {
                     "type": "string"
                 },
-                "foreign_key": {
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.OpenAPIParameter"
+                    }
+                },
+                "requestBody": {
+                    "$ref": "#/definitions/crudder.OpenAPIRequestBody"
+                },
+                "responses": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPIResponse"
+                    }
+                },
+                "security": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "summary": {
                     "type": "string"
                 },
-                "is_nullable": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "crudder.OpenAPIParameter": {
+            "type": "object",
+            "properties": {
+                "in": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "required": {
                     "type": "boolean"
                 },
-                "is_primary_key": {
+                "schema": {
+                    "$ref": "#/definitions/crudder.OpenAPISchema"
+                }
+            }
+        },
+        "crudder.OpenAPIRequestBody": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPIMediaType"
+                    }
+                },
+                "required": {
                     "type": "boolean"
+                }
+            }
+        },
+        "crudder.OpenAPIResponse": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPIMediaType"
+                    }
                 },
-                "referenced_column": {
+                "description": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.OpenAPISchema": {
+            "type": "object",
+            "properties": {
+                "$ref": {
                     "type": "string"
                 },
-                "referenced_table": {
+                "additionalProperties": {},
+                "default": {},
+                "description": {
+                    "type": "string"
+                },
+                "enum": {
+                    "type": "array",
+                    "items": {}
+                },
+                "format": {
+                    "type": "string"
+                },
+                "items": {
+                    "$ref": "#/definitions/crudder.OpenAPISchema"
+                },
+                "maxLength": {
+                    "type": "integer"
+                },
+                "maximum": {
+                    "type": "number"
+                },
+                "minimum": {
+                    "type": "number"
+                },
+                "nullable": {
+                    "type": "boolean"
+                },
+                "oneOf": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.OpenAPISchema"
+                    }
+                },
+                "pattern": {
+                    "type": "string"
+                },
+                "properties": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/crudder.OpenAPISchema"
+                    }
+                },
+                "readOnly": {
+                    "type": "boolean"
+                },
+                "required": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.OpenAPISecurityScheme": {
+            "type": "object",
+            "properties": {
+                "in": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.OpenAPIServer": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.OpenAPITag": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.ProfileInfo": {
+            "type": "object",
+            "properties": {
+                "databases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "engine": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.RoutineInfo": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.RoutineParameter"
+                    }
+                },
+                "return_type": {
+                    "type": "string"
+                },
+                "routine_name": {
+                    "type": "string"
+                },
+                "routine_type": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.RoutineParameter": {
+            "type": "object",
+            "properties": {
+                "data_type": {
+                    "type": "string"
+                },
+                "mode": {
+                    "description": "IN, OUT or INOUT (always IN for functions)",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.SavedQuery": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sql": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.SchemaDiff": {
+            "type": "object",
+            "properties": {
+                "added_tables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.TableSnapshot"
+                    }
+                },
+                "changed_tables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.TableDiff"
+                    }
+                },
+                "removed_tables": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "script": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "source": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.SchemaSnapshot": {
+            "type": "object",
+            "properties": {
+                "schema": {
+                    "type": "string"
+                },
+                "tables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.TableSnapshot"
+                    }
+                }
+            }
+        },
+        "crudder.TableDefinition": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ColumnInfo"
+                    }
+                },
+                "engine": {
+                    "type": "string"
+                },
+                "indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.IndexInfo"
+                    }
+                },
+                "table_comment": {
+                    "type": "string"
+                },
+                "table_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.TableDiff": {
+            "type": "object",
+            "properties": {
+                "added_columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ColumnInfo"
+                    }
+                },
+                "added_foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ConstraintInfo"
+                    }
+                },
+                "added_indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.IndexInfo"
+                    }
+                },
+                "changed_columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ColumnChange"
+                    }
+                },
+                "changed_foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ForeignKeyChange"
+                    }
+                },
+                "changed_indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.IndexChange"
+                    }
+                },
+                "removed_columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "removed_foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "removed_indexes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "table_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.TableInfo": {
+            "type": "object",
+            "properties": {
+                "engine": {
+                    "type": "string"
+                },
+                "row_estimate": {
+                    "type": "integer"
+                },
+                "table_comment": {
+                    "type": "string"
+                },
+                "table_name": {
+                    "type": "string"
+                },
+                "table_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.TableSnapshot": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ColumnInfo"
+                    }
+                },
+                "engine": {
+                    "type": "string"
+                },
+                "foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.ConstraintInfo"
+                    }
+                },
+                "indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.IndexInfo"
+                    }
+                },
+                "table_comment": {
+                    "type": "string"
+                },
+                "table_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "crudder.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/crudder.FieldError"
+                    }
+                },
+                "message": {
                     "type": "string"
                 }
             }